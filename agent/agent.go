@@ -0,0 +1,346 @@
+// Package agent provides a token agent which holds `goauth.CredentialsSet` accounts in memory
+// and serves access tokens to local processes over a Unix socket, like `ssh-agent`. Tokens
+// are refreshed before they expire so clients sharing an account do not each request tokens
+// or race to use rotating refresh tokens.
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grokify/goauth"
+	"github.com/grokify/goauth/authutil"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// DefaultRefreshBefore is how long before a token expires the agent refreshes it.
+	DefaultRefreshBefore = 5 * time.Minute
+	// RetryInterval is the wait before retrying a failed refresh.
+	RetryInterval = 30 * time.Second
+
+	// minTokenTTL matches the expiry margin of `oauth2.Token.Valid()`. Tokens which expire
+	// sooner are not served.
+	minTokenTTL = 10 * time.Second
+)
+
+// TokenFunc returns a new token for an account. `tok` is the current token, if any, whose
+// refresh token may be used.
+type TokenFunc func(ctx context.Context, account string, tok *oauth2.Token) (*oauth2.Token, error)
+
+// Agent serves access tokens for the accounts in `Set`. Tokens are requested when first
+// used and then refreshed `RefreshBefore` they expire. If `Cache` is set, cached tokens are
+// used when an account is first requested and new tokens are written to the cache.
+type Agent struct {
+	Set           *goauth.CredentialsSet
+	Filename      string // credentials set file name, used for token cache keys
+	Cache         *authutil.TokenCache
+	NewToken      TokenFunc // defaults to `Credentials.RenewToken()` for the account
+	RefreshBefore time.Duration
+	Logger        *slog.Logger
+
+	mu       sync.Mutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+	accounts map[string]*account
+}
+
+type account struct {
+	mu     sync.Mutex
+	loaded bool
+	tok    *oauth2.Token
+	timer  *time.Timer
+}
+
+// New returns an agent for the accounts in `set`. `logger` may be nil.
+func New(set *goauth.CredentialsSet, logger *slog.Logger) *Agent {
+	return &Agent{
+		Set:           set,
+		RefreshBefore: DefaultRefreshBefore,
+		Logger:        logger}
+}
+
+// Token returns an access token for an account, which is requested if there is no current
+// token or `force` is set. Concurrent requests for an account share a single token request.
+func (a *Agent) Token(ctx context.Context, name string, force bool) (*oauth2.Token, error) {
+	acct, err := a.account(name)
+	if err != nil {
+		return nil, err
+	}
+	acct.mu.Lock()
+	defer acct.mu.Unlock()
+	if !acct.loaded {
+		acct.loaded = true
+		if tok := a.cachedToken(name); current(tok) {
+			acct.tok = tok
+			a.schedule(name, acct)
+		}
+	}
+	if !force && current(acct.tok) {
+		return acct.tok, nil
+	}
+	return a.renew(ctx, name, acct)
+}
+
+func (a *Agent) account(name string) (*account, error) {
+	if a.Set == nil {
+		return nil, fmt.Errorf("%w (%s)", goauth.ErrAccountNotFound, name)
+	} else if _, err := a.Set.Get(name); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.accounts == nil {
+		a.accounts = map[string]*account{}
+	}
+	acct, ok := a.accounts[name]
+	if !ok {
+		acct = &account{}
+		a.accounts[name] = acct
+	}
+	return acct, nil
+}
+
+// renew requests a new token and schedules its refresh. `acct.mu` must be held.
+func (a *Agent) renew(ctx context.Context, name string, acct *account) (*oauth2.Token, error) {
+	newToken := a.NewToken
+	if newToken == nil {
+		newToken = a.renewToken
+	}
+	tok, err := newToken(ctx, name, acct.tok)
+	if err != nil {
+		return nil, err
+	} else if tok == nil || strings.TrimSpace(tok.AccessToken) == "" {
+		return nil, fmt.Errorf("no access token for account `%s`", name)
+	}
+	acct.tok = tok
+	a.schedule(name, acct)
+	return tok, nil
+}
+
+// renewToken is the default `TokenFunc`. The cached token's refresh token is used if the
+// agent has no token for the account.
+func (a *Agent) renewToken(ctx context.Context, name string, tok *oauth2.Token) (*oauth2.Token, error) {
+	creds, err := a.Set.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if tok == nil {
+		tok = a.cachedToken(name)
+	}
+	newTok, err := creds.RenewToken(ctx, tok)
+	if err != nil {
+		return nil, err
+	}
+	if a.Cache != nil {
+		if key, err := creds.TokenCacheKey(a.Filename, name); err == nil {
+			if err := a.Cache.Put(key, newTok); err != nil {
+				a.log(slog.LevelWarn, "token cache write failed", "account", name, "error", err.Error())
+			}
+		}
+	}
+	return newTok, nil
+}
+
+// cachedToken returns the token cache entry for an account, or nil.
+func (a *Agent) cachedToken(name string) *oauth2.Token {
+	if a.Cache == nil || a.Set == nil {
+		return nil
+	}
+	creds, err := a.Set.Get(name)
+	if err != nil {
+		return nil
+	}
+	key, err := creds.TokenCacheKey(a.Filename, name)
+	if err != nil {
+		return nil
+	}
+	tok, err := a.Cache.Get(key)
+	if err != nil {
+		return nil
+	}
+	return tok
+}
+
+// current reports whether a token can be served without requesting a new one.
+func current(tok *oauth2.Token) bool {
+	return tok != nil && strings.TrimSpace(tok.AccessToken) != "" &&
+		(tok.Expiry.IsZero() || time.Until(tok.Expiry) > minTokenTTL)
+}
+
+// refreshAt returns when to refresh `tok`: `RefreshBefore` it expires, but no earlier than
+// halfway to expiry so short-lived tokens are not refreshed continuously. It returns the zero
+// time if the token does not expire.
+func (a *Agent) refreshAt(tok *oauth2.Token) time.Time {
+	if tok == nil || tok.Expiry.IsZero() {
+		return time.Time{}
+	}
+	now := time.Now()
+	half := now.Add(max(tok.Expiry.Sub(now)/2, time.Second))
+	if at := tok.Expiry.Add(-a.RefreshBefore); at.After(half) {
+		return at
+	}
+	return half
+}
+
+// schedule sets the refresh timer for the account's token. `acct.mu` must be held.
+func (a *Agent) schedule(name string, acct *account) {
+	a.scheduleAt(name, acct, a.refreshAt(acct.tok))
+}
+
+func (a *Agent) scheduleAt(name string, acct *account, at time.Time) {
+	if acct.timer != nil {
+		acct.timer.Stop()
+		acct.timer = nil
+	}
+	if !at.IsZero() && a.context().Err() == nil {
+		acct.timer = time.AfterFunc(time.Until(at), func() { a.refresh(name, acct) })
+	}
+}
+
+// refresh renews the account's token, retrying after `RetryInterval` on failure while the
+// current token is unexpired.
+func (a *Agent) refresh(name string, acct *account) {
+	ctx := a.context()
+	if ctx.Err() != nil {
+		return
+	}
+	acct.mu.Lock()
+	defer acct.mu.Unlock()
+	if tok, err := a.renew(ctx, name, acct); err != nil {
+		a.log(slog.LevelWarn, "token refresh failed", "account", name, "error", err.Error())
+		if retry := time.Now().Add(RetryInterval); acct.tok != nil && retry.Before(acct.tok.Expiry) {
+			a.scheduleAt(name, acct, retry)
+		}
+	} else {
+		a.log(slog.LevelInfo, "token refreshed", "account", name, "expiry", tok.Expiry)
+	}
+}
+
+func (a *Agent) context() context.Context {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.ctx == nil {
+		return context.Background()
+	}
+	return a.ctx
+}
+
+// Do handles a request.
+func (a *Agent) Do(ctx context.Context, req Request) Response {
+	switch req.Op {
+	case OpPing, OpStop:
+		return Response{}
+	case OpList:
+		if a.Set == nil {
+			return Response{Accounts: []string{}}
+		}
+		return Response{Accounts: a.Set.Keys()}
+	case OpToken, OpRefresh:
+		if strings.TrimSpace(req.Account) == "" {
+			return errorResponse(ErrorCodeBadRequest, errors.New("account is required"))
+		}
+		tok, err := a.Token(ctx, req.Account, req.Op == OpRefresh)
+		if errors.Is(err, goauth.ErrAccountNotFound) {
+			return errorResponse(ErrorCodeAccountNotFound, err)
+		} else if err != nil {
+			a.log(slog.LevelWarn, "token request failed", "account", req.Account, "error", err.Error())
+			return errorResponse(ErrorCodeToken, err)
+		}
+		return Response{
+			AccessToken: tok.AccessToken,
+			TokenType:   tok.Type(),
+			Expiry:      tok.Expiry}
+	default:
+		return errorResponse(ErrorCodeBadRequest, fmt.Errorf("unknown op `%s`", req.Op))
+	}
+}
+
+// Serve accepts connections on `l` until `ctx` is done or a client sends `OpStop`. It
+// closes `l` and stops refreshing tokens on return. Connections from processes run by other
+// users are closed where peer credentials are supported.
+func (a *Agent) Serve(ctx context.Context, l net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	a.mu.Lock()
+	a.ctx, a.cancel = ctx, cancel
+	a.mu.Unlock()
+	defer a.stop()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+	context.AfterFunc(ctx, func() { l.Close() })
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Go(func() { a.serveConn(ctx, conn) })
+	}
+}
+
+// stop cancels the serve context and stops refresh timers.
+func (a *Agent) stop() {
+	a.mu.Lock()
+	if a.cancel != nil {
+		a.cancel()
+	}
+	accounts := make([]*account, 0, len(a.accounts))
+	for _, acct := range a.accounts {
+		accounts = append(accounts, acct)
+	}
+	a.mu.Unlock()
+	for _, acct := range accounts {
+		acct.mu.Lock()
+		if acct.timer != nil {
+			acct.timer.Stop()
+			acct.timer = nil
+		}
+		acct.mu.Unlock()
+	}
+}
+
+func (a *Agent) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	if err := checkPeer(conn); err != nil {
+		a.log(slog.LevelWarn, "connection refused", "error", err.Error())
+		return
+	}
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	enc := json.NewEncoder(conn)
+	for {
+		var req Request
+		if err := dec.Decode(&req); err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				_ = enc.Encode(errorResponse(ErrorCodeBadRequest, err))
+			}
+			return
+		}
+		if err := enc.Encode(a.Do(ctx, req)); err != nil {
+			return
+		} else if req.Op == OpStop {
+			a.log(slog.LevelInfo, "stop requested")
+			a.stop()
+			return
+		}
+	}
+}
+
+func (a *Agent) log(level slog.Level, msg string, args ...any) {
+	if a.Logger != nil {
+		a.Logger.Log(context.Background(), level, msg, args...)
+	}
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grokify/goauth"
+	"golang.org/x/oauth2"
+)
+
+func TestAgent(t *testing.T) {
+	var calls atomic.Int32
+	a := New(&goauth.CredentialsSet{Credentials: map[string]goauth.Credentials{
+		"a": {Type: goauth.TypeOAuth2},
+		"b": {Type: goauth.TypeOAuth2}}}, nil)
+	a.NewToken = func(ctx context.Context, account string, tok *oauth2.Token) (*oauth2.Token, error) {
+		n := calls.Add(1)
+		return &oauth2.Token{AccessToken: account + "-" + strconv.Itoa(int(n)), Expiry: time.Now().Add(time.Hour)}, nil
+	}
+	path := filepath.Join(t.TempDir(), "goauth", "agent.sock")
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen(): err [%v]", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- a.Serve(context.Background(), l) }()
+
+	ctx := context.Background()
+	c := NewClient(path)
+	for range 2 {
+		if tok, err := c.Token(ctx, "a"); err != nil || tok.AccessToken != "a-1" {
+			t.Errorf("Client.Token(a): want [a-1], got [%v] err [%v]", tok, err)
+		}
+	}
+	if tok, err := c.Refresh(ctx, "a"); err != nil || tok.AccessToken != "a-2" {
+		t.Errorf("Client.Refresh(a): want [a-2], got [%v] err [%v]", tok, err)
+	}
+	if tok, err := c.TokenSource(ctx, "a").Token(); err != nil || tok.AccessToken != "a-2" {
+		t.Errorf("Client.TokenSource(a).Token(): want [a-2], got [%v] err [%v]", tok, err)
+	}
+	if _, err := c.Token(ctx, "c"); !errors.Is(err, goauth.ErrAccountNotFound) {
+		t.Errorf("Client.Token(c): want [%v], got [%v]", goauth.ErrAccountNotFound, err)
+	}
+	if accounts, err := c.Accounts(ctx); err != nil || !slices.Equal(accounts, []string{"a", "b"}) {
+		t.Errorf("Client.Accounts(): want [a b], got [%v] err [%v]", accounts, err)
+	}
+	if _, err := Listen(path); err == nil {
+		t.Errorf("Listen(): want error for running agent, got nil")
+	}
+	if err := c.Stop(ctx); err != nil {
+		t.Errorf("Client.Stop(): err [%v]", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Agent.Serve(): err [%v]", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Agent.Serve(): did not stop")
+	}
+	if err := c.Ping(ctx); err == nil {
+		t.Errorf("Client.Ping(): want error after stop, got nil")
+	}
+}
+
+var refreshAtTests = []struct {
+	ttl           time.Duration
+	refreshBefore time.Duration
+	want          time.Duration
+}{
+	{time.Hour, 5 * time.Minute, 55 * time.Minute},
+	{4 * time.Minute, 5 * time.Minute, 2 * time.Minute},
+	{time.Second, 5 * time.Minute, time.Second},
+	{0, 5 * time.Minute, 0},
+}
+
+func TestRefreshAt(t *testing.T) {
+	for _, tt := range refreshAtTests {
+		a := Agent{RefreshBefore: tt.refreshBefore}
+		tok := &oauth2.Token{AccessToken: "x"}
+		if tt.ttl > 0 {
+			tok.Expiry = time.Now().Add(tt.ttl)
+		}
+		at := a.refreshAt(tok)
+		if tt.want == 0 {
+			if !at.IsZero() {
+				t.Errorf("Agent.refreshAt(%s): want zero, got [%v]", tt.ttl, at)
+			}
+		} else if got := time.Until(at); got > tt.want || got < tt.want-time.Second {
+			t.Errorf("Agent.refreshAt(%s): want [%s], got [%s]", tt.ttl, tt.want, got)
+		}
+	}
+}
+
+func TestListenSocketDir(t *testing.T) {
+	tmp := t.TempDir()
+	target := filepath.Join(tmp, "target")
+	if err := os.Mkdir(target, 0700); err != nil {
+		t.Fatalf("os.Mkdir(): err [%v]", err)
+	}
+	link := filepath.Join(tmp, "link")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatalf("os.Symlink(): err [%v]", err)
+	}
+	open := filepath.Join(tmp, "open")
+	if err := os.Mkdir(open, 0755); err != nil {
+		t.Fatalf("os.Mkdir(): err [%v]", err)
+	}
+	dirs := []string{link, open}
+	// Only root can create a directory owned by another user.
+	if os.Getuid() == 0 {
+		other := filepath.Join(tmp, "other")
+		if err := os.Mkdir(other, 0700); err != nil {
+			t.Fatalf("os.Mkdir(): err [%v]", err)
+		} else if err := os.Chown(other, 65534, 65534); err != nil {
+			t.Fatalf("os.Chown(): err [%v]", err)
+		}
+		dirs = append(dirs, other)
+	}
+	for _, dir := range dirs {
+		if l, err := Listen(filepath.Join(dir, "agent.sock")); err == nil {
+			l.Close()
+			t.Errorf("Listen(%s): want error, got nil", filepath.Base(dir))
+		}
+	}
+}
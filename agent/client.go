@@ -0,0 +1,125 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// DefaultTimeout is the default time limit for a request to the agent.
+const DefaultTimeout = time.Minute
+
+// Client sends requests to an agent. Each request uses a new connection.
+type Client struct {
+	SocketPath string
+	Timeout    time.Duration // defaults to `DefaultTimeout` if the context has no deadline
+}
+
+// NewClient returns a client for the agent at `socketPath`, using `DefaultSocketPath()` if
+// empty.
+func NewClient(socketPath string) *Client {
+	if socketPath == "" {
+		socketPath = DefaultSocketPath()
+	}
+	return &Client{SocketPath: socketPath, Timeout: DefaultTimeout}
+}
+
+// Do sends a request and returns the response, or the error in the response.
+func (c *Client) Do(ctx context.Context, req Request) (Response, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := c.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", c.SocketPath)
+	if err != nil {
+		return Response{}, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return Response{}, err
+		}
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return Response{}, err
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		if ctx.Err() != nil {
+			return Response{}, ctx.Err()
+		}
+		return Response{}, err
+	}
+	return resp, resp.Err()
+}
+
+// Ping returns an error if the agent is not running.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, Request{Op: OpPing})
+	return err
+}
+
+// Token returns an unexpired access token for an account.
+func (c *Client) Token(ctx context.Context, account string) (*oauth2.Token, error) {
+	resp, err := c.Do(ctx, Request{Op: OpToken, Account: account})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Token(), nil
+}
+
+// Refresh returns a new access token for an account, e.g. after the current token was
+// rejected.
+func (c *Client) Refresh(ctx context.Context, account string) (*oauth2.Token, error) {
+	resp, err := c.Do(ctx, Request{Op: OpRefresh, Account: account})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Token(), nil
+}
+
+// Accounts returns the agent's accounts.
+func (c *Client) Accounts(ctx context.Context) ([]string, error) {
+	resp, err := c.Do(ctx, Request{Op: OpList})
+	return resp.Accounts, err
+}
+
+// Stop stops the agent.
+func (c *Client) Stop(ctx context.Context) error {
+	_, err := c.Do(ctx, Request{Op: OpStop})
+	return err
+}
+
+// TokenSource returns an `oauth2.TokenSource` which gets tokens for an account from the
+// agent, reusing each token until it expires.
+func (c *Client) TokenSource(ctx context.Context, account string) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, &tokenSource{ctx: ctx, client: c, account: account})
+}
+
+// NewHTTPClient returns an `*http.Client` which authorizes requests with tokens for an
+// account from the agent.
+func (c *Client) NewHTTPClient(ctx context.Context, account string) *http.Client {
+	return oauth2.NewClient(ctx, c.TokenSource(ctx, account))
+}
+
+type tokenSource struct {
+	ctx     context.Context
+	client  *Client
+	account string
+}
+
+func (ts *tokenSource) Token() (*oauth2.Token, error) {
+	return ts.client.Token(ts.ctx, ts.account)
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package agent
+
+import "os"
+
+// fileOwner is not supported on this platform, so only the directory permissions are checked.
+func fileOwner(fi os.FileInfo) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,25 @@
+package agent
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerUID returns the user ID of the process connected to `conn` using `LOCAL_PEERCRED`.
+func peerUID(conn *net.UnixConn) (int, bool, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, false, err
+	}
+	var cred *unix.Xucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptXucred(int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERCRED) // #nosec G115
+	}); err != nil {
+		return 0, false, err
+	} else if credErr != nil {
+		return 0, false, credErr
+	}
+	return int(cred.Uid), true, nil
+}
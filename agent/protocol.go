@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/grokify/goauth"
+	"golang.org/x/oauth2"
+)
+
+// Request operations. Requests and responses are newline delimited JSON objects, and a
+// connection may send any number of requests.
+const (
+	OpPing    = "ping"    // check the agent is running
+	OpToken   = "token"   // get an unexpired access token for `account`
+	OpRefresh = "refresh" // get a new access token for `account`
+	OpList    = "list"    // list the accounts
+	OpStop    = "stop"    // stop the agent
+)
+
+// Response error codes.
+const (
+	ErrorCodeAccountNotFound = "account_not_found"
+	ErrorCodeBadRequest      = "bad_request"
+	ErrorCodeToken           = "token_error"
+)
+
+var ErrAgent = errors.New("agent error")
+
+// Request is a request to the agent.
+type Request struct {
+	Op      string `json:"op"`
+	Account string `json:"account,omitempty"`
+}
+
+// Response is the agent's response to a request. `Error` is set if the request failed.
+type Response struct {
+	AccessToken string    `json:"accessToken,omitempty"`
+	TokenType   string    `json:"tokenType,omitempty"`
+	Expiry      time.Time `json:"expiry,omitzero"`
+	Accounts    []string  `json:"accounts,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	ErrorCode   string    `json:"errorCode,omitempty"`
+}
+
+// Token returns the access token in the response. The refresh token is never sent.
+func (resp Response) Token() *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken: resp.AccessToken,
+		TokenType:   resp.TokenType,
+		Expiry:      resp.Expiry}
+}
+
+// Err returns the error in the response, or nil.
+func (resp Response) Err() error {
+	if resp.Error == "" && resp.ErrorCode == "" {
+		return nil
+	}
+	return &Error{Code: resp.ErrorCode, Message: resp.Error}
+}
+
+// Error is an error returned by the agent.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", ErrAgent.Error(), e.Message)
+}
+
+// Is matches `ErrAgent`, and `goauth.ErrAccountNotFound` for unknown accounts.
+func (e *Error) Is(target error) bool {
+	return target == ErrAgent ||
+		(target == goauth.ErrAccountNotFound && e.Code == ErrorCodeAccountNotFound)
+}
+
+func errorResponse(code string, err error) Response {
+	return Response{ErrorCode: code, Error: err.Error()}
+}
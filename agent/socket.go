@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// EnvSocket is the environment variable with the agent socket path, like `SSH_AUTH_SOCK`.
+const EnvSocket = "GOAUTH_AGENT_SOCK"
+
+const socketName = "agent.sock"
+
+var ErrPeerNotAllowed = errors.New("agent peer is not the agent user")
+
+// DefaultSocketPath returns the socket path from `GOAUTH_AGENT_SOCK`, or `goauth/agent.sock`
+// under `XDG_RUNTIME_DIR`, or `goauth-<uid>/agent.sock` under the temporary directory.
+func DefaultSocketPath() string {
+	if s := os.Getenv(EnvSocket); s != "" {
+		return s
+	} else if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "goauth", socketName)
+	}
+	return filepath.Join(os.TempDir(), "goauth-"+strconv.Itoa(os.Getuid()), socketName)
+}
+
+// Listen listens on a Unix socket at `path`, using `DefaultSocketPath()` if empty. The socket
+// directory is created with `0700` permissions if it does not exist, and must be a directory,
+// not a symlink, owned by the current user and not accessible by other users, since another
+// user could create the `goauth-<uid>` directory under a shared temporary directory first. A stale socket file is removed, but an error is returned if an
+// agent is listening on it.
+func Listen(path string) (*net.UnixListener, error) {
+	if path == "" {
+		path = DefaultSocketPath()
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	} else if fi, err := os.Lstat(dir); err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("agent socket directory is not a directory (%s)", dir)
+	} else if uid, ok := fileOwner(fi); ok && uid != os.Getuid() {
+		return nil, fmt.Errorf("agent socket directory is owned by another user (uid %d %s)", uid, dir)
+	} else if fi.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("agent socket directory is accessible by other users (%s %s)", fi.Mode().Perm(), dir)
+	}
+	if _, err := os.Lstat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("agent already listening (%s)", path)
+		} else if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	} else if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// checkPeer returns `ErrPeerNotAllowed` if the process connected to `conn` is run by another
+// user. Where peer credentials are not supported, the socket permissions are relied on.
+func checkPeer(conn net.Conn) error {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil
+	}
+	uid, ok, err := peerUID(uc)
+	if err != nil {
+		return err
+	} else if ok && uid != os.Getuid() {
+		return fmt.Errorf("%w (uid %d)", ErrPeerNotAllowed, uid)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package aha
+
+import (
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/goauth/endpoints"
+	"github.com/grokify/goauth/multiservice/providers"
+)
+
+func init() {
+	providers.MustRegister(providers.Provider{
+		Name: endpoints.ServiceAha,
+		// The endpoint requires the account subdomain.
+		Endpoint:      providers.ServiceEndpoint(endpoints.ServiceAha),
+		NewClientUtil: func() authutil.OAuth2Util { return &ClientUtil{} }})
+}
@@ -0,0 +1,357 @@
+// Package authproxy provides a local reverse proxy which forwards requests upstream with
+// credentials from `goauth.CredentialsSet` accounts.
+package authproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/grokify/goauth"
+	"github.com/grokify/mogo/net/http/httputilmore"
+)
+
+// DefaultAddr is the default listen address, which accepts connections from this host only.
+const DefaultAddr = "127.0.0.1:9000"
+
+var (
+	ErrNoRoute = errors.New("no route for request")
+	// ErrHostNotAllowed is returned for requests whose `Host` is not allowed, e.g. from a web
+	// page whose domain resolves to the loopback address.
+	ErrHostNotAllowed = errors.New("request host not allowed")
+)
+
+// sensitiveHeaders are always redacted in logs.
+var sensitiveHeaders = []string{
+	httputilmore.HeaderAuthorization,
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// sensitiveNameParts mark header and query parameter names whose values are redacted in logs.
+var sensitiveNameParts = []string{"token", "secret", "password", "apikey", "api-key", "api_key", "signature", "session"}
+
+// Route forwards requests matching `Host` and `PathPrefix` to `Upstream` using the transport of
+// `Client`, which adds the account's credentials. An empty `Host` or `PathPrefix` matches any.
+type Route struct {
+	Account     string
+	Host        string
+	PathPrefix  string
+	StripPrefix bool
+	Upstream    *url.URL
+	Client      *http.Client
+}
+
+// ParseRoute parses a route in the format `[HOST][/PREFIX]=ACCOUNT[@UPSTREAM]`, e.g.
+// `/github=github`, `api.local=crm` or `/crm=crm@https://api.example.com/v2`. Path prefixes are
+// stripped from forwarded requests. `Upstream` is not set if `@UPSTREAM` is not given.
+func ParseRoute(s string) (Route, error) {
+	match, target, ok := strings.Cut(strings.TrimSpace(s), "=")
+	if !ok || strings.TrimSpace(match) == "" {
+		return Route{}, fmt.Errorf("route must be `[HOST][/PREFIX]=ACCOUNT[@UPSTREAM]` (%s)", s)
+	}
+	rt := Route{StripPrefix: true}
+	rt.Host, rt.PathPrefix, _ = strings.Cut(strings.TrimSpace(match), "/")
+	if rt.PathPrefix != "" || strings.HasSuffix(match, "/") {
+		rt.PathPrefix = "/" + rt.PathPrefix
+	}
+	account, upstream, _ := strings.Cut(target, "@")
+	if rt.Account = strings.TrimSpace(account); rt.Account == "" {
+		return Route{}, fmt.Errorf("route account is required (%s)", s)
+	}
+	if upstream = strings.TrimSpace(upstream); upstream != "" {
+		u, err := ParseUpstream(upstream)
+		if err != nil {
+			return Route{}, err
+		}
+		rt.Upstream = u
+	}
+	return rt, nil
+}
+
+// ParseUpstream parses an absolute `http` or `https` upstream URL.
+func ParseUpstream(s string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("upstream must be an absolute http or https URL (%s)", s)
+	}
+	return u, nil
+}
+
+// Match returns whether the route matches the request host and path.
+func (rt Route) Match(r *http.Request) bool {
+	if rt.Host != "" && !strings.EqualFold(rt.Host, requestHost(r)) {
+		return false
+	}
+	return rt.PathPrefix == "" || hasPathPrefix(r.URL.Path, rt.PathPrefix)
+}
+
+// specificity ranks matching routes. Host routes rank above path-only routes, then longer
+// path prefixes rank higher.
+func (rt Route) specificity() int {
+	n := len(rt.PathPrefix)
+	if rt.Host != "" {
+		n += 1 << 16
+	}
+	return n
+}
+
+// String returns the route in `ParseRoute()` format.
+func (rt Route) String() string {
+	s := rt.Host + rt.PathPrefix
+	if s == "" {
+		s = "/"
+	}
+	s += "=" + rt.Account
+	if rt.Upstream != nil {
+		s += "@" + rt.Upstream.String()
+	}
+	return s
+}
+
+// hasPathPrefix returns whether `path` is `prefix` or is under it.
+func hasPathPrefix(path, prefix string) bool {
+	if strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(path, prefix)
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+func requestHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.Host); err == nil {
+		return host
+	}
+	return r.Host
+}
+
+// Proxy forwards requests to the most specific matching route. Inbound `Authorization` and
+// `Proxy-Authorization` headers are removed before the route's credentials are added. Requests
+// are only accepted for `localhost`, loopback IPs, route hosts and `AllowedHosts`, so a web page
+// on a domain which resolves to the loopback address cannot use the proxy (DNS rebinding).
+type Proxy struct {
+	Routes []Route
+	// AllowedHosts are additional request hosts to accept, e.g. the host name the proxy is
+	// reached with when listening on a non-loopback address.
+	AllowedHosts []string
+	// Logger logs each request and response with credentials and sensitive values redacted.
+	// Logging is disabled if nil.
+	Logger  *slog.Logger
+	proxies []*httputil.ReverseProxy
+}
+
+// New returns a `Proxy` for `routes`. Each route requires `Upstream` and `Client`.
+func New(routes []Route, logger *slog.Logger) (*Proxy, error) {
+	p := &Proxy{Routes: routes, Logger: logger}
+	for _, rt := range routes {
+		if rt.Upstream == nil {
+			return nil, fmt.Errorf("route has no upstream (%s)", rt.String())
+		} else if rt.Client == nil {
+			return nil, fmt.Errorf("route has no client (%s)", rt.String())
+		}
+		p.proxies = append(p.proxies, p.reverseProxy(rt))
+	}
+	return p, nil
+}
+
+// NewRoutesClients sets `Client` for each route from the account in `set` using `newClient`,
+// creating one client per account, and sets `Upstream` from the account's server URL if unset.
+func NewRoutesClients(set *goauth.CredentialsSet, routes []Route, newClient func(account string) (*http.Client, error)) ([]Route, error) {
+	clients := map[string]*http.Client{}
+	out := []Route{}
+	for _, rt := range routes {
+		creds, err := set.Get(rt.Account)
+		if err != nil {
+			return nil, fmt.Errorf("route (%s): %w", rt.String(), err)
+		}
+		if rt.Upstream == nil {
+			if svrURL, err := creds.ServerURL(); err != nil || strings.TrimSpace(svrURL) == "" {
+				return nil, fmt.Errorf("route has no upstream and account `%s` has no server URL (%s)", rt.Account, rt.String())
+			} else if rt.Upstream, err = ParseUpstream(svrURL); err != nil {
+				return nil, err
+			}
+		}
+		if clt, ok := clients[rt.Account]; ok {
+			rt.Client = clt
+		} else if clt, err := newClient(rt.Account); err != nil {
+			return nil, fmt.Errorf("route (%s): %w", rt.String(), err)
+		} else {
+			clients[rt.Account] = clt
+			rt.Client = clt
+		}
+		out = append(out, rt)
+	}
+	return out, nil
+}
+
+// hostAllowed returns whether the request host is `localhost`, a loopback IP, a route host or
+// in `AllowedHosts`.
+func (p *Proxy) hostAllowed(r *http.Request) bool {
+	host := strings.TrimSuffix(strings.TrimPrefix(requestHost(r), "["), "]")
+	if IsLoopback(host) {
+		return true
+	}
+	for _, rt := range p.Routes {
+		if rt.Host != "" && strings.EqualFold(rt.Host, host) {
+			return true
+		}
+	}
+	for _, allowed := range p.AllowedHosts {
+		if strings.EqualFold(strings.TrimSpace(allowed), host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.hostAllowed(r) {
+		p.log(slog.LevelWarn, "host not allowed", "method", r.Method, "host", r.Host, "path", r.URL.Path)
+		http.Error(w, ErrHostNotAllowed.Error(), http.StatusForbidden)
+		return
+	}
+	best := -1
+	for i, rt := range p.Routes {
+		if rt.Match(r) && (best < 0 || rt.specificity() > p.Routes[best].specificity()) {
+			best = i
+		}
+	}
+	if best < 0 {
+		p.log(slog.LevelWarn, "no route", "method", r.Method, "host", r.Host, "path", r.URL.Path)
+		http.Error(w, ErrNoRoute.Error(), http.StatusNotFound)
+		return
+	}
+	p.proxies[best].ServeHTTP(w, r)
+}
+
+func (p *Proxy) reverseProxy(rt Route) *httputil.ReverseProxy {
+	transport := rt.Client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if p.Logger != nil {
+		transport = &logTransport{next: transport, logger: p.Logger, account: rt.Account}
+	}
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			if rt.StripPrefix && rt.PathPrefix != "" {
+				pr.Out.URL.Path = strings.TrimPrefix(pr.Out.URL.Path, strings.TrimSuffix(rt.PathPrefix, "/"))
+				pr.Out.URL.RawPath = ""
+			}
+			pr.SetURL(rt.Upstream)
+			pr.Out.Header.Del(httputilmore.HeaderAuthorization)
+			pr.Out.Header.Del("Proxy-Authorization")
+		},
+		Transport: transport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			p.log(slog.LevelError, "upstream error", "account", rt.Account, "method", r.Method,
+				"url", RedactURL(r.URL), "error", err.Error())
+			http.Error(w, "upstream error: "+err.Error(), http.StatusBadGateway)
+		},
+	}
+}
+
+func (p *Proxy) log(level slog.Level, msg string, args ...any) {
+	if p.Logger != nil {
+		p.Logger.Log(context.Background(), level, msg, args...)
+	}
+}
+
+// logTransport logs requests before the account's credentials are added, and responses.
+type logTransport struct {
+	next    http.RoundTripper
+	logger  *slog.Logger
+	account string
+}
+
+func (t *logTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqURL, reqHeader := RedactURL(req.URL), RedactHeader(req.Header)
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	t.logger.LogAttrs(req.Context(), slog.LevelInfo, "request",
+		slog.String("account", t.account),
+		slog.String("method", req.Method),
+		slog.String("url", reqURL),
+		slog.Any("requestHeader", reqHeader),
+		slog.Int("status", resp.StatusCode),
+		slog.Any("responseHeader", RedactHeader(resp.Header)),
+		slog.Duration("duration", time.Since(start)))
+	return resp, nil
+}
+
+// isSensitive returns whether values for a header or query parameter name are redacted.
+func isSensitive(name string) bool {
+	for _, h := range sensitiveHeaders {
+		if strings.EqualFold(name, h) {
+			return true
+		}
+	}
+	name = strings.ToLower(name)
+	for _, part := range sensitiveNameParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactHeader returns a copy of `h` with credential and other sensitive values replaced
+// by `goauth.RedactedValue`.
+func RedactHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for k, vals := range out {
+		if isSensitive(k) {
+			for i := range vals {
+				vals[i] = goauth.RedactedValue
+			}
+		}
+	}
+	return out
+}
+
+// RedactURL returns `u` as a string with sensitive query parameter values and user info
+// passwords replaced by `goauth.RedactedValue`.
+func RedactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	out := *u
+	if _, ok := out.User.Password(); ok {
+		out.User = url.UserPassword(out.User.Username(), goauth.RedactedValue)
+	}
+	qry := out.Query()
+	for k, vals := range qry {
+		if isSensitive(k) {
+			for i := range vals {
+				vals[i] = goauth.RedactedValue
+			}
+		}
+	}
+	out.RawQuery = strings.ReplaceAll(qry.Encode(), url.QueryEscape(goauth.RedactedValue), goauth.RedactedValue)
+	return out.String()
+}
+
+// IsLoopback returns whether a listen address only accepts connections from this host.
+func IsLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,117 @@
+package authproxy
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grokify/goauth/authutil"
+)
+
+var routeTests = []struct {
+	route   string
+	host    string
+	path    string
+	wantErr bool
+}{
+	{"/github=github", "", "/github", false},
+	{"api.local=crm", "api.local", "", false},
+	{"api.local/v2=crm@https://api.example.com/v2", "api.local", "/v2", false},
+	{"/crm=", "", "", true},
+	{"/crm=crm@ftp://example.com", "", "", true},
+}
+
+func TestParseRoute(t *testing.T) {
+	for _, tt := range routeTests {
+		rt, err := ParseRoute(tt.route)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseRoute(%s): want error, got nil", tt.route)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("ParseRoute(%s): err [%v]", tt.route, err)
+		} else if rt.Host != tt.host || rt.PathPrefix != tt.path {
+			t.Errorf("ParseRoute(%s): want [%s, %s], got [%s, %s]", tt.route, tt.host, tt.path, rt.Host, rt.PathPrefix)
+		} else if rt.String() != tt.route {
+			t.Errorf("Route.String(%s): want [%s], got [%s]", tt.route, tt.route, rt.String())
+		}
+	}
+}
+
+var proxyTests = []struct {
+	host       string
+	path       string
+	wantStatus int
+	wantBody   string
+}{
+	{"localhost", "/a/users?api_key=abc", http.StatusOK, "Bearer tokA /users"},
+	{"localhost", "/a", http.StatusOK, "Bearer tokA /"},
+	{"localhost", "/ab", http.StatusNotFound, ""},
+	{"b.local", "/a/users", http.StatusOK, "Bearer tokB /v1/a/users"},
+	{"localhost", "/c", http.StatusNotFound, ""},
+	{"127.0.0.1:9000", "/a", http.StatusOK, "Bearer tokA /"},
+	{"[::1]:9000", "/a", http.StatusOK, "Bearer tokA /"},
+	{"rebind.example.com", "/a/users", http.StatusForbidden, ""},
+	{"rebind.example.com:9000", "/a/users", http.StatusForbidden, ""},
+}
+
+func TestProxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret-cookie")
+		io.WriteString(w, r.Header.Get("Authorization")+" "+r.URL.Path)
+	}))
+	defer upstream.Close()
+
+	var routes []Route
+	for _, spec := range []struct{ route, token string }{
+		{"/a=a@" + upstream.URL, "tokA"},
+		{"b.local=b@" + upstream.URL + "/v1", "tokB"},
+	} {
+		rt, err := ParseRoute(spec.route)
+		if err != nil {
+			t.Fatalf("ParseRoute(%s): err [%v]", spec.route, err)
+		}
+		rt.Client = authutil.NewClientToken(authutil.TokenBearer, spec.token, false)
+		routes = append(routes, rt)
+	}
+	var logs bytes.Buffer
+	p, err := New(routes, slog.New(slog.NewTextHandler(&logs, nil)))
+	if err != nil {
+		t.Fatalf("New(): err [%v]", err)
+	}
+	svr := httptest.NewServer(p)
+	defer svr.Close()
+
+	for _, tt := range proxyTests {
+		req, err := http.NewRequest(http.MethodGet, svr.URL+tt.path, nil)
+		if err != nil {
+			t.Fatalf("http.NewRequest(%s): err [%v]", tt.path, err)
+		}
+		req.Host = tt.host
+		req.Header.Set("Authorization", "Bearer inbound")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Proxy(%s%s): err [%v]", tt.host, tt.path, err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("Proxy(%s%s): want status [%d], got [%d]", tt.host, tt.path, tt.wantStatus, resp.StatusCode)
+		} else if tt.wantStatus == http.StatusOK && string(b) != tt.wantBody {
+			t.Errorf("Proxy(%s%s): want body [%s], got [%s]", tt.host, tt.path, tt.wantBody, string(b))
+		}
+	}
+	if !strings.Contains(logs.String(), "account=b") {
+		t.Errorf("Proxy logs: want request logged, got [%s]", logs.String())
+	}
+	for _, secret := range []string{"tokA", "tokB", "inbound", "secret-cookie", "abc"} {
+		if strings.Contains(logs.String(), secret) {
+			t.Errorf("Proxy logs: want [%s] redacted, got [%s]", secret, logs.String())
+		}
+	}
+}
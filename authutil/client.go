@@ -24,18 +24,30 @@ func NewClientPassword(conf oauth2.Config, ctx context.Context, username, passwo
 }
 
 func NewClientPasswordConf(conf oauth2.Config, username, password string) (*http.Client, error) {
-	if token, err := conf.PasswordCredentialsToken(context.Background(), username, password); err != nil {
+	return NewClientPasswordConfContext(context.Background(), conf, username, password)
+}
+
+// NewClientPasswordConfContext is like `NewClientPasswordConf()` but uses the `*http.Client`
+// in the `oauth2.HTTPClient` context key for the token request and as the base transport.
+func NewClientPasswordConfContext(ctx context.Context, conf oauth2.Config, username, password string) (*http.Client, error) {
+	if token, err := conf.PasswordCredentialsToken(ctx, username, password); err != nil {
 		return &http.Client{}, err
 	} else {
-		return conf.Client(context.Background(), token), nil
+		return conf.Client(ctx, token), nil
 	}
 }
 
 func NewClientAuthCode(conf oauth2.Config, authCode string) (*http.Client, error) {
-	if token, err := conf.Exchange(context.Background(), authCode); err != nil {
+	return NewClientAuthCodeContext(context.Background(), conf, authCode)
+}
+
+// NewClientAuthCodeContext is like `NewClientAuthCode()` but uses the `*http.Client`
+// in the `oauth2.HTTPClient` context key for the token request and as the base transport.
+func NewClientAuthCodeContext(ctx context.Context, conf oauth2.Config, authCode string) (*http.Client, error) {
+	if token, err := conf.Exchange(ctx, authCode); err != nil {
 		return &http.Client{}, err
 	} else {
-		return conf.Client(context.Background(), token), nil
+		return conf.Client(ctx, token), nil
 	}
 }
 
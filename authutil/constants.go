@@ -16,8 +16,13 @@ const (
 	ParamPassword     = "password"
 	ParamRefreshToken = "refresh_token"
 	ParamScope        = "scope"
+	ParamToken        = "token"
+	ParamTokenHint    = "token_type_hint"
 	ParamUsername     = "usernamae"
 
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token" // #nosec G101
+
 	TokenBasic  = "Basic"
 	TokenBearer = "Bearer"
 
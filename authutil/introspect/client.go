@@ -0,0 +1,53 @@
+package introspect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/mogo/net/http/httpsimple"
+	"github.com/grokify/mogo/net/http/httputilmore"
+)
+
+// Introspect calls an RFC 7662 token introspection endpoint. Client credentials, if any,
+// are sent using HTTP Basic authentication.
+func Introspect(ctx context.Context, introspectionURL, clientID, clientSecret, token, tokenTypeHint string) (*IntrospectResponse, error) {
+	if strings.TrimSpace(introspectionURL) == "" {
+		return nil, errors.New("introspection url is required")
+	}
+	body := url.Values{authutil.ParamToken: {token}}
+	if tokenTypeHint != "" {
+		body.Set(authutil.ParamTokenHint, tokenTypeHint)
+	}
+	req := httpsimple.Request{
+		Method:   http.MethodPost,
+		URL:      introspectionURL,
+		Headers:  http.Header{},
+		Body:     body,
+		BodyType: httpsimple.BodyTypeForm}
+	if clientID != "" || clientSecret != "" {
+		if hdr, err := authutil.BasicAuthHeader(clientID, clientSecret); err != nil {
+			return nil, err
+		} else {
+			req.Headers.Set(httputilmore.HeaderAuthorization, hdr)
+		}
+	}
+	resp, err := req.Do(ctx, authutil.ContextClient(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	} else if resp.StatusCode >= 300 {
+		return nil, &authutil.ResponseError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+	ir := &IntrospectResponse{}
+	return ir, json.Unmarshal(b, ir)
+}
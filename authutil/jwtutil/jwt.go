@@ -68,7 +68,7 @@ func NewTokenOAuth2JWT(ctx context.Context, tokenURL, clientID, clientSecret, jw
 	}
 	if hreq, err := sreq.HTTPRequest(ctx); err != nil {
 		return nil, errorsutil.WrapWithLocation(err)
-	} else if resp, err := ctxhttp.Do(ctx, &http.Client{}, hreq); err != nil {
+	} else if resp, err := ctxhttp.Do(ctx, authutil.ContextClient(ctx), hreq); err != nil {
 		return nil, errorsutil.WrapWithLocation(err)
 	} else if resp.StatusCode >= 300 {
 		return nil, fmt.Errorf("tokenURL (httpResStatus: %d) %s", resp.StatusCode, string(jsonutil.MustMarshal(
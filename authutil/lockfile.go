@@ -0,0 +1,49 @@
+package authutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// lockFilePollInterval is how often a lock held by another process is retried.
+const lockFilePollInterval = 25 * time.Millisecond
+
+// LockFile acquires an exclusive lock for `path` like `LockFileContext()`, waiting up to
+// `TokenCacheLockTimeout`.
+func LockFile(path string) (func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TokenCacheLockTimeout)
+	defer cancel()
+	return LockFileContext(ctx, path)
+}
+
+// LockFileContext acquires an exclusive lock for `path` with an OS file lock on a `.lock`
+// file, waiting until `ctx` is done. It returns a function which releases the lock. The OS
+// releases the lock if the process exits, so an abandoned lock never has to be removed and a
+// slow holder never loses it. The lock file is left in place, since removing it would let
+// another process lock a file which is no longer linked.
+func LockFileContext(ctx context.Context, path string) (func(), error) {
+	lock := path + ".lock"
+	f, err := os.OpenFile(lock, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if ok, err := tryLockFile(f); err != nil {
+			f.Close()
+			return nil, err
+		} else if ok {
+			return func() {
+				_ = unlockFile(f)
+				f.Close()
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, fmt.Errorf("waiting for lock (%s): %w", lock, ctx.Err())
+		case <-time.After(lockFilePollInterval):
+		}
+	}
+}
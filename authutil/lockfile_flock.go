@@ -0,0 +1,23 @@
+//go:build unix && !aix && !solaris
+
+package authutil
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockFile acquires an exclusive `flock` on `f` without waiting. It returns false if
+// another open file holds the lock.
+func tryLockFile(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB) // #nosec G115
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN) // #nosec G115
+}
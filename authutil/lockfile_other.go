@@ -0,0 +1,22 @@
+//go:build (!unix && !windows) || aix || solaris
+
+package authutil
+
+import (
+	"os"
+	"sync"
+)
+
+// heldLockFiles are the lock files held by this process. `flock` is not supported on this
+// platform, so locks only exclude other goroutines.
+var heldLockFiles sync.Map
+
+func tryLockFile(f *os.File) (bool, error) {
+	_, held := heldLockFiles.LoadOrStore(f.Name(), struct{}{})
+	return !held, nil
+}
+
+func unlockFile(f *os.File) error {
+	heldLockFiles.Delete(f.Name())
+	return nil
+}
@@ -0,0 +1,53 @@
+package authutil
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFileContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	unlock, err := LockFileContext(context.Background(), path)
+	if err != nil {
+		t.Fatalf("LockFileContext(): err [%v]", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := LockFileContext(ctx, path); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("LockFileContext() while held: want [%v], got [%v]", context.DeadlineExceeded, err)
+	}
+
+	// A waiting lock is acquired once the holder releases it.
+	acquired := make(chan error, 1)
+	go func() {
+		unlock2, err := LockFileContext(context.Background(), path)
+		if err == nil {
+			unlock2()
+		}
+		acquired <- err
+	}()
+	time.Sleep(2 * lockFilePollInterval)
+	unlock()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Errorf("LockFileContext() after unlock: err [%v]", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("LockFileContext() after unlock: want lock, got timeout")
+	}
+
+	// A lock file left by a process which exited is not locked.
+	if _, err := os.Stat(path + ".lock"); err != nil {
+		t.Errorf("LockFileContext(): want lock file kept, got err [%v]", err)
+	}
+	if unlock, err := LockFile(path); err != nil {
+		t.Errorf("LockFile() with existing lock file: err [%v]", err)
+	} else {
+		unlock()
+	}
+}
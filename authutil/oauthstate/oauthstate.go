@@ -0,0 +1,343 @@
+// Package oauthstate issues and verifies OAuth 2.0 `state` values which protect authorization
+// code flows against CSRF and replay. States are signed with HMAC-SHA256, expire, and can be
+// used once. They carry the provider key, return URL, PKCE verifier, OpenID Connect nonce and
+// application data.
+//
+// Without a `Manager.Store`, the state content is signed but not encrypted, so the return URL
+// and application data can be read by the user, the provider and anything which logs the
+// authorization URL. Use a `Store` for data which must not be disclosed.
+package oauthstate
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// DefaultTTL is how long a state is valid, i.e. how long a user has to authorize.
+	DefaultTTL = 10 * time.Minute
+	// DefaultCookieName is the cookie used by `Manager.SetCookie()`.
+	DefaultCookieName = "goauth_state"
+	// ParamState is the query parameter for the state in authorization responses.
+	ParamState = "state"
+
+	minKeyLength = 32
+)
+
+var (
+	// ErrInvalidState is returned, or wrapped, when a state is malformed, not signed with the
+	// manager's key, expired, already used or not bound to the request.
+	ErrInvalidState = errors.New("invalid oauth2 state")
+	// ErrExpired is wrapped with `ErrInvalidState` for expired states.
+	ErrExpired = errors.New("state expired")
+	// ErrUsed is wrapped with `ErrInvalidState` for states which have already been verified.
+	ErrUsed = errors.New("state already used")
+	// ErrNotFound is returned by `Store.Take()` when there is no state for an ID.
+	ErrNotFound = errors.New("state not found")
+	// ErrNonceMismatch is wrapped with `ErrInvalidState` when an ID token's `nonce` claim does
+	// not match the state.
+	ErrNonceMismatch = errors.New("id_token nonce mismatch")
+)
+
+// State is the content of an issued state value. `ID`, `Verifier`, `ExpiresAt` and, if empty,
+// `Nonce` are set by `Manager.Issue()`. Without a `Manager.Store`, `Provider`, `ReturnURL`,
+// `Nonce` and `Data` are readable in the state value.
+type State struct {
+	ID        string            `json:"id"`
+	Provider  string            `json:"provider,omitempty"`
+	ReturnURL string            `json:"returnUrl,omitempty"`
+	Nonce     string            `json:"nonce,omitempty"`
+	Verifier  string            `json:"verifier,omitempty"`
+	Data      map[string]string `json:"data,omitempty"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+// signedState is the payload of a state value when there is no `Manager.Store`. The PKCE
+// verifier is derived from the ID so it is not sent to the browser.
+type signedState struct {
+	ID        string            `json:"i"`
+	Provider  string            `json:"p,omitempty"`
+	ReturnURL string            `json:"r,omitempty"`
+	Nonce     string            `json:"n,omitempty"`
+	Data      map[string]string `json:"d,omitempty"`
+	ExpiresAt int64             `json:"e"`
+}
+
+// Store stores issued states server-side. `Take()` must get and remove a state atomically so
+// it can be used once, e.g. with Redis `GETDEL`.
+type Store interface {
+	Put(ctx context.Context, st *State) error
+	Take(ctx context.Context, id string) (*State, error)
+}
+
+// Manager issues and verifies states. Without a `Store`, the state value is the signed, but
+// not encrypted, state content, and used states are remembered in memory until they expire, so
+// single use is only enforced within the process. With a `Store`, the state value is a signed
+// ID, and the content is kept in the store, which enforces single use across processes and
+// keeps `ReturnURL` and `Data` private.
+type Manager struct {
+	// Key signs state values and derives PKCE verifiers, and must be at least 32 bytes.
+	Key []byte
+	// TTL defaults to `DefaultTTL`.
+	TTL   time.Duration
+	Store Store
+
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+// NewManager returns a manager which signs states with `key`.
+func NewManager(key []byte) (*Manager, error) {
+	if len(key) < minKeyLength {
+		return nil, fmt.Errorf("state key must be at least [%d] bytes", minKeyLength)
+	}
+	return &Manager{Key: key}, nil
+}
+
+// Random returns a random value, e.g. for a state which is not verified, as when the
+// authorization code is pasted on the command line.
+func Random() string { return rand.Text() }
+
+func (m *Manager) ttl() time.Duration {
+	if m.TTL > 0 {
+		return m.TTL
+	}
+	return DefaultTTL
+}
+
+// Issue sets the state ID, expiry, PKCE verifier and a nonce if empty, and returns the state
+// value for the authorization URL.
+func (m *Manager) Issue(ctx context.Context, st *State) (string, error) {
+	if len(m.Key) < minKeyLength {
+		return "", fmt.Errorf("state key must be at least [%d] bytes", minKeyLength)
+	} else if st == nil {
+		st = &State{}
+	}
+	st.ID = rand.Text()
+	st.ExpiresAt = time.Now().Add(m.ttl()).UTC()
+	if st.Nonce == "" {
+		st.Nonce = rand.Text()
+	}
+	if m.Store != nil {
+		st.Verifier = rand.Text() + rand.Text()
+		if err := m.Store.Put(ctx, st); err != nil {
+			return "", err
+		}
+		return m.sign(st.ID), nil
+	}
+	st.Verifier = m.verifier(st.ID)
+	b, err := json.Marshal(signedState{
+		ID:        st.ID,
+		Provider:  st.Provider,
+		ReturnURL: st.ReturnURL,
+		Nonce:     st.Nonce,
+		Data:      st.Data,
+		ExpiresAt: st.ExpiresAt.Unix()})
+	if err != nil {
+		return "", err
+	}
+	return m.sign(base64.RawURLEncoding.EncodeToString(b)), nil
+}
+
+// Verify returns the state for a state value if it is signed with the manager's key, has not
+// expired and has not been verified before.
+func (m *Manager) Verify(ctx context.Context, value string) (*State, error) {
+	payload, err := m.open(value)
+	if err != nil {
+		return nil, err
+	}
+	if m.Store != nil {
+		st, err := m.Store.Take(ctx, payload)
+		if errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidState, ErrUsed)
+		} else if err != nil {
+			return nil, err
+		} else if !time.Now().Before(st.ExpiresAt) {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidState, ErrExpired)
+		}
+		return st, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidState, err)
+	}
+	ss := signedState{}
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidState, err)
+	}
+	st := &State{
+		ID:        ss.ID,
+		Provider:  ss.Provider,
+		ReturnURL: ss.ReturnURL,
+		Nonce:     ss.Nonce,
+		Verifier:  m.verifier(ss.ID),
+		Data:      ss.Data,
+		ExpiresAt: time.Unix(ss.ExpiresAt, 0).UTC()}
+	if !time.Now().Before(st.ExpiresAt) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidState, ErrExpired)
+	} else if !m.use(st.ID, st.ExpiresAt) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidState, ErrUsed)
+	}
+	return st, nil
+}
+
+// VerifyNonce checks that the `nonce` claim of an OpenID Connect ID token matches the state
+// nonce, so an ID token issued for another authorization request is rejected. The token
+// signature is not verified. This is sufficient for an ID token received directly from the
+// token endpoint over TLS, per OpenID Connect Core section 3.1.3.7.
+func (st *State) VerifyNonce(idToken string) error {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(idToken, claims); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidState, err)
+	}
+	nonce, _ := claims["nonce"].(string)
+	if st.Nonce == "" || !hmac.Equal([]byte(nonce), []byte(st.Nonce)) {
+		return fmt.Errorf("%w: %w", ErrInvalidState, ErrNonceMismatch)
+	}
+	return nil
+}
+
+// use records that a state has been verified, returning false if it already was.
+func (m *Manager) use(id string, expiresAt time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for usedID, exp := range m.used {
+		if !now.Before(exp) {
+			delete(m.used, usedID)
+		}
+	}
+	if _, ok := m.used[id]; ok {
+		return false
+	} else if m.used == nil {
+		m.used = map[string]time.Time{}
+	}
+	m.used[id] = expiresAt
+	return true
+}
+
+// sign returns `payload` and its HMAC, separated by `.`.
+func (m *Manager) sign(payload string) string {
+	return payload + "." + base64.RawURLEncoding.EncodeToString(m.mac("state", payload))
+}
+
+// open returns the payload of a signed value.
+func (m *Manager) open(value string) (string, error) {
+	payload, sig, ok := strings.Cut(value, ".")
+	if !ok || payload == "" {
+		return "", fmt.Errorf("%w: malformed", ErrInvalidState)
+	} else if got, err := base64.RawURLEncoding.DecodeString(sig); err != nil || !hmac.Equal(got, m.mac("state", payload)) {
+		return "", fmt.Errorf("%w: bad signature", ErrInvalidState)
+	}
+	return payload, nil
+}
+
+// verifier returns the PKCE verifier for a state ID, which is 43 characters as required by
+// RFC 7636.
+func (m *Manager) verifier(id string) string {
+	return base64.RawURLEncoding.EncodeToString(m.mac("verifier", id))
+}
+
+func (m *Manager) mac(purpose, data string) []byte {
+	h := hmac.New(sha256.New, m.Key)
+	h.Write([]byte(purpose + "\x00" + data))
+	return h.Sum(nil)
+}
+
+// CookieOptions configures the cookie which binds a state to the browser.
+type CookieOptions struct {
+	// Name defaults to `DefaultCookieName`.
+	Name string
+	// Path should be the callback path, so the cookie is only sent there.
+	Path   string
+	Secure bool
+}
+
+func (opts CookieOptions) name() string {
+	if opts.Name != "" {
+		return opts.Name
+	}
+	return DefaultCookieName
+}
+
+// SetCookie sets an HTTP-only cookie with the state value, so `VerifyRequest()` only accepts
+// the state in the browser which started the flow.
+func (m *Manager) SetCookie(w http.ResponseWriter, value string, opts CookieOptions) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     opts.name(),
+		Value:    value,
+		Path:     opts.Path,
+		MaxAge:   int(m.ttl().Seconds()),
+		HttpOnly: true,
+		Secure:   opts.Secure,
+		// Lax is required for the cookie to be sent on the redirect back from the provider.
+		SameSite: http.SameSiteLaxMode})
+}
+
+// VerifyRequest verifies the `state` query parameter of a callback request, which must match
+// the cookie set by `SetCookie()`. The cookie is cleared.
+func (m *Manager) VerifyRequest(w http.ResponseWriter, r *http.Request, opts CookieOptions) (*State, error) {
+	value := r.URL.Query().Get(ParamState)
+	cookie, err := r.Cookie(opts.name())
+	ClearCookie(w, opts)
+	if err != nil || !hmac.Equal([]byte(cookie.Value), []byte(value)) {
+		return nil, fmt.Errorf("%w: state not issued to this browser", ErrInvalidState)
+	}
+	return m.Verify(r.Context(), value)
+}
+
+// ClearCookie removes the cookie set by `SetCookie()`, e.g. when the provider returns an error
+// instead of a code.
+func ClearCookie(w http.ResponseWriter, opts CookieOptions) {
+	http.SetCookie(w, &http.Cookie{Name: opts.name(), Path: opts.Path, MaxAge: -1, HttpOnly: true, Secure: opts.Secure})
+}
+
+// MemoryStore is a `Store` for a single process.
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]*State
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: map[string]*State{}}
+}
+
+func (s *MemoryStore) Put(ctx context.Context, st *State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, cur := range s.states {
+		if !now.Before(cur.ExpiresAt) {
+			delete(s.states, id)
+		}
+	}
+	if s.states == nil {
+		s.states = map[string]*State{}
+	}
+	cp := *st
+	s.states[st.ID] = &cp
+	return nil
+}
+
+func (s *MemoryStore) Take(ctx context.Context, id string) (*State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.states[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(s.states, id)
+	return st, nil
+}
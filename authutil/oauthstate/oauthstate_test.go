@@ -0,0 +1,120 @@
+package oauthstate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	key := bytes.Repeat([]byte("k"), 32)
+	tests := []struct {
+		name  string
+		store Store
+	}{
+		{"signed", nil},
+		{"stored", NewMemoryStore()},
+	}
+	for _, tt := range tests {
+		m := &Manager{Key: key, Store: tt.store}
+		st := &State{Provider: "google", ReturnURL: "/done", Data: map[string]string{"tenant": "t1"}}
+		value, err := m.Issue(ctx, st)
+		if err != nil {
+			t.Fatalf("Manager.Issue(%s): err [%v]", tt.name, err)
+		} else if len(st.Verifier) < 43 || st.Nonce == "" || st.ID == "" {
+			t.Errorf("Manager.Issue(%s): want id, nonce and verifier, got [%v]", tt.name, st)
+		}
+		got, err := m.Verify(ctx, value)
+		if err != nil {
+			t.Fatalf("Manager.Verify(%s): err [%v]", tt.name, err)
+		} else if got.Provider != "google" || got.ReturnURL != "/done" || got.Data["tenant"] != "t1" ||
+			got.Nonce != st.Nonce || got.Verifier != st.Verifier || got.ExpiresAt.Unix() != st.ExpiresAt.Unix() {
+			t.Errorf("Manager.Verify(%s): want [%v], got [%v]", tt.name, st, got)
+		}
+		if _, err := m.Verify(ctx, value); !errors.Is(err, ErrUsed) {
+			t.Errorf("Manager.Verify(%s) again: want [%v], got [%v]", tt.name, ErrUsed, err)
+		}
+		if _, err := (&Manager{Key: bytes.Repeat([]byte("x"), 32), Store: tt.store}).Verify(ctx, value); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("Manager.Verify(%s) other key: want [%v], got [%v]", tt.name, ErrInvalidState, err)
+		}
+		m.TTL = time.Nanosecond
+		if value, err := m.Issue(ctx, &State{}); err != nil {
+			t.Fatalf("Manager.Issue(%s): err [%v]", tt.name, err)
+		} else if _, err := m.Verify(ctx, value); !errors.Is(err, ErrExpired) {
+			t.Errorf("Manager.Verify(%s) expired: want [%v], got [%v]", tt.name, ErrExpired, err)
+		}
+	}
+	if _, err := NewManager([]byte("short")); err == nil {
+		t.Errorf("NewManager(short): want error, got nil")
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	m, err := NewManager(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatalf("NewManager(): err [%v]", err)
+	}
+	opts := CookieOptions{Path: "/callback"}
+	newRequest := func(withCookie bool) (*http.Request, error) {
+		value, err := m.Issue(context.Background(), &State{})
+		if err != nil {
+			return nil, err
+		}
+		rec := httptest.NewRecorder()
+		m.SetCookie(rec, value, opts)
+		r := httptest.NewRequest(http.MethodGet, "/callback?state="+url.QueryEscape(value), nil)
+		if withCookie {
+			for _, c := range rec.Result().Cookies() {
+				r.AddCookie(c)
+			}
+		}
+		return r, nil
+	}
+	if r, err := newRequest(true); err != nil {
+		t.Fatalf("Manager.Issue(): err [%v]", err)
+	} else if _, err := m.VerifyRequest(httptest.NewRecorder(), r, opts); err != nil {
+		t.Errorf("Manager.VerifyRequest(): err [%v]", err)
+	}
+	if r, err := newRequest(false); err != nil {
+		t.Fatalf("Manager.Issue(): err [%v]", err)
+	} else if _, err := m.VerifyRequest(httptest.NewRecorder(), r, opts); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Manager.VerifyRequest() without cookie: want [%v], got [%v]", ErrInvalidState, err)
+	}
+}
+
+func TestVerifyNonce(t *testing.T) {
+	idToken := func(claims jwt.MapClaims) string {
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("provider-key"))
+		if err != nil {
+			t.Fatalf("jwt.Token.SignedString(): err [%v]", err)
+		}
+		return s
+	}
+	tests := []struct {
+		nonce   string
+		idToken string
+		wantErr error
+	}{
+		{"n1", idToken(jwt.MapClaims{"nonce": "n1"}), nil},
+		{"n1", idToken(jwt.MapClaims{"nonce": "n2"}), ErrNonceMismatch},
+		{"n1", idToken(jwt.MapClaims{"sub": "123"}), ErrNonceMismatch},
+		{"", idToken(jwt.MapClaims{"sub": "123"}), ErrNonceMismatch},
+		{"n1", "not-a-jwt", ErrInvalidState},
+	}
+	for _, tt := range tests {
+		err := (&State{Nonce: tt.nonce}).VerifyNonce(tt.idToken)
+		if tt.wantErr == nil && err != nil {
+			t.Errorf("State.VerifyNonce(%s): want [nil], got [%v]", tt.nonce, err)
+		} else if tt.wantErr != nil && (!errors.Is(err, tt.wantErr) || !errors.Is(err, ErrInvalidState)) {
+			t.Errorf("State.VerifyNonce(%s): want [%v], got [%v]", tt.nonce, tt.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package authutil
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/grokify/mogo/net/http/httputilmore"
+	"golang.org/x/oauth2"
+)
+
+var ErrTransportNotResolvable = errors.New("client transport cannot be resolved")
+
+// ResolveRequest applies the credentials added by the `*http.Client` transport
+// chain to `req` and returns the resulting request without sending it over the
+// network. It supports the transports created by this module, namely
+// `httputilmore.TransportRequestModifier` and `*oauth2.Transport`. Note: an
+// `*oauth2.Transport` may request or refresh a token to resolve the request.
+func ResolveRequest(client *http.Client, req *http.Request) (*http.Request, error) {
+	if req == nil {
+		return nil, errors.New("request must not be nil")
+	}
+	var xport http.RoundTripper
+	if client != nil {
+		xport = client.Transport
+	}
+	capture := &captureTransport{}
+	if rt, err := replaceLeafTransport(xport, capture); err != nil {
+		return nil, err
+	} else if resp, err := rt.RoundTrip(req.Clone(req.Context())); err != nil {
+		return nil, err
+	} else {
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+		if capture.req == nil {
+			return nil, ErrTransportNotResolvable
+		}
+		return capture.req, nil
+	}
+}
+
+// replaceLeafTransport rebuilds the transport chain with `leaf` in place of the
+// network transport.
+func replaceLeafTransport(xport, leaf http.RoundTripper) (http.RoundTripper, error) {
+	switch t := xport.(type) {
+	case nil:
+		return leaf, nil
+	case *http.Transport:
+		return leaf, nil
+	case httputilmore.TransportRequestModifier:
+		if inner, err := replaceLeafTransport(t.Transport, leaf); err != nil {
+			return nil, err
+		} else {
+			t.Transport = inner
+			return t, nil
+		}
+	case *httputilmore.TransportRequestModifier:
+		if inner, err := replaceLeafTransport(t.Transport, leaf); err != nil {
+			return nil, err
+		} else {
+			t2 := *t
+			t2.Transport = inner
+			return t2, nil
+		}
+	case *oauth2.Transport:
+		if inner, err := replaceLeafTransport(t.Base, leaf); err != nil {
+			return nil, err
+		} else {
+			return &oauth2.Transport{Source: t.Source, Base: inner}, nil
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported transport type (%T)", ErrTransportNotResolvable, xport)
+	}
+}
+
+// captureTransport records the outbound request instead of sending it.
+type captureTransport struct {
+	req *http.Request
+}
+
+func (ct *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ct.req = req
+	return &http.Response{
+		Status:     http.StatusText(http.StatusOK),
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req}, nil
+}
@@ -0,0 +1,58 @@
+package authutil
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+type noopTransport struct{}
+
+func (noopTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("request must not be sent")
+}
+
+var resolveRequestTests = []struct {
+	client    *http.Client
+	header    string
+	wantValue string
+	wantQuery string
+}{
+	{NewClientToken(TokenBearer, "abc", false), "Authorization", "Bearer abc", "page=2"},
+	{NewClientHeaderQuery(http.Header{"X-Api-Key": []string{"def"}}, url.Values{"api_key": []string{"ghi"}}, false), "X-Api-Key", "def", "api_key=ghi&page=2"},
+	{&http.Client{}, "Authorization", "", "page=2"},
+}
+
+func TestResolveRequest(t *testing.T) {
+	for _, tt := range resolveRequestTests {
+		req, err := http.NewRequest(http.MethodGet, "https://api.example.com/v1?page=2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ResolveRequest(tt.client, req)
+		if err != nil {
+			t.Fatalf("ResolveRequest(%T): err [%v]", tt.client.Transport, err)
+		}
+		if v := got.Header.Get(tt.header); v != tt.wantValue {
+			t.Errorf("ResolveRequest(%T) %s: want [%s], got [%s]", tt.client.Transport, tt.header, tt.wantValue, v)
+		}
+		if q := got.URL.Query().Encode(); q != tt.wantQuery {
+			t.Errorf("ResolveRequest(%T) query: want [%s], got [%s]", tt.client.Transport, tt.wantQuery, q)
+		}
+		if len(req.Header) != 0 || req.URL.RawQuery != "page=2" {
+			t.Errorf("ResolveRequest(%T): want request unchanged, got header [%v] query [%s]", tt.client.Transport, req.Header, req.URL.RawQuery)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://api.example.com/v1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ResolveRequest(&http.Client{Transport: noopTransport{}}, req); !errors.Is(err, ErrTransportNotResolvable) {
+		t.Errorf("ResolveRequest(noopTransport): want [%v], got [%v]", ErrTransportNotResolvable, err)
+	}
+	if _, err := ResolveRequest(nil, nil); err == nil {
+		t.Errorf("ResolveRequest(nil, nil): want err, got nil")
+	}
+}
@@ -0,0 +1,59 @@
+package authutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/grokify/mogo/net/http/httpsimple"
+	"github.com/grokify/mogo/net/http/httputilmore"
+)
+
+// RevokeToken revokes an access or refresh token using the RFC 7009 token revocation
+// endpoint. `tokenTypeHint` is optional, e.g. `TokenTypeHintRefreshToken`. Client
+// credentials, if any, are sent using HTTP Basic authentication.
+func RevokeToken(ctx context.Context, revocationURL, clientID, clientSecret, token, tokenTypeHint string) error {
+	if strings.TrimSpace(revocationURL) == "" {
+		return fmt.Errorf("revocation url is required")
+	}
+	body := url.Values{ParamToken: {token}}
+	if tokenTypeHint != "" {
+		body.Set(ParamTokenHint, tokenTypeHint)
+	}
+	req := httpsimple.Request{
+		Method:   http.MethodPost,
+		URL:      revocationURL,
+		Headers:  http.Header{},
+		Body:     body,
+		BodyType: httpsimple.BodyTypeForm}
+	if clientID != "" || clientSecret != "" {
+		if hdr, err := BasicAuthHeader(clientID, clientSecret); err != nil {
+			return err
+		} else {
+			req.Headers.Set(httputilmore.HeaderAuthorization, hdr)
+		}
+	}
+	resp, err := req.Do(ctx, ContextClient(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(resp.Body)
+		return &ResponseError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+	return nil
+}
+
+// ResponseError is returned for an unsuccessful authorization server response.
+type ResponseError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("authorization server error (httpResStatus: %d) %s", e.StatusCode, strings.TrimSpace(e.Body))
+}
@@ -82,12 +82,19 @@ func NewTokenCLIFromWeb(ctx context.Context, cfg *oauth2.Config, state string) (
 // the issue is encoding the HTTP request body. The approach here uses `&` in the
 // URL encoded values.
 func TokenClientCredentials(cfg clientcredentials.Config) (*oauth2.Token, error) {
+	return TokenClientCredentialsContext(context.Background(), cfg)
+}
+
+// TokenClientCredentialsContext is like `TokenClientCredentials()` but uses the context and
+// the `*http.Client` from `ContextClient()`.
+func TokenClientCredentialsContext(ctx context.Context, cfg clientcredentials.Config) (*oauth2.Token, error) {
 	body := url.Values{}
 	body.Add(ParamGrantType, GrantTypeClientCredentials)
 	for _, scope := range cfg.Scopes {
 		body.Add(ParamScope, scope)
 	}
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodPost,
 		cfg.TokenURL,
 		strings.NewReader(body.Encode()))
@@ -101,8 +108,7 @@ func TokenClientCredentials(cfg clientcredentials.Config) (*oauth2.Token, error)
 	req.Header.Add(httputilmore.HeaderContentType, httputilmore.ContentTypeAppFormURLEncoded)
 	req.Header.Add(httputilmore.HeaderAuthorization, TokenBasic+" "+b64)
 
-	client := http.Client{}
-	resp, err := client.Do(req)
+	resp, err := ContextClient(ctx).Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,220 @@
+package authutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const tokenCacheExt = ".json"
+
+var (
+	// TokenCacheLockTimeout is how long `TokenCache` waits for another process to release a lock.
+	TokenCacheLockTimeout = 10 * time.Second
+
+	rxTokenCacheUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+)
+
+// TokenCache is an on-disk token cache with one file per key. Files are written with
+// `0600` permissions using a temporary file and rename, guarded by a lock file so
+// concurrent processes do not interleave writes.
+type TokenCache struct {
+	Dir string
+}
+
+// DefaultTokenCacheDir returns `goauth/tokens` under the user cache directory, e.g.
+// `$XDG_CACHE_HOME/goauth/tokens` or `~/.cache/goauth/tokens` on Linux.
+func DefaultTokenCacheDir() (string, error) {
+	if dir, err := os.UserCacheDir(); err != nil {
+		return "", err
+	} else {
+		return filepath.Join(dir, "goauth", "tokens"), nil
+	}
+}
+
+// NewTokenCache returns a `TokenCache` for `dir`, using `DefaultTokenCacheDir()` if empty.
+func NewTokenCache(dir string) (*TokenCache, error) {
+	if strings.TrimSpace(dir) == "" {
+		if def, err := DefaultTokenCacheDir(); err != nil {
+			return nil, err
+		} else {
+			dir = def
+		}
+	}
+	return &TokenCache{Dir: dir}, nil
+}
+
+// TokenCacheKey returns a cache key for a credentials file, account key and scopes. The file
+// path is made absolute and scopes are sorted so equivalent inputs share a key.
+func TokenCacheKey(credsFile, account string, scopes []string) string {
+	if abs, err := filepath.Abs(credsFile); err == nil && strings.TrimSpace(credsFile) != "" {
+		credsFile = abs
+	}
+	scopes = slices.Clone(scopes)
+	slices.Sort(scopes)
+	sum := sha256.Sum256([]byte(credsFile + "\n" + account + "\n" + strings.Join(scopes, " ")))
+	name := strings.Trim(rxTokenCacheUnsafe.ReplaceAllString(account, "_"), "_.")
+	if name == "" {
+		name = "token"
+	}
+	return name + "-" + hex.EncodeToString(sum[:8])
+}
+
+// Path returns the token file path for a key.
+func (tc *TokenCache) Path(key string) string {
+	return filepath.Join(tc.Dir, rxTokenCacheUnsafe.ReplaceAllString(key, "_")+tokenCacheExt)
+}
+
+// Get returns the cached token for a key, or nil if there is none.
+func (tc *TokenCache) Get(key string) (*oauth2.Token, error) {
+	tok, err := ReadTokenFile(tc.Path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return tok, err
+}
+
+// Put writes a token for a key.
+func (tc *TokenCache) Put(key string, tok *oauth2.Token) error {
+	if tok == nil {
+		return errors.New("token must not be nil")
+	}
+	b, err := json.Marshal(tok) //nolint:gosec // G117: OAuth token response per RFC 6749
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(tc.Dir, 0700); err != nil {
+		return err
+	}
+	path := tc.Path(key)
+	unlock, err := LockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return WriteFileAtomic(path, b, 0600)
+}
+
+// Delete removes the cached token for a key. A missing token is not an error.
+func (tc *TokenCache) Delete(key string) error {
+	path := tc.Path(key)
+	unlock, err := LockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Clear removes all cached tokens and returns the number removed.
+func (tc *TokenCache) Clear() (int, error) {
+	entries, err := os.ReadDir(tc.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != tokenCacheExt {
+			continue
+		}
+		if err := tc.Delete(strings.TrimSuffix(e.Name(), tokenCacheExt)); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// TokenSource returns an `oauth2.TokenSource` which returns the cached token while it is valid,
+// otherwise gets a token from `src`, e.g. a refreshing `oauth2.Config.TokenSource()`, and caches it.
+// A failed cache write is logged with `slog` and does not fail the request.
+func (tc *TokenCache) TokenSource(key string, src oauth2.TokenSource) oauth2.TokenSource {
+	return &cachedTokenSource{cache: tc, key: key, src: src}
+}
+
+type cachedTokenSource struct {
+	mu    sync.Mutex
+	cache *TokenCache
+	key   string
+	src   oauth2.TokenSource
+	tok   *oauth2.Token
+}
+
+func (s *cachedTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tok.Valid() {
+		return s.tok, nil
+	} else if tok, err := s.cache.Get(s.key); err == nil && tok.Valid() {
+		s.tok = tok // refreshed by another process
+		return tok, nil
+	}
+	tok, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	s.tok = tok
+	// The token is valid even if it cannot be cached, e.g. on a read-only file system.
+	if err := s.cache.Put(s.key, tok); err != nil {
+		slog.Warn("token cache write failed", "key", s.key, "error", err.Error())
+	}
+	return tok, nil
+}
+
+// NewClientWebTokenCache returns a client using the cached token for `key`, refreshing it
+// with `conf` when expired. If there is no usable token or `forceNewToken` is set, the
+// authorization code flow is run on the command line and the new token is cached.
+func NewClientWebTokenCache(ctx context.Context, conf *oauth2.Config, cache *TokenCache, key string, forceNewToken bool, state string) (*http.Client, error) {
+	tok, err := cache.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if tok == nil || forceNewToken || (!tok.Valid() && tok.RefreshToken == "") {
+		if tok, err = NewTokenCLIFromWeb(ctx, conf, state); err != nil {
+			return nil, err
+		} else if err := cache.Put(key, tok); err != nil {
+			return nil, err
+		}
+	}
+	return oauth2.NewClient(ctx, cache.TokenSource(key, conf.TokenSource(ctx, tok))), nil
+}
+
+// WriteFileAtomic writes `data` to a temporary file in the same directory as `path` and
+// renames it over `path`, so readers see either the old or new contents.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	} else if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	} else if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,78 @@
+package authutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+type countingTokenSource struct {
+	count int
+}
+
+func (ts *countingTokenSource) Token() (*oauth2.Token, error) {
+	ts.count++
+	return &oauth2.Token{AccessToken: "new", Expiry: time.Now().Add(time.Hour)}, nil
+}
+
+var tokenCacheTests = []struct {
+	cached      *oauth2.Token
+	wantToken   string
+	wantFetches int
+}{
+	{nil, "new", 1},
+	{&oauth2.Token{AccessToken: "old", Expiry: time.Now().Add(time.Hour)}, "old", 0},
+	{&oauth2.Token{AccessToken: "old", RefreshToken: "r", Expiry: time.Now().Add(-time.Hour)}, "new", 1},
+}
+
+func TestTokenCache(t *testing.T) {
+	tc := &TokenCache{Dir: t.TempDir()}
+	key := TokenCacheKey("creds.json", "my-app", []string{"b", "a"})
+	if key2 := TokenCacheKey("creds.json", "my-app", []string{"a", "b"}); key != key2 {
+		t.Errorf("TokenCacheKey(): want scope order ignored, got [%s] and [%s]", key, key2)
+	}
+	for _, tt := range tokenCacheTests {
+		if tt.cached != nil {
+			if err := tc.Put(key, tt.cached); err != nil {
+				t.Fatalf("TokenCache.Put(): err [%v]", err)
+			}
+			if fi, err := os.Stat(tc.Path(key)); err != nil || fi.Mode().Perm() != 0600 {
+				t.Errorf("TokenCache.Put(): want mode [0600], got [%v] err [%v]", fi.Mode().Perm(), err)
+			}
+		}
+		src := &countingTokenSource{}
+		tok, err := tc.TokenSource(key, src).Token()
+		if err != nil {
+			t.Fatalf("TokenCache.TokenSource().Token(): err [%v]", err)
+		}
+		if tok.AccessToken != tt.wantToken || src.count != tt.wantFetches {
+			t.Errorf("TokenCache.TokenSource().Token(): want [%s] fetches [%d], got [%s] fetches [%d]",
+				tt.wantToken, tt.wantFetches, tok.AccessToken, src.count)
+		}
+		if got, err := tc.Get(key); err != nil || got == nil || got.AccessToken != tt.wantToken {
+			t.Errorf("TokenCache.Get(): want [%s], got [%v] err [%v]", tt.wantToken, got, err)
+		}
+		if count, err := tc.Clear(); err != nil || count != 1 {
+			t.Errorf("TokenCache.Clear(): want [1], got [%d] err [%v]", count, err)
+		}
+	}
+}
+
+func TestTokenCacheWriteError(t *testing.T) {
+	// A cache directory below a regular file cannot be created.
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	tc := &TokenCache{Dir: filepath.Join(file, "tokens")}
+	src := &countingTokenSource{}
+	ts := tc.TokenSource("my-app", src)
+	for range 2 {
+		if tok, err := ts.Token(); err != nil || tok.AccessToken != "new" || src.count != 1 {
+			t.Errorf("TokenCache.TokenSource().Token(): want [new] fetches [1], got [%v] fetches [%d] err [%v]", tok, src.count, err)
+		}
+	}
+}
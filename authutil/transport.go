@@ -0,0 +1,278 @@
+package authutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/grokify/mogo/net/http/httputilmore"
+	"golang.org/x/oauth2"
+)
+
+// TokenRequestTimeout is the `http.Client.Timeout` for token endpoint requests when
+// `TransportConfig.Timeout` is not set, and for the client returned by `ContextClient()` when
+// the context has none.
+var TokenRequestTimeout = 30 * time.Second
+
+// TransportConfig configures the HTTP transport used for both token endpoint requests and
+// API requests. Durations use `time.ParseDuration()` format, e.g. `30s`.
+type TransportConfig struct {
+	ProxyURL              string      `json:"proxyURL,omitempty"` // `http`, `https` or `socks5` scheme
+	CAFile                string      `json:"caFile,omitempty"`   // PEM bundle added to the system pool
+	MinTLSVersion         string      `json:"minTLSVersion,omitempty"`
+	DialTimeout           string      `json:"dialTimeout,omitempty"`
+	TLSHandshakeTimeout   string      `json:"tlsHandshakeTimeout,omitempty"`
+	ResponseHeaderTimeout string      `json:"responseHeaderTimeout,omitempty"`
+	Timeout               string      `json:"timeout,omitempty"` // `http.Client.Timeout`, including reading the body
+	MaxIdleConns          int         `json:"maxIdleConns,omitempty"`
+	MaxIdleConnsPerHost   int         `json:"maxIdleConnsPerHost,omitempty"`
+	Header                http.Header `json:"header,omitempty"` // added when not already set on the request
+	UserAgent             string      `json:"userAgent,omitempty"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion parses a TLS version such as `1.2` or `TLS1.3`.
+func ParseTLSVersion(s string) (uint16, error) {
+	v := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "tls")
+	if ver, ok := tlsVersions[strings.TrimSpace(v)]; ok {
+		return ver, nil
+	}
+	return 0, fmt.Errorf("tls version not supported (%s)", s)
+}
+
+// NewHTTPTransport returns an `*http.Transport` based on `http.DefaultTransport` with the
+// proxy, TLS, timeout and connection pool settings applied.
+func (tc *TransportConfig) NewHTTPTransport() (*http.Transport, error) {
+	xport := http.DefaultTransport.(*http.Transport).Clone()
+	if tc == nil {
+		return xport, nil
+	}
+	if proxyURL := strings.TrimSpace(tc.ProxyURL); proxyURL != "" {
+		if u, err := url.Parse(proxyURL); err != nil {
+			return nil, err
+		} else {
+			xport.Proxy = http.ProxyURL(u)
+		}
+	}
+	if tc.CAFile != "" || tc.MinTLSVersion != "" {
+		if xport.TLSClientConfig == nil {
+			xport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		if tc.MinTLSVersion != "" {
+			if ver, err := ParseTLSVersion(tc.MinTLSVersion); err != nil {
+				return nil, err
+			} else {
+				xport.TLSClientConfig.MinVersion = ver // #nosec G402
+			}
+		}
+		if tc.CAFile != "" {
+			if pool, err := certPoolWithFile(tc.CAFile); err != nil {
+				return nil, err
+			} else {
+				xport.TLSClientConfig.RootCAs = pool
+			}
+		}
+	}
+	if tc.DialTimeout != "" {
+		if d, err := time.ParseDuration(tc.DialTimeout); err != nil {
+			return nil, fmt.Errorf("dialTimeout: %w", err)
+		} else {
+			xport.DialContext = (&net.Dialer{Timeout: d, KeepAlive: 30 * time.Second}).DialContext
+		}
+	}
+	if tc.TLSHandshakeTimeout != "" {
+		if d, err := time.ParseDuration(tc.TLSHandshakeTimeout); err != nil {
+			return nil, fmt.Errorf("tlsHandshakeTimeout: %w", err)
+		} else {
+			xport.TLSHandshakeTimeout = d
+		}
+	}
+	if tc.ResponseHeaderTimeout != "" {
+		if d, err := time.ParseDuration(tc.ResponseHeaderTimeout); err != nil {
+			return nil, fmt.Errorf("responseHeaderTimeout: %w", err)
+		} else {
+			xport.ResponseHeaderTimeout = d
+		}
+	}
+	if tc.MaxIdleConns > 0 {
+		xport.MaxIdleConns = tc.MaxIdleConns
+	}
+	if tc.MaxIdleConnsPerHost > 0 {
+		xport.MaxIdleConnsPerHost = tc.MaxIdleConnsPerHost
+	}
+	return xport, nil
+}
+
+func certPoolWithFile(filename string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if b, err := os.ReadFile(filename); err != nil {
+		return nil, err
+	} else if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in ca file (%s)", filename)
+	}
+	return pool, nil
+}
+
+// defaultHeader returns the configured default headers including `User-Agent`.
+func (tc *TransportConfig) defaultHeader() http.Header {
+	h := http.Header{}
+	if tc == nil {
+		return h
+	}
+	for k, vals := range tc.Header {
+		for _, v := range vals {
+			h.Add(k, v)
+		}
+	}
+	if ua := strings.TrimSpace(tc.UserAgent); ua != "" {
+		h.Set(httputilmore.HeaderUserAgent, ua)
+	}
+	return h
+}
+
+// NewTransport returns the configured `*http.Transport`, wrapped to add default headers if any.
+func (tc *TransportConfig) NewTransport() (http.RoundTripper, error) {
+	xport, err := tc.NewHTTPTransport()
+	if err != nil {
+		return nil, err
+	}
+	if h := tc.defaultHeader(); len(h) > 0 {
+		return httputilmore.TransportRequestModifier{
+			Header:    h,
+			Transport: xport}, nil
+	}
+	return xport, nil
+}
+
+// timeout returns the parsed `Timeout`, or 0 if it is not set.
+func (tc *TransportConfig) timeout() (time.Duration, error) {
+	if tc == nil || tc.Timeout == "" {
+		return 0, nil
+	} else if d, err := time.ParseDuration(tc.Timeout); err != nil {
+		return 0, fmt.Errorf("timeout: %w", err)
+	} else {
+		return d, nil
+	}
+}
+
+// NewClient returns an `*http.Client` using `NewTransport()` and `Timeout`.
+func (tc *TransportConfig) NewClient() (*http.Client, error) {
+	d, err := tc.timeout()
+	if err != nil {
+		return nil, err
+	}
+	if xport, err := tc.NewTransport(); err != nil {
+		return nil, err
+	} else {
+		return &http.Client{Transport: xport, Timeout: d}, nil
+	}
+}
+
+// ContextWithClient returns a context carrying a client built from the config under the
+// `oauth2.HTTPClient` key so it is used for token endpoint requests. If `Timeout` is not set,
+// the client uses `TokenRequestTimeout`.
+func (tc *TransportConfig) ContextWithClient(ctx context.Context) (context.Context, error) {
+	clt, err := tc.NewClient()
+	if err != nil {
+		return ctx, err
+	} else if clt.Timeout == 0 {
+		clt.Timeout = TokenRequestTimeout
+	}
+	return ContextWithHTTPClient(ctx, clt), nil
+}
+
+// ApplyToClient replaces the network transport at the end of the client's transport chain
+// with the configured transport, and sets the client timeout if `Timeout` is set.
+// `InsecureSkipVerify` set on the existing transport is kept.
+// An error wrapping `ErrTransportNotResolvable` is returned for transport chains not created
+// by this module, which the config cannot be applied to.
+func (tc *TransportConfig) ApplyToClient(client *http.Client) (*http.Client, error) {
+	if client == nil {
+		return nil, errors.New("client must not be nil")
+	}
+	timeout, err := tc.timeout()
+	if err != nil {
+		return nil, err
+	}
+	xport, err := tc.NewHTTPTransport()
+	if err != nil {
+		return nil, err
+	}
+	if leaf, ok := leafTransport(client.Transport).(*http.Transport); ok &&
+		leaf.TLSClientConfig != nil && leaf.TLSClientConfig.InsecureSkipVerify {
+		if xport.TLSClientConfig == nil {
+			xport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		xport.TLSClientConfig.InsecureSkipVerify = true // #nosec G402
+	}
+	var rt http.RoundTripper = xport
+	if h := tc.defaultHeader(); len(h) > 0 {
+		rt = httputilmore.TransportRequestModifier{Header: h, Transport: xport}
+	}
+	if chain, err := replaceLeafTransport(client.Transport, rt); err != nil {
+		return nil, fmt.Errorf("transport config cannot be applied: %w", err)
+	} else {
+		clt := *client
+		clt.Transport = chain
+		if timeout > 0 {
+			clt.Timeout = timeout
+		}
+		return &clt, nil
+	}
+}
+
+// leafTransport returns the last transport in a chain created by this module.
+func leafTransport(xport http.RoundTripper) http.RoundTripper {
+	switch t := xport.(type) {
+	case httputilmore.TransportRequestModifier:
+		return leafTransport(t.Transport)
+	case *httputilmore.TransportRequestModifier:
+		return leafTransport(t.Transport)
+	case *oauth2.Transport:
+		return leafTransport(t.Base)
+	default:
+		return xport
+	}
+}
+
+// ContextWithHTTPClient returns a context carrying `clt` under the `oauth2.HTTPClient` key
+// which is used for token endpoint requests by this module and `golang.org/x/oauth2`. If
+// `clt` is nil, `ctx` is returned unchanged.
+func ContextWithHTTPClient(ctx context.Context, clt *http.Client) context.Context {
+	if clt == nil {
+		return ctx
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, oauth2.HTTPClient, clt)
+}
+
+// ContextClient returns the `*http.Client` stored under the `oauth2.HTTPClient` context key,
+// or a client using `http.DefaultTransport` with a `TokenRequestTimeout` timeout, since
+// `http.DefaultClient` has none.
+func ContextClient(ctx context.Context) *http.Client {
+	if ctx != nil {
+		if clt, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && clt != nil {
+			return clt
+		}
+	}
+	return &http.Client{Timeout: TokenRequestTimeout}
+}
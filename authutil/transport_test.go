@@ -0,0 +1,108 @@
+package authutil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+var transportConfigTests = []struct {
+	config     TransportConfig
+	userAgent  string
+	customHdr  string
+	authzValue string
+}{
+	{TransportConfig{UserAgent: "goauth-test/1.0", Header: http.Header{"X-Team": []string{"platform"}}}, "goauth-test/1.0", "platform", "Bearer abc"},
+	{TransportConfig{ResponseHeaderTimeout: "5s", MinTLSVersion: "1.3"}, "Go-http-client/1.1", "", "Bearer abc"},
+}
+
+func TestTransportConfigApplyToClient(t *testing.T) {
+	var got http.Header
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer svr.Close()
+
+	for _, tt := range transportConfigTests {
+		clt, err := tt.config.ApplyToClient(NewClientToken(TokenBearer, "abc", false))
+		if err != nil {
+			t.Fatalf("TransportConfig.ApplyToClient(): err [%v]", err)
+		}
+		resp, err := clt.Get(svr.URL)
+		if err != nil {
+			t.Fatalf("client.Get(): err [%v]", err)
+		}
+		resp.Body.Close()
+		if v := got.Get("User-Agent"); v != tt.userAgent {
+			t.Errorf("TransportConfig.ApplyToClient() User-Agent: want [%s], got [%s]", tt.userAgent, v)
+		}
+		if v := got.Get("X-Team"); v != tt.customHdr {
+			t.Errorf("TransportConfig.ApplyToClient() X-Team: want [%s], got [%s]", tt.customHdr, v)
+		}
+		if v := got.Get("Authorization"); v != tt.authzValue {
+			t.Errorf("TransportConfig.ApplyToClient() Authorization: want [%s], got [%s]", tt.authzValue, v)
+		}
+	}
+}
+
+type customTransport struct{}
+
+func (customTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestTransportConfigApplyToClientUnsupported(t *testing.T) {
+	tc := &TransportConfig{UserAgent: "goauth-test/1.0"}
+	for _, clt := range []*http.Client{
+		{Transport: customTransport{}},
+		{Transport: &oauth2.Transport{Base: customTransport{}}},
+	} {
+		if got, err := tc.ApplyToClient(clt); !errors.Is(err, ErrTransportNotResolvable) {
+			t.Errorf("TransportConfig.ApplyToClient(%T): want [%v], got [%v] err [%v]", clt.Transport, ErrTransportNotResolvable, got, err)
+		}
+	}
+	if _, err := tc.ApplyToClient(&http.Client{}); err != nil {
+		t.Errorf("TransportConfig.ApplyToClient(default transport): want [nil], got [%v]", err)
+	}
+}
+
+func TestTransportConfigProxy(t *testing.T) {
+	tc := TransportConfig{ProxyURL: "socks5://127.0.0.1:1080", MinTLSVersion: "TLS1.2"}
+	xport, err := tc.NewHTTPTransport()
+	if err != nil {
+		t.Fatalf("TransportConfig.NewHTTPTransport(): err [%v]", err)
+	}
+	proxyURL, err := xport.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "api.example.com"}})
+	if err != nil || proxyURL == nil || proxyURL.String() != tc.ProxyURL {
+		t.Errorf("TransportConfig.NewHTTPTransport().Proxy: want [%s], got [%v] err [%v]", tc.ProxyURL, proxyURL, err)
+	}
+	if _, err := (&TransportConfig{MinTLSVersion: "1.4"}).NewHTTPTransport(); err == nil {
+		t.Errorf("TransportConfig.NewHTTPTransport(MinTLSVersion=1.4): want err, got nil")
+	}
+}
+
+func TestTransportConfigTimeout(t *testing.T) {
+	tc := &TransportConfig{Timeout: "5s"}
+	if clt, err := tc.NewClient(); err != nil || clt.Timeout != 5*time.Second {
+		t.Errorf("TransportConfig.NewClient(Timeout=5s): want [5s], got [%v] err [%v]", clt, err)
+	}
+	if clt, err := tc.ApplyToClient(&http.Client{}); err != nil || clt.Timeout != 5*time.Second {
+		t.Errorf("TransportConfig.ApplyToClient(Timeout=5s): want [5s], got [%v] err [%v]", clt, err)
+	}
+	if ctx, err := (&TransportConfig{}).ContextWithClient(context.Background()); err != nil ||
+		ContextClient(ctx).Timeout != TokenRequestTimeout {
+		t.Errorf("TransportConfig.ContextWithClient(): want timeout [%v], got [%v] err [%v]", TokenRequestTimeout, ContextClient(ctx).Timeout, err)
+	}
+	if clt := ContextClient(context.Background()); clt.Timeout != TokenRequestTimeout {
+		t.Errorf("ContextClient(): want timeout [%v], got [%v]", TokenRequestTimeout, clt.Timeout)
+	}
+	if _, err := (&TransportConfig{Timeout: "5"}).NewClient(); err == nil {
+		t.Errorf("TransportConfig.NewClient(Timeout=5): want err, got nil")
+	}
+}
@@ -2,25 +2,27 @@ package goauth
 
 import (
 	"context"
-	"io"
+	"errors"
 	"net/http"
-	"time"
+	"os"
 
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/goauth/authutil/oauthstate"
 	"github.com/grokify/mogo/errors/errorsutil"
-	"github.com/grokify/mogo/fmt/fmtutil"
-	"github.com/grokify/mogo/net/http/httpsimple"
-	"github.com/grokify/mogo/net/http/httputilmore"
-	"github.com/grokify/mogo/type/maputil"
 	"github.com/jessevdk/go-flags"
+	"golang.org/x/oauth2"
 )
 
 // Options is a struct to be used with `ParseOptions()` or `github.com/jessevdk/go-flags`.
 // It can be embedded in another struct and used directly with `github.com/jessevdk/go-flags`.
 type Options struct {
-	CredsPath string `long:"creds" description:"Environment File Path"`
-	Account   string `long:"account" description:"Environment Variable Name"`
-	Token     string `long:"token" description:"Token"`
-	CLI       []bool `long:"cli" description:"CLI"`
+	CredsPath string `long:"creds" env:"GOAUTH_CREDS" description:"Credentials set file path"`
+	Account   string `long:"account" description:"Account key in the credentials set file"`
+	Token     string `long:"token" description:"Access token, used before other credentials sources"`
+	TokenFile string `long:"token-file" description:"Cached token file path"`
+	NoCache   bool   `long:"no-cache" description:"Do not read or write the token cache"`
+	CLI       []bool `long:"cli" description:"Enable the interactive token flow"`
+	Verbose   []bool `short:"v" long:"verbose" description:"Report which credentials source was used"`
 }
 
 func NewClientCmd(ctx context.Context, state string) (*http.Client, error) {
@@ -45,77 +47,75 @@ func (opts *Options) CredentialsSet(inflateEndpoints bool) (*CredentialsSet, err
 	return ReadFileCredentialsSet(opts.CredsPath, inflateEndpoints)
 }
 
-func (opts *Options) NewClient(ctx context.Context, state string) (*http.Client, error) {
-	if opts.CredsPath == "" && opts.Account == "" {
-		return &http.Client{}, nil
-	} else if creds, err := opts.Credentials(); err != nil {
-		return nil, errorsutil.Wrap(err, "error in `goauth.Options.NewClient() call to self as `goauth.Options.Credentials()`")
-	} else if opts.UseCLI() {
-		if state == "" {
-			state = time.Now().Format(time.RFC3339)
-		}
-		return creds.NewClientCLI(ctx, state)
+// TokenCache returns the token cache in the user cache directory, or nil if `--no-cache` is
+// set or the directory cannot be determined.
+func (opts *Options) TokenCache() *authutil.TokenCache {
+	if opts.NoCache {
+		return nil
+	} else if tc, err := authutil.NewTokenCache(""); err != nil {
+		return nil
 	} else {
-		return creds.NewClient(ctx)
+		return tc
 	}
 }
 
-func (opts *Options) UseCLI() bool {
-	return len(opts.CLI) > 0
+// ProviderChain returns the credentials providers in precedence order: the `--token` flag,
+// `GOAUTH_` environment variables, the cached token, the credentials set file account
+// and the interactive flow. The interactive flow is only enabled with `--cli`, so commands
+// run from a terminal do not prompt unless asked to.
+func (opts *Options) ProviderChain(state string) ProviderChain {
+	if state == "" {
+		state = oauthstate.Random()
+	}
+	file := FileProvider{
+		Filename: opts.CredsPath,
+		Account:  opts.Account,
+		Cache:    opts.TokenCache()}
+	return ProviderChain{
+		TokenProvider{AccessToken: opts.Token},
+		EnvProvider{Prefix: EnvPrefixDefault},
+		CachedTokenProvider{Filepath: opts.TokenFile, FileProvider: file},
+		file,
+		InteractiveProvider{
+			FileProvider: file,
+			State:        state,
+			Enabled:      opts.UseCLI()}}
 }
 
-// CLIRequest will get a token using `goauth` and then execute the provided request
-// parameters with the credential, e.g. OAuth 2.0 access token.
-type CLIRequest struct {
-	Options
-	Request httpsimple.CLI
+// NewClient returns a client from the first credentials source in `ProviderChain()`. If no
+// source is configured and no credentials file or account is set, a plain client is returned.
+// With `--verbose`, the result of each provider is written to stderr.
+func (opts *Options) NewClient(ctx context.Context, state string) (*http.Client, error) {
+	clt, results, err := opts.ProviderChain(state).Resolve(ctx)
+	if opts.UseVerbose() {
+		if werr := results.Write(os.Stderr); werr != nil {
+			return nil, werr
+		}
+	}
+	if errors.Is(err, ErrNoCredentials) && opts.CredsPath == "" && opts.Account == "" {
+		return &http.Client{}, nil
+	} else if err != nil {
+		return nil, errorsutil.Wrap(err, "error in `goauth.Options.NewClient()`")
+	}
+	return clt, nil
 }
 
-func (cli CLIRequest) Do(ctx context.Context, state string, w io.Writer) error {
-	if clt, err := cli.NewClient(ctx, state); err != nil {
-		return errorsutil.WrapWithLocation(err)
-	} else if sr, err := cli.Request.Request(); err != nil {
-		return errorsutil.WrapWithLocation(err)
-	} else {
-		sc := httpsimple.NewClient(clt, "")
-		resp, err := sc.Do(ctx, sr)
-		if err != nil {
-			return errorsutil.WrapWithLocation(err)
-		}
-		if _, err := fmtutil.FprintfIf(w, "Response Status Code: %d\n", resp.StatusCode); err != nil {
-			return errorsutil.WrapWithLocation(err)
-		}
-		if _, err := fmtutil.FprintfIf(w, "===== BEGIN RESPONSE META =====\nStatus Code: %d\n===== END RESPONSE META =====\n", resp.StatusCode); err != nil {
-			return errorsutil.WrapWithLocation(err)
+// NewToken returns an access token from the first credentials source in `ProviderChain()`
+// along with the result of each provider. With `--verbose`, the results are written to stderr.
+func (opts *Options) NewToken(ctx context.Context, state string) (*oauth2.Token, ProviderResults, error) {
+	tok, results, err := opts.ProviderChain(state).ResolveToken(ctx)
+	if opts.UseVerbose() {
+		if werr := results.Write(os.Stderr); werr != nil {
+			return nil, results, werr
 		}
+	}
+	return tok, results, err
+}
 
-		if _, err := fmtutil.FprintIf(w, "===== BEGIN RESPONSE HEADERS =====\n"); err != nil {
-			return errorsutil.WrapWithLocation(err)
-		}
-		hkeys := maputil.Keys(resp.Header)
-		for _, header := range hkeys {
-			if v, ok := resp.Header[header]; ok {
-				for _, vi := range v {
-					if _, err := fmtutil.FprintfIf(w, "%s: %s\n", header, vi); err != nil {
-						return errorsutil.WrapWithLocation(err)
-					}
-				}
-			}
-		}
-		if _, err := fmtutil.FprintIf(w, "===== END RESPONSE HEADERS =====\n"); err != nil {
-			return errorsutil.WrapWithLocation(err)
-		}
+func (opts *Options) UseCLI() bool {
+	return len(opts.CLI) > 0
+}
 
-		b, err := httputilmore.ResponseBodyMore(resp, "", "  ")
-		if err != nil {
-			return errorsutil.WrapWithLocation(err)
-		} else {
-			if w != nil {
-				if _, err := fmtutil.FprintfIf(w, "===== BEGIN RESPONSE BODY =====\n%s\n===== END RESPONSE BODY =====", string(b)); err != nil {
-					return errorsutil.WrapWithLocation(err)
-				}
-			}
-			return nil
-		}
-	}
+func (opts *Options) UseVerbose() bool {
+	return len(opts.Verbose) > 0
 }
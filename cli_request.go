@@ -0,0 +1,511 @@
+package goauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/grokify/mogo/errors/errorsutil"
+	"github.com/grokify/mogo/net/http/httpsimple"
+	"github.com/grokify/mogo/type/maputil"
+)
+
+// Response output formats for `CLIRequest`.
+const (
+	OutputText    = "text"    // status, headers, and body in human-readable sections
+	OutputJSON    = "json"    // one indented `{statusCode, header, body}` object per page
+	OutputRaw     = "raw"     // response bodies as received
+	OutputHeaders = "headers" // status line and headers only
+	OutputNDJSON  = "ndjson"  // one compact JSON value per line, splitting top-level arrays
+)
+
+var (
+	ErrOutputNotSupported = errors.New("output format not supported")
+	ErrQueryInvalid       = errors.New("invalid query")
+	// ErrResponseStatus is returned after writing a response whose status is not 2xx.
+	ErrResponseStatus = errors.New("response status not successful")
+	// ErrPageOrigin is returned when a next page URL has a different scheme or host than the
+	// request, so the credentials are not sent to another site.
+	ErrPageOrigin = errors.New("next page url origin does not match request")
+)
+
+// DefaultCursorPaths are the body fields checked for the next page when the response has no
+// `Link` header and `ResponseOptions.CursorPath` is not set.
+var DefaultCursorPaths = []string{".next", ".links.next", ".paging.next", ".navigation.nextPage.uri"}
+
+// CLIRequest will get a token using `goauth` and then execute the provided request
+// parameters with the credential, e.g. OAuth 2.0 access token.
+type CLIRequest struct {
+	Options
+	Request  httpsimple.CLI
+	Output   string `long:"output" description:"Output format" choice:"text" choice:"json" choice:"raw" choice:"headers" choice:"ndjson" default:"text"`
+	Response ResponseOptions
+}
+
+func (cli CLIRequest) Do(ctx context.Context, state string, w io.Writer) error {
+	if clt, err := cli.NewClient(ctx, state); err != nil {
+		return errorsutil.WrapWithLocation(err)
+	} else if sr, err := cli.Request.Request(); err != nil {
+		return errorsutil.WrapWithLocation(err)
+	} else {
+		return cli.Response.Do(ctx, clt, sr, cli.Output, w)
+	}
+}
+
+// ResponseOptions sets pagination and field extraction for `CLIRequest`. It can be used
+// directly with `github.com/jessevdk/go-flags`.
+type ResponseOptions struct {
+	FollowPages bool   `long:"follow-pages" description:"Request each next page from the Link header or body cursor"`
+	MaxPages    int    `long:"max-pages" description:"Maximum pages to request with --follow-pages, 0 for no limit"`
+	CursorPath  string `long:"cursor-path" description:"Body path of the next page cursor or URL, e.g. $.meta.next_cursor"`
+	CursorParam string `long:"cursor-param" description:"Query parameter for a cursor that is not a URL" default:"cursor"`
+	Query       string `long:"jq" description:"Extract values from JSON bodies, e.g. .records[].id"`
+}
+
+// Do executes `req`, and each next page if `FollowPages` is set, writing responses to `w`
+// in the `output` format.
+func (opts ResponseOptions) Do(ctx context.Context, clt *http.Client, req httpsimple.Request, output string, w io.Writer) error {
+	output = strings.ToLower(strings.TrimSpace(output))
+	if output == "" {
+		output = OutputText
+	}
+	var steps []pathStep
+	switch output {
+	case OutputText, OutputHeaders:
+		if strings.TrimSpace(opts.Query) != "" {
+			return fmt.Errorf("%w: query with output `%s`", ErrOutputNotSupported, output)
+		}
+	case OutputJSON, OutputRaw, OutputNDJSON:
+		if strings.TrimSpace(opts.Query) != "" {
+			s, err := parsePath(opts.Query)
+			if err != nil {
+				return err
+			}
+			steps = s
+		}
+	default:
+		return fmt.Errorf("%w (%s)", ErrOutputNotSupported, output)
+	}
+	if w == nil {
+		w = io.Discard
+	}
+	return opts.pages(ctx, clt, req, func(resp *http.Response, b []byte) error {
+		return writeResponse(w, output, resp, b, steps)
+	})
+}
+
+// errStopPages is returned by a `pages()` callback to stop without an error.
+var errStopPages = errors.New("stop pages")
+
+// pages executes `req`, and each next page if `FollowPages` is set, calling `fn` with each
+// response and body. It stops with `ErrResponseStatus` after a response which is not 2xx, and
+// with `ErrPageOrigin` if a next page is on another scheme or host.
+func (opts ResponseOptions) pages(ctx context.Context, clt *http.Client, req httpsimple.Request, fn func(resp *http.Response, b []byte) error) error {
+	sc := httpsimple.NewClient(clt, "")
+	seen := map[string]bool{}
+	origin, err := url.Parse(req.URL)
+	if err != nil {
+		return errorsutil.WrapWithLocation(err)
+	}
+	for page := 1; ; page++ {
+		resp, err := sc.Do(ctx, req)
+		if err != nil {
+			return errorsutil.WrapWithLocation(err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return errorsutil.WrapWithLocation(err)
+		} else if err := fn(resp, b); errors.Is(err, errStopPages) {
+			return nil
+		} else if err != nil {
+			return err
+		} else if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%w (%d)", ErrResponseStatus, resp.StatusCode)
+		} else if !opts.FollowPages || (opts.MaxPages > 0 && page >= opts.MaxPages) {
+			return nil
+		}
+		if resp.Request != nil && resp.Request.URL != nil {
+			seen[resp.Request.URL.String()] = true
+		}
+		next, err := opts.NextURL(resp, b)
+		if err != nil {
+			return err
+		} else if next == "" || seen[next] {
+			return nil
+		} else if u, err := url.Parse(next); err != nil {
+			return err
+		} else if !strings.EqualFold(u.Scheme, origin.Scheme) || !strings.EqualFold(u.Host, origin.Host) {
+			return fmt.Errorf("%w (%s://%s)", ErrPageOrigin, u.Scheme, u.Host)
+		}
+		req = httpsimple.Request{
+			Method:   req.Method,
+			URL:      next,
+			Headers:  req.Headers,
+			Body:     req.Body,
+			BodyType: req.BodyType}
+	}
+}
+
+// NextURL returns the next page URL from the response `Link` header, the `CursorPath` body
+// field, or the first of `DefaultCursorPaths` that is set. A cursor that is not a URL is set as
+// the `CursorParam` query parameter on the request URL. It returns an empty string if there
+// is no next page.
+func (opts ResponseOptions) NextURL(resp *http.Response, body []byte) (string, error) {
+	var base *url.URL
+	if resp.Request != nil {
+		base = resp.Request.URL
+	}
+	if next := linkNext(resp.Header); next != "" {
+		return nextPageURL(base, next, "")
+	}
+	v, err := decodeJSON(body)
+	if err != nil {
+		return "", nil
+	}
+	paths := DefaultCursorPaths
+	if opts.CursorPath != "" {
+		paths = []string{opts.CursorPath}
+	}
+	for _, path := range paths {
+		steps, err := parsePath(path)
+		if err != nil {
+			return "", err
+		}
+		vals, err := evalPath(v, steps)
+		if err != nil {
+			if opts.CursorPath != "" {
+				return "", err
+			}
+			continue
+		}
+		for _, val := range vals {
+			var cursor string
+			switch c := val.(type) {
+			case string:
+				cursor = c
+			case json.Number:
+				cursor = c.String()
+			}
+			if cursor = strings.TrimSpace(cursor); cursor != "" {
+				return nextPageURL(base, cursor, opts.CursorParam)
+			}
+		}
+	}
+	return "", nil
+}
+
+// nextPageURL resolves a next page URL against `base`, or sets a cursor as the `param` query
+// parameter on `base`.
+func nextPageURL(base *url.URL, cursor, param string) (string, error) {
+	isURL := param == "" ||
+		strings.HasPrefix(cursor, "/") || strings.HasPrefix(cursor, "?") ||
+		strings.HasPrefix(cursor, "http://") || strings.HasPrefix(cursor, "https://")
+	if base == nil {
+		if isURL {
+			return cursor, nil
+		}
+		return "", fmt.Errorf("no request url to add cursor to (%s)", cursor)
+	} else if isURL {
+		ref, err := url.Parse(cursor)
+		if err != nil {
+			return "", err
+		}
+		return base.ResolveReference(ref).String(), nil
+	}
+	u := *base
+	qry := u.Query()
+	qry.Set(param, cursor)
+	u.RawQuery = qry.Encode()
+	return u.String(), nil
+}
+
+// linkNext returns the `rel="next"` target of RFC 8288 `Link` headers.
+func linkNext(h http.Header) string {
+	for _, v := range h.Values("Link") {
+		for {
+			start := strings.IndexByte(v, '<')
+			end := strings.IndexByte(v, '>')
+			if start < 0 || end < start {
+				break
+			}
+			target, params := v[start+1:end], v[end+1:]
+			if i := strings.IndexByte(params, '<'); i >= 0 {
+				v, params = params[i:], params[:i]
+			} else {
+				v = ""
+			}
+			for _, p := range strings.Split(params, ";") {
+				k, val, ok := strings.Cut(p, "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(k), "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(val, "\" ,\t")) {
+					if strings.EqualFold(rel, "next") {
+						return target
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+type responseOutput struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       any         `json:"body,omitempty"`
+}
+
+// writeResponse writes one page in the `output` format, extracting `steps` values if set.
+func writeResponse(w io.Writer, output string, resp *http.Response, b []byte, steps []pathStep) error {
+	switch output {
+	case OutputText:
+		return writeResponseText(w, resp, b)
+	case OutputHeaders:
+		if _, err := fmt.Fprintf(w, "%s %s\n", resp.Proto, resp.Status); err != nil {
+			return err
+		}
+		return writeHeaders(w, resp.Header, "\n")
+	}
+	if steps == nil {
+		switch output {
+		case OutputRaw:
+			_, err := w.Write(b)
+			return err
+		case OutputJSON:
+			out := responseOutput{StatusCode: resp.StatusCode, Header: resp.Header}
+			if json.Valid(b) {
+				out.Body = json.RawMessage(b)
+			} else if len(b) > 0 {
+				out.Body = string(b)
+			}
+			return encodeJSON(w, out, "  ")
+		}
+	}
+	var vals []any
+	if v, err := decodeJSON(b); err != nil {
+		if steps != nil {
+			return fmt.Errorf("response body is not JSON: %w", err)
+		}
+		vals = []any{string(b)}
+	} else if steps != nil {
+		if vals, err = evalPath(v, steps); err != nil {
+			return err
+		}
+	} else if arr, ok := v.([]any); ok {
+		vals = arr
+	} else {
+		vals = []any{v}
+	}
+	for _, val := range vals {
+		var err error
+		switch {
+		case output == OutputJSON:
+			err = encodeJSON(w, val, "  ")
+		case output == OutputRaw && isString(val):
+			_, err = fmt.Fprintln(w, val)
+		default:
+			err = encodeJSON(w, val, "")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeResponseText(w io.Writer, resp *http.Response, b []byte) error {
+	if _, err := fmt.Fprintf(w, "Response Status Code: %d\n", resp.StatusCode); err != nil {
+		return err
+	} else if _, err := fmt.Fprintf(w, "===== BEGIN RESPONSE META =====\nStatus Code: %d\n===== END RESPONSE META =====\n", resp.StatusCode); err != nil {
+		return err
+	} else if _, err := fmt.Fprint(w, "===== BEGIN RESPONSE HEADERS =====\n"); err != nil {
+		return err
+	} else if err := writeHeaders(w, resp.Header, "===== END RESPONSE HEADERS =====\n"); err != nil {
+		return err
+	}
+	if json.Valid(b) {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, b, "", "  "); err == nil {
+			b = buf.Bytes()
+		}
+	}
+	_, err := fmt.Fprintf(w, "===== BEGIN RESPONSE BODY =====\n%s\n===== END RESPONSE BODY =====\n", string(b))
+	return err
+}
+
+// writeHeaders writes sorted headers followed by `trailer`.
+func writeHeaders(w io.Writer, h http.Header, trailer string) error {
+	for _, k := range maputil.Keys(h) {
+		for _, v := range h[k] {
+			if _, err := fmt.Fprintf(w, "%s: %s\n", k, v); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprint(w, trailer)
+	return err
+}
+
+func encodeJSON(w io.Writer, v any, indent string) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	return enc.Encode(v)
+}
+
+// decodeJSON decodes a JSON document, keeping numbers as `json.Number`.
+func decodeJSON(b []byte) (any, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	} else if dec.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+func isString(v any) bool {
+	_, ok := v.(string)
+	return ok
+}
+
+// pathStep is one step of a query path: an object key, an array index, or an iterator.
+type pathStep struct {
+	key   string
+	index *int
+	iter  bool
+}
+
+// parsePath parses a `jq`-style path such as `.data[].id`, `.items[0]` or `.["a.b"]`. A
+// leading `$` and `[*]` are also accepted so JSONPath expressions like `$.meta.next` work.
+func parsePath(path string) ([]pathStep, error) {
+	s := strings.TrimSpace(path)
+	s = strings.TrimPrefix(s, "$")
+	if s == "" || s == "." {
+		return []pathStep{}, nil
+	}
+	steps := []pathStep{}
+	for s != "" {
+		switch {
+		case strings.HasPrefix(s, ".["):
+			s = s[1:]
+		case s[0] == '.':
+			s = s[1:]
+			n := strings.IndexAny(s, ".[")
+			if n < 0 {
+				n = len(s)
+			}
+			if n == 0 {
+				return nil, fmt.Errorf("%w: empty key (%s)", ErrQueryInvalid, path)
+			}
+			steps = append(steps, pathStep{key: s[:n]})
+			s = s[n:]
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: missing `]` (%s)", ErrQueryInvalid, path)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			switch {
+			case inner == "" || inner == "*":
+				steps = append(steps, pathStep{iter: true})
+			case strings.HasPrefix(inner, `"`):
+				key, err := strconv.Unquote(inner)
+				if err != nil {
+					return nil, fmt.Errorf("%w: key %s (%s)", ErrQueryInvalid, inner, path)
+				}
+				steps = append(steps, pathStep{key: key})
+			default:
+				idx, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("%w: index %s (%s)", ErrQueryInvalid, inner, path)
+				}
+				steps = append(steps, pathStep{index: &idx})
+			}
+		default:
+			return nil, fmt.Errorf("%w: unexpected `%c` (%s)", ErrQueryInvalid, s[0], path)
+		}
+	}
+	return steps, nil
+}
+
+// evalPath returns the values `steps` selects from `v`. Missing keys and indexes select `null`.
+func evalPath(v any, steps []pathStep) ([]any, error) {
+	vals := []any{v}
+	for _, step := range steps {
+		next := []any{}
+		for _, val := range vals {
+			switch {
+			case step.iter:
+				switch x := val.(type) {
+				case []any:
+					next = append(next, x...)
+				case map[string]any:
+					for _, k := range maputil.Keys(x) {
+						next = append(next, x[k])
+					}
+				case nil:
+				default:
+					return nil, fmt.Errorf("cannot iterate over %s", jsonTypeName(val))
+				}
+			case step.index != nil:
+				switch x := val.(type) {
+				case []any:
+					i := *step.index
+					if i < 0 {
+						i += len(x)
+					}
+					if i >= 0 && i < len(x) {
+						next = append(next, x[i])
+					} else {
+						next = append(next, nil)
+					}
+				case nil:
+					next = append(next, nil)
+				default:
+					return nil, fmt.Errorf("cannot index %s with number", jsonTypeName(val))
+				}
+			default:
+				switch x := val.(type) {
+				case map[string]any:
+					next = append(next, x[step.key])
+				case nil:
+					next = append(next, nil)
+				default:
+					return nil, fmt.Errorf("cannot index %s with `%s`", jsonTypeName(val), step.key)
+				}
+			}
+		}
+		vals = next
+	}
+	return vals, nil
+}
+
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
@@ -0,0 +1,296 @@
+package goauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/grokify/mogo/net/http/httpsimple"
+)
+
+// Account result statuses for `CLIRequest.DoAccounts()`.
+const (
+	AccountStatusOK        = "ok"         // every page returned a 2xx or 3xx status
+	AccountStatusHTTPError = "http_error" // a page returned a 4xx or 5xx status
+	AccountStatusTimeout   = "timeout"    // the account timeout or context expired
+	AccountStatusError     = "error"      // credentials, connection, or response error
+)
+
+// DefaultAccountsConcurrency is the default number of accounts requested at once.
+const DefaultAccountsConcurrency = 4
+
+// AccountsOptions selects the accounts for `CLIRequest.DoAccounts()` and limits how many
+// are requested at once. It can be used directly with `github.com/jessevdk/go-flags`.
+type AccountsOptions struct {
+	Accounts       []string      `long:"accounts" description:"Accounts to request, comma separated or repeatable"`
+	All            bool          `long:"all" description:"Request every account in the credentials set"`
+	Match          []string      `long:"match" description:"Select accounts with KEY=VALUE for account, type, service, subdomain, or grantType, e.g. service=zoom. Account values may use * wildcards. Repeatable, all must match"`
+	Concurrency    int           `long:"concurrency" description:"Number of accounts to request at once" default:"4"`
+	AccountTimeout time.Duration `long:"account-timeout" description:"Time limit for each account including token requests and pages, 0 for none" default:"30s"`
+}
+
+// IsSet returns true if accounts are selected with `Accounts`, `All`, or `Match`.
+func (opts AccountsOptions) IsSet() bool {
+	return len(opts.Accounts) > 0 || opts.All || len(opts.Match) > 0
+}
+
+// SelectAccounts returns the `Accounts` in the set, or all accounts if `All` is set or only
+// `Match` is given, filtered by `Match`.
+func (opts AccountsOptions) SelectAccounts(set *CredentialsSet) ([]string, error) {
+	var keys []string
+	for _, v := range opts.Accounts {
+		for _, key := range strings.Split(v, ",") {
+			if key = strings.TrimSpace(key); key == "" {
+				continue
+			} else if _, err := set.Get(key); err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		if !opts.All && len(opts.Match) == 0 {
+			return nil, errors.New("no accounts selected")
+		}
+		keys = set.Keys()
+	}
+	out := []string{}
+	for _, key := range keys {
+		if ok, err := matchAccount(key, set.Credentials[key], opts.Match); err != nil {
+			return nil, err
+		} else if ok {
+			out = append(out, key)
+		}
+	}
+	return out, nil
+}
+
+// matchAccount returns true if the account matches every `KEY=VALUE` filter.
+func matchAccount(account string, creds Credentials, filters []string) (bool, error) {
+	for _, f := range filters {
+		k, want, ok := strings.Cut(f, "=")
+		if !ok {
+			return false, fmt.Errorf("match must be KEY=VALUE (%s)", f)
+		}
+		want = strings.TrimSpace(want)
+		var got string
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "account":
+			if ok, err := path.Match(want, account); err != nil {
+				return false, fmt.Errorf("match account pattern (%s): %w", want, err)
+			} else if !ok {
+				return false, nil
+			}
+			continue
+		case "type":
+			got = creds.Type
+		case "service":
+			got = creds.Service
+		case "subdomain":
+			got = creds.Subdomain
+		case "granttype", "grant_type":
+			if creds.OAuth2 != nil {
+				got = creds.OAuth2.GrantType
+			}
+		default:
+			return false, fmt.Errorf("match key not supported (%s)", k)
+		}
+		if !strings.EqualFold(strings.TrimSpace(got), want) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// AccountResult is the outcome of a request for one account. `Body` is the response body,
+// an array of page bodies if `FollowPages` is set, or an array of the values selected by
+// `Query` across pages. `StatusCode` is that of the last page.
+type AccountResult struct {
+	Account    string `json:"account"`
+	Status     string `json:"status"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Pages      int    `json:"pages,omitempty"`
+	DurationMS int64  `json:"durationMs"`
+	Body       any    `json:"body,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// OK returns true if the request succeeded for the account.
+func (res AccountResult) OK() bool { return res.Status == AccountStatusOK }
+
+// AccountResults are the results of `CLIRequest.DoAccounts()` in account order.
+type AccountResults []AccountResult
+
+// Failed returns the number of accounts whose request did not succeed.
+func (results AccountResults) Failed() int {
+	n := 0
+	for _, res := range results {
+		if !res.OK() {
+			n++
+		}
+	}
+	return n
+}
+
+// Write writes the results as an indented JSON array for `OutputJSON`, one compact result
+// per line for `OutputNDJSON`, or a table with one row per account for `OutputText`. The
+// table includes the `Query` values when `query` is set.
+func (results AccountResults) Write(w io.Writer, output string, query bool) error {
+	switch strings.ToLower(strings.TrimSpace(output)) {
+	case OutputJSON:
+		return encodeJSON(w, results, "  ")
+	case OutputNDJSON:
+		for _, res := range results {
+			if err := encodeJSON(w, res, ""); err != nil {
+				return err
+			}
+		}
+		return nil
+	case OutputText, "":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		header := "ACCOUNT\tSTATUS\tCODE\tPAGES\tTIME\tERROR"
+		if query {
+			header += "\tRESULT"
+		}
+		fmt.Fprintln(tw, header)
+		for _, res := range results {
+			code := ""
+			if res.StatusCode > 0 {
+				code = fmt.Sprintf("%d", res.StatusCode)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s", res.Account, res.Status, code, res.Pages,
+				(time.Duration(res.DurationMS) * time.Millisecond).String(), singleLine(res.Error))
+			if query {
+				b, _ := json.Marshal(res.Body)
+				fmt.Fprintf(tw, "\t%s", string(b))
+			}
+			fmt.Fprintln(tw)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("%w with multiple accounts (%s)", ErrOutputNotSupported, output)
+	}
+}
+
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// DoAccounts executes the request for each account concurrently, with at most `Concurrency`
+// accounts at once and `AccountTimeout` for each. Clients are created without user
+// interaction from the cached token or the `CredsPath` account. Account failures are
+// reported in the results rather than as an error.
+func (cli CLIRequest) DoAccounts(ctx context.Context, opts AccountsOptions) (AccountResults, error) {
+	set, err := cli.CredentialsSet(true)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := opts.SelectAccounts(set)
+	if err != nil {
+		return nil, err
+	}
+	req, err := cli.Request.Request()
+	if err != nil {
+		return nil, err
+	}
+	var steps []pathStep
+	if strings.TrimSpace(cli.Response.Query) != "" {
+		if steps, err = parsePath(cli.Response.Query); err != nil {
+			return nil, err
+		}
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultAccountsConcurrency
+	}
+	results := make(AccountResults, len(accounts))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(concurrency, len(accounts)) {
+		wg.Go(func() {
+			for i := range jobs {
+				results[i] = cli.doAccount(ctx, accounts[i], req, steps, opts.AccountTimeout)
+			}
+		})
+	}
+	for i := range accounts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results, nil
+}
+
+// doAccount executes the request for one account.
+func (cli CLIRequest) doAccount(ctx context.Context, account string, req httpsimple.Request, steps []pathStep, timeout time.Duration) AccountResult {
+	start := time.Now()
+	res := AccountResult{Account: account}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req.Headers, req.Query = req.Headers.Clone(), maps.Clone(req.Query)
+	file := FileProvider{Filename: cli.CredsPath, Account: account, Cache: cli.TokenCache()}
+	var bodies []any
+	clt, _, err := ProviderChain{CachedTokenProvider{FileProvider: file}, file}.Resolve(ctx)
+	if err == nil {
+		err = cli.Response.pages(ctx, clt, req, func(resp *http.Response, b []byte) error {
+			res.Pages++
+			res.StatusCode = resp.StatusCode
+			if resp.StatusCode >= http.StatusBadRequest {
+				vals, _ := responseValues(b, nil)
+				bodies = append(bodies, vals...)
+				return errStopPages
+			}
+			vals, err := responseValues(b, steps)
+			bodies = append(bodies, vals...)
+			return err
+		})
+	}
+	res.DurationMS = time.Since(start).Milliseconds()
+	switch {
+	case err != nil && (errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil):
+		res.Status, res.Error = AccountStatusTimeout, err.Error()
+	case err != nil:
+		res.Status, res.Error = AccountStatusError, err.Error()
+	case res.StatusCode >= http.StatusBadRequest:
+		res.Status = AccountStatusHTTPError
+	default:
+		res.Status = AccountStatusOK
+	}
+	if steps != nil || cli.Response.FollowPages {
+		if bodies == nil {
+			bodies = []any{}
+		}
+		res.Body = bodies
+	} else if len(bodies) > 0 {
+		res.Body = bodies[0]
+	}
+	return res
+}
+
+// responseValues returns the body as a JSON value or string, or the values `steps` selects.
+func responseValues(b []byte, steps []pathStep) ([]any, error) {
+	v, err := decodeJSON(b)
+	if err != nil {
+		if steps != nil {
+			return nil, fmt.Errorf("response body is not JSON: %w", err)
+		} else if len(b) == 0 {
+			return []any{}, nil
+		}
+		return []any{string(b)}, nil
+	} else if steps != nil {
+		return evalPath(v, steps)
+	}
+	return []any{v}, nil
+}
@@ -0,0 +1,99 @@
+package goauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/grokify/mogo/net/http/httpsimple"
+)
+
+func accountsTestSet(serverURL string) *CredentialsSet {
+	hq := func(service, key string) Credentials {
+		return Credentials{Service: service, Type: TypeHeaderQuery, HeaderQuery: &CredentialsHeaderQuery{
+			ServerURL: serverURL,
+			Header:    http.Header{"X-Key": []string{key}}}}
+	}
+	return &CredentialsSet{Credentials: map[string]Credentials{
+		"zoom-a": hq("zoom", "a"),
+		"zoom-b": hq("zoom", "b"),
+		"zoom-x": hq("zoom", "bad"),
+		"rc-a":   hq("ringcentral", "a")}}
+}
+
+var selectAccountsTests = []struct {
+	opts AccountsOptions
+	want string
+}{
+	{AccountsOptions{All: true}, "rc-a,zoom-a,zoom-b,zoom-x"},
+	{AccountsOptions{Match: []string{"service=zoom"}}, "zoom-a,zoom-b,zoom-x"},
+	{AccountsOptions{Match: []string{"service=Zoom", "account=*-[ab]"}}, "zoom-a,zoom-b"},
+	{AccountsOptions{Accounts: []string{"zoom-b,rc-a"}}, "zoom-b,rc-a"},
+	{AccountsOptions{Accounts: []string{"zoom-b", "rc-a"}, Match: []string{"type=headerquery", "service=zoom"}}, "zoom-b"},
+}
+
+func TestSelectAccounts(t *testing.T) {
+	set := accountsTestSet("")
+	for _, tt := range selectAccountsTests {
+		got, err := tt.opts.SelectAccounts(set)
+		if err != nil {
+			t.Errorf("AccountsOptions.SelectAccounts(%v): err [%v]", tt.opts, err)
+		} else if strings.Join(got, ",") != tt.want {
+			t.Errorf("AccountsOptions.SelectAccounts(%v): want [%s], got [%s]", tt.opts, tt.want, strings.Join(got, ","))
+		}
+	}
+	if _, err := (AccountsOptions{Accounts: []string{"zoom-z"}}).SelectAccounts(set); err == nil {
+		t.Errorf("AccountsOptions.SelectAccounts(zoom-z): want error, got nil")
+	}
+}
+
+func TestDoAccounts(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get("X-Key"); key == "bad" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"unauthorized"}`))
+		} else {
+			_, _ = w.Write([]byte(`{"users":[{"id":"` + key + `1"},{"id":"` + key + `2"}]}`))
+		}
+	}))
+	defer svr.Close()
+	filename := filepath.Join(t.TempDir(), "creds.json")
+	if err := accountsTestSet(svr.URL).WriteFile(filename, "", "  ", 0600); err != nil {
+		t.Fatalf("CredentialsSet.WriteFile(): err [%v]", err)
+	}
+	cli := CLIRequest{
+		Options:  Options{CredsPath: filename, NoCache: true},
+		Request:  httpsimple.CLI{Method: http.MethodGet, URL: svr.URL + "/users"},
+		Response: ResponseOptions{Query: ".users[].id"}}
+	results, err := cli.DoAccounts(context.Background(), AccountsOptions{Match: []string{"service=zoom"}, Concurrency: 2})
+	if err != nil {
+		t.Fatalf("CLIRequest.DoAccounts(): err [%v]", err)
+	}
+	want := []struct {
+		account string
+		status  string
+		body    []any
+	}{
+		{"zoom-a", AccountStatusOK, []any{"a1", "a2"}},
+		{"zoom-b", AccountStatusOK, []any{"b1", "b2"}},
+		{"zoom-x", AccountStatusHTTPError, nil},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("CLIRequest.DoAccounts(): want [%d] results, got [%d]", len(want), len(results))
+	}
+	for i, w := range want {
+		res := results[i]
+		if res.Account != w.account || res.Status != w.status {
+			t.Errorf("CLIRequest.DoAccounts()[%d]: want [%s %s], got [%s %s]", i, w.account, w.status, res.Account, res.Status)
+		} else if body, _ := res.Body.([]any); w.body != nil && !slices.Equal(body, w.body) {
+			t.Errorf("CLIRequest.DoAccounts()[%d]: want [%v], got [%v]", i, w.body, res.Body)
+		}
+	}
+	if results.Failed() != 1 {
+		t.Errorf("AccountResults.Failed(): want [1], got [%d]", results.Failed())
+	}
+}
@@ -0,0 +1,121 @@
+package goauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grokify/mogo/net/http/httpsimple"
+)
+
+var queryTests = []struct {
+	body  string
+	query string
+	want  string
+}{
+	{`{"data":[{"id":1},{"id":2}]}`, `.data[].id`, `[1,2]`},
+	{`{"data":[{"id":1},{"id":2}]}`, `$.data[*].id`, `[1,2]`},
+	{`{"data":[{"id":1},{"id":2}]}`, `.data[-1]`, `[{"id":2}]`},
+	{`{"meta":{"next.cursor":"abc"}}`, `.meta["next.cursor"]`, `["abc"]`},
+	{`{"meta":{}}`, `.meta.next.page`, `[null]`},
+	{`[1,2]`, `.`, `[[1,2]]`},
+}
+
+func TestQueryJSON(t *testing.T) {
+	for _, tt := range queryTests {
+		steps, err := parsePath(tt.query)
+		if err != nil {
+			t.Fatalf("parsePath(%s): err [%v]", tt.query, err)
+		}
+		v, err := decodeJSON([]byte(tt.body))
+		if err != nil {
+			t.Fatalf("decodeJSON(%s): err [%v]", tt.body, err)
+		}
+		vals, err := evalPath(v, steps)
+		if err != nil {
+			t.Fatalf("evalPath(%s, %s): err [%v]", tt.body, tt.query, err)
+		}
+		if b, err := json.Marshal(vals); err != nil {
+			t.Errorf("json.Marshal(%v): err [%v]", vals, err)
+		} else if string(b) != tt.want {
+			t.Errorf("evalPath(%s, %s): want [%s], got [%s]", tt.body, tt.query, tt.want, string(b))
+		}
+	}
+}
+
+// pagesHandler serves three pages of `{"items":[...]}`, linking pages with a `Link` header,
+// a `next` URL, or a `meta.cursor` cursor.
+func pagesHandler(mode string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page := 1
+		if c := r.URL.Query().Get("page"); c != "" {
+			fmt.Sscanf(c, "%d", &page)
+		} else if c := r.URL.Query().Get("after"); c != "" {
+			fmt.Sscanf(c, "p%d", &page)
+		}
+		if mode == "error" && page == 2 {
+			http.Error(w, `{"error":"server"}`, http.StatusInternalServerError)
+			return
+		}
+		body := map[string]any{"items": []int{page*2 - 1, page * 2}}
+		if page < 3 {
+			switch mode {
+			case "offsite":
+				w.Header().Set("Link", fmt.Sprintf(`<https://evil.example.com/items?page=%d>; rel="next"`, page+1))
+			case "link", "error":
+				w.Header().Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next", </items?page=1>; rel="first"`, page+1))
+			case "next":
+				body["next"] = fmt.Sprintf("/items?page=%d", page+1)
+			case "cursor":
+				body["meta"] = map[string]string{"cursor": fmt.Sprintf("p%d", page+1)}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	}
+}
+
+var pagesTests = []struct {
+	mode    string
+	opts    ResponseOptions
+	output  string
+	want    string
+	wantErr bool
+}{
+	{"link", ResponseOptions{FollowPages: true, Query: ".items[]"}, OutputNDJSON, "1\n2\n3\n4\n5\n6\n", false},
+	{"next", ResponseOptions{FollowPages: true, Query: ".items[0]"}, OutputRaw, "1\n3\n5\n", false},
+	{"cursor", ResponseOptions{FollowPages: true, CursorPath: "$.meta.cursor", CursorParam: "after", Query: ".items[1]"}, OutputNDJSON, "2\n4\n6\n", false},
+	{"link", ResponseOptions{FollowPages: true, MaxPages: 2, Query: ".items[0]"}, OutputNDJSON, "1\n3\n", false},
+	{"link", ResponseOptions{Query: ".items[0]"}, OutputNDJSON, "1\n", false},
+	{"link", ResponseOptions{}, OutputNDJSON, `{"items":[1,2]}` + "\n", false},
+	{"link", ResponseOptions{Query: ".items"}, OutputText, "", true},
+	{"offsite", ResponseOptions{FollowPages: true, Query: ".items[0]"}, OutputNDJSON, "1\n", true},
+	{"error", ResponseOptions{FollowPages: true, Query: ".items[0]"}, OutputRaw, "1\n", true},
+}
+
+func TestResponseOptionsDo(t *testing.T) {
+	for _, tt := range pagesTests {
+		srv := httptest.NewServer(pagesHandler(tt.mode))
+		var buf bytes.Buffer
+		err := tt.opts.Do(context.Background(), srv.Client(), httpsimple.Request{URL: srv.URL + "/items"}, tt.output, &buf)
+		srv.Close()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ResponseOptions.Do(%s, %s): want error, got nil", tt.mode, tt.output)
+			} else if tt.want != "" && !strings.HasPrefix(buf.String(), tt.want) {
+				t.Errorf("ResponseOptions.Do(%s, %s): want output [%s], got [%s]", tt.mode, tt.output, strings.TrimSpace(tt.want), buf.String())
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("ResponseOptions.Do(%s, %s): err [%v]", tt.mode, tt.output, err)
+		} else if buf.String() != tt.want {
+			t.Errorf("ResponseOptions.Do(%s, %s): want [%s], got [%s]", tt.mode, tt.output,
+				strings.TrimSpace(tt.want), strings.TrimSpace(buf.String()))
+		}
+	}
+}
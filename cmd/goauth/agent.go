@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/grokify/goauth/agent"
+)
+
+const providerNameAgent = "agent"
+
+// agentOptions are the flags shared by `agent` subcommands.
+type agentOptions struct {
+	Socket string `long:"socket" env:"GOAUTH_AGENT_SOCK" description:"Agent socket path, defaults to goauth/agent.sock in XDG_RUNTIME_DIR or the temporary directory"`
+}
+
+func (o agentOptions) client() *agent.Client {
+	return agent.NewClient(o.Socket)
+}
+
+type agentStartCommand struct {
+	agentOptions
+	RefreshBefore time.Duration `long:"refresh-before" description:"Refresh tokens this long before they expire" default:"5m"`
+	Preload       bool          `long:"preload" description:"Get tokens for --account, or all accounts, at startup so they are refreshed before first use"`
+	g             *globalOptions
+}
+
+func (cmd *agentStartCommand) Execute(args []string) error {
+	if cmd.g.CredsPath == "" {
+		return usageErrorf("--creds is required")
+	}
+	set, err := cmd.g.CredentialsSet(true)
+	if err != nil {
+		return err
+	}
+	a := agent.New(set, slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	a.Filename = cmd.g.CredsPath
+	a.Cache = cmd.g.TokenCache()
+	a.RefreshBefore = cmd.RefreshBefore
+	socket := cmd.Socket
+	if socket == "" {
+		socket = agent.DefaultSocketPath()
+	}
+	l, err := agent.Listen(socket)
+	if err != nil {
+		return configError(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if cmd.Preload {
+		accounts := set.Keys()
+		if cmd.g.Account != "" {
+			accounts = []string{cmd.g.Account}
+		}
+		for _, account := range accounts {
+			if _, err := a.Token(ctx, account, false); err != nil {
+				a.Logger.Warn("preload failed", "account", account, "error", err.Error())
+			}
+		}
+	}
+	fmt.Printf("%s=%s; export %s;\n", agent.EnvSocket, socket, agent.EnvSocket)
+	a.Logger.Info("agent started", "socket", socket, "accounts", len(set.Credentials))
+	return a.Serve(ctx, l)
+}
+
+type agentTokenCommand struct {
+	agentOptions
+	Refresh bool `long:"refresh" description:"Get a new token instead of the agent's current token"`
+	g       *globalOptions
+}
+
+func (cmd *agentTokenCommand) Execute(args []string) error {
+	if cmd.g.Account == "" {
+		return usageErrorf("--account is required")
+	}
+	c := cmd.client()
+	get := c.Token
+	if cmd.Refresh {
+		get = c.Refresh
+	}
+	tok, err := get(context.Background(), cmd.g.Account)
+	if err != nil {
+		return err
+	}
+	return cmd.g.printToken(tok, providerNameAgent)
+}
+
+type agentListCommand struct {
+	agentOptions
+	g *globalOptions
+}
+
+func (cmd *agentListCommand) Execute(args []string) error {
+	accounts, err := cmd.client().Accounts(context.Background())
+	if err != nil {
+		return err
+	}
+	return cmd.g.print(accounts, func(w io.Writer) error {
+		for _, account := range accounts {
+			if _, err := fmt.Fprintln(w, account); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type agentStopCommand struct {
+	agentOptions
+}
+
+func (cmd *agentStopCommand) Execute(args []string) error {
+	return cmd.client().Stop(context.Background())
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/grokify/goauth/credhelper"
+)
+
+// Credential helper executable name prefixes. A link to goauth with one of these names runs
+// the matching `credential-helper` subcommand, e.g. `docker-credential-goauth get`.
+const (
+	gitHelperPrefix    = "git-credential-"
+	dockerHelperPrefix = "docker-credential-"
+)
+
+// helperArgs returns the command line arguments, prefixed with the `credential-helper`
+// subcommand when goauth is run as a git or Docker credential helper executable.
+func helperArgs(args []string) []string {
+	name := strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+	switch {
+	case strings.HasPrefix(name, gitHelperPrefix):
+		return append([]string{"credential-helper", "git"}, args[1:]...)
+	case strings.HasPrefix(name, dockerHelperPrefix):
+		return append([]string{"credential-helper", "docker"}, args[1:]...)
+	default:
+		return args[1:]
+	}
+}
+
+type credentialHelperOptions struct {
+	Host     []string `long:"host" description:"Use an account for a host as HOST=ACCOUNT, repeatable. Defaults to --account if its server URL has the host, or the account with the host's server URL"`
+	Username string   `long:"username" description:"Username returned with access tokens, defaults to the account's username or oauth2"`
+}
+
+func (o credentialHelperOptions) helper(g *globalOptions) (credhelper.Helper, error) {
+	if g.CredsPath == "" {
+		return credhelper.Helper{}, usageErrorf("--creds or GOAUTH_CREDS is required")
+	}
+	h := credhelper.Helper{
+		Filename: g.CredsPath,
+		Account:  g.Account,
+		Hosts:    map[string]string{},
+		Username: o.Username,
+		Cache:    g.TokenCache()}
+	for _, kv := range o.Host {
+		host, account, ok := strings.Cut(kv, "=")
+		if !ok || strings.TrimSpace(host) == "" || strings.TrimSpace(account) == "" {
+			return h, usageErrorf("--host must be HOST=ACCOUNT (%s)", kv)
+		}
+		h.Hosts[credhelper.HostOf(host)] = strings.TrimSpace(account)
+	}
+	return h, nil
+}
+
+func helperAction(args []string, actions string) (string, error) {
+	if len(args) == 0 {
+		return "", usageErrorf("action is required: %s", actions)
+	}
+	return args[0], nil
+}
+
+type credentialHelperGitCommand struct {
+	credentialHelperOptions
+	g *globalOptions
+}
+
+func (cmd *credentialHelperGitCommand) Execute(args []string) error {
+	action, err := helperAction(args, "get, store, or erase")
+	if err != nil {
+		return err
+	}
+	h, err := cmd.helper(cmd.g)
+	if err != nil {
+		return err
+	}
+	c, err := credhelper.ReadGitCredential(os.Stdin)
+	if err != nil {
+		return err
+	}
+	switch action {
+	case "get":
+		cred, err := h.Get(context.Background(), c.ServerURL())
+		if errors.Is(err, credhelper.ErrNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		c.SetCredential(cred)
+		return c.Write(os.Stdout)
+	case "store":
+		return h.Store(c.ServerURL(), c[credhelper.GitAttrUsername], c[credhelper.GitAttrPassword])
+	case "erase":
+		if err := h.Erase(c.ServerURL(), c[credhelper.GitAttrPassword]); !errors.Is(err, credhelper.ErrNotFound) {
+			return err
+		}
+		return nil
+	default:
+		// git requires helpers to ignore unknown actions.
+		return nil
+	}
+}
+
+type credentialHelperDockerCommand struct {
+	credentialHelperOptions
+	g *globalOptions
+}
+
+// Execute runs a Docker credential helper action. Errors are written to stdout for Docker.
+func (cmd *credentialHelperDockerCommand) Execute(args []string) error {
+	if err := cmd.execute(args); err != nil {
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			return err
+		}
+		fmt.Fprintln(os.Stdout, err.Error())
+		return &exitCodeError{code: exitError}
+	}
+	return nil
+}
+
+func (cmd *credentialHelperDockerCommand) execute(args []string) error {
+	action, err := helperAction(args, "get, store, erase, list, or version")
+	if err != nil {
+		return err
+	}
+	h, err := cmd.helper(cmd.g)
+	if err != nil {
+		return err
+	}
+	readServerURL := func() (string, error) {
+		s, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		} else if s = strings.TrimSpace(s); s == "" {
+			return "", errors.New("no credentials server URL")
+		}
+		return s, nil
+	}
+	enc := json.NewEncoder(os.Stdout)
+	switch action {
+	case "get":
+		serverURL, err := readServerURL()
+		if err != nil {
+			return err
+		}
+		cred, err := h.Get(context.Background(), serverURL)
+		if errors.Is(err, credhelper.ErrNotFound) {
+			return errors.New(credhelper.DockerErrCredentialsNotFound)
+		} else if err != nil {
+			return err
+		}
+		return enc.Encode(credhelper.DockerCredentials{ServerURL: serverURL, Username: cred.Username, Secret: cred.Secret})
+	case "store":
+		var dc credhelper.DockerCredentials
+		if err := json.NewDecoder(os.Stdin).Decode(&dc); err != nil {
+			return err
+		}
+		return h.Store(dc.ServerURL, dc.Username, dc.Secret)
+	case "erase":
+		serverURL, err := readServerURL()
+		if err != nil {
+			return err
+		} else if err := h.Erase(serverURL, ""); errors.Is(err, credhelper.ErrNotFound) {
+			return errors.New(credhelper.DockerErrCredentialsNotFound)
+		} else {
+			return err
+		}
+	case "list":
+		list, err := h.List()
+		if err != nil {
+			return err
+		}
+		return enc.Encode(list)
+	case "version":
+		_, err := fmt.Fprintln(os.Stdout, "goauth credential helper")
+		return err
+	default:
+		return fmt.Errorf("unknown credential action `%s`", action)
+	}
+}
+
+type credentialHelperExecCommand struct {
+	credentialHelperOptions
+	APIVersion string `long:"api-version" description:"ExecCredential apiVersion, defaults to KUBERNETES_EXEC_INFO or client.authentication.k8s.io/v1"`
+	g          *globalOptions
+}
+
+func (cmd *credentialHelperExecCommand) Execute(args []string) error {
+	if err := cmd.g.requireAccount(); err != nil {
+		return err
+	}
+	h, err := cmd.helper(cmd.g)
+	if err != nil {
+		return err
+	}
+	cred, err := h.GetAccount(context.Background(), cmd.g.Account)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(credhelper.NewExecCredential(cred, cmd.APIVersion))
+}
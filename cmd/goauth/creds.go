@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/grokify/goauth"
+)
+
+type credsAccount struct {
+	Account   string `json:"account"`
+	Type      string `json:"type,omitempty"`
+	Service   string `json:"service,omitempty"`
+	GrantType string `json:"grantType,omitempty"`
+}
+
+type credsListCommand struct {
+	g *globalOptions
+}
+
+func (cmd *credsListCommand) Execute(args []string) error {
+	if cmd.g.CredsPath == "" {
+		return usageErrorf("--creds is required")
+	}
+	set, err := cmd.g.CredentialsSet(false)
+	if err != nil {
+		return err
+	}
+	accounts := []credsAccount{}
+	for _, key := range set.Keys() {
+		creds := set.Credentials[key]
+		acct := credsAccount{Account: key, Type: creds.Type, Service: creds.Service}
+		if creds.OAuth2 != nil {
+			acct.GrantType = creds.OAuth2.GrantType
+		}
+		accounts = append(accounts, acct)
+	}
+	return cmd.g.print(accounts, func(w io.Writer) error {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ACCOUNT\tTYPE\tSERVICE\tGRANT TYPE")
+		for _, acct := range accounts {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", acct.Account, acct.Type, acct.Service, acct.GrantType)
+		}
+		return tw.Flush()
+	})
+}
+
+type credsShowCommand struct {
+	Reveal bool `long:"reveal" description:"Print secrets instead of redacting them"`
+	g      *globalOptions
+}
+
+func (cmd *credsShowCommand) Execute(args []string) error {
+	if err := cmd.g.requireAccount(); err != nil {
+		return err
+	}
+	creds, err := cmd.g.Credentials()
+	if err != nil {
+		return err
+	}
+	if !cmd.Reveal {
+		creds = creds.Redacted()
+	}
+	return cmd.g.print(creds, nil)
+}
+
+type credsValidation struct {
+	Account string   `json:"account"`
+	Valid   bool     `json:"valid"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+type credsValidateCommand struct {
+	g *globalOptions
+}
+
+func (cmd *credsValidateCommand) Execute(args []string) error {
+	if cmd.g.CredsPath == "" {
+		return usageErrorf("--creds is required")
+	}
+	set, err := cmd.g.CredentialsSet(true)
+	if err != nil {
+		return err
+	}
+	keys := set.Keys()
+	if cmd.g.Account != "" {
+		if _, err := set.Get(cmd.g.Account); err != nil {
+			return err
+		}
+		keys = []string{cmd.g.Account}
+	}
+	results := []credsValidation{}
+	invalid := 0
+	for _, key := range keys {
+		creds := set.Credentials[key]
+		res := credsValidation{Account: key, Valid: true}
+		if err := creds.Validate(); err != nil {
+			res.Valid = false
+			invalid++
+			var joined interface{ Unwrap() []error }
+			if errors.As(err, &joined) {
+				for _, e := range joined.Unwrap() {
+					res.Errors = append(res.Errors, e.Error())
+				}
+			} else {
+				res.Errors = []string{err.Error()}
+			}
+		}
+		results = append(results, res)
+	}
+	if err := cmd.g.print(results, func(w io.Writer) error {
+		for _, res := range results {
+			if res.Valid {
+				fmt.Fprintf(w, "%s: ok\n", res.Account)
+				continue
+			}
+			for _, e := range res.Errors {
+				fmt.Fprintf(w, "%s: %s\n", res.Account, e)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	} else if invalid > 0 {
+		return configError(fmt.Errorf("%d of %d accounts invalid", invalid, len(results)))
+	}
+	return nil
+}
+
+type credsImportCommand struct {
+	File        string `long:"file" description:"Credentials set file to import from"`
+	FileAccount string `long:"file-account" description:"Account in --file, defaults to --account"`
+	EnvPrefix   string `long:"env-prefix" description:"Import from environment variables with this prefix, e.g. MYAPP_"`
+	g           *globalOptions
+}
+
+func (cmd *credsImportCommand) Execute(args []string) error {
+	if err := cmd.g.requireAccount(); err != nil {
+		return err
+	}
+	var creds goauth.Credentials
+	switch {
+	case cmd.File != "" && cmd.EnvPrefix != "":
+		return usageErrorf("only one of --file and --env-prefix can be set")
+	case cmd.File != "":
+		if c, err := goauth.NewCredentialsFromSetFile(cmd.File, firstNonEmpty(cmd.FileAccount, cmd.g.Account), true); err != nil {
+			return err
+		} else {
+			creds = c
+		}
+	case cmd.EnvPrefix != "":
+		if c, ok := goauth.NewCredentialsEnv(cmd.EnvPrefix); !ok {
+			return configError(fmt.Errorf("no credentials environment variables with prefix `%s`", cmd.EnvPrefix))
+		} else {
+			creds = c
+		}
+	default:
+		return usageErrorf("one of --file or --env-prefix is required")
+	}
+	set := goauth.CredentialsSet{Credentials: map[string]goauth.Credentials{cmd.g.Account: creds}}
+	if err := set.WriteFileMerge(cmd.g.CredsPath, "", "  ", 0600); err != nil {
+		return err
+	}
+	return cmd.g.print(map[string]any{"account": cmd.g.Account, "file": cmd.g.CredsPath}, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Imported account `%s` to %s\n", cmd.g.Account, cmd.g.CredsPath)
+		return err
+	})
+}
+
+type credsExportCommand struct {
+	goauth.ExportOptions
+	g *globalOptions
+}
+
+func (cmd *credsExportCommand) Execute(args []string) error {
+	if err := cmd.g.requireAccount(); err != nil {
+		return err
+	} else if creds, err := cmd.g.Credentials(); err != nil {
+		return err
+	} else {
+		return creds.Export(context.Background(), os.Stdout, cmd.ExportOptions)
+	}
+}
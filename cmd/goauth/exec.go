@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Environment variables set for `exec` child processes.
+const (
+	envAccessToken     = "GOAUTH_ACCESS_TOKEN"
+	envAuthHeader      = "GOAUTH_AUTH_HEADER"
+	envTokenExpiry     = "GOAUTH_TOKEN_EXPIRY"
+	envAccessTokenFile = "GOAUTH_ACCESS_TOKEN_FILE"
+)
+
+// Actions when an `exec` child process outlives its token.
+const (
+	onExpiryRestart = "restart"
+	onExpirySignal  = "signal"
+	onExpiryNone    = "none"
+)
+
+// Waits before retrying a failed token refresh. The wait doubles after each consecutive
+// failure up to `execRetryMax`.
+const (
+	execRetryInterval = 30 * time.Second
+	execRetryMax      = 10 * time.Minute
+)
+
+// execUnchangedRetryBefore is how long before expiry to retry a refresh which returned the
+// current token. `oauth2.Token.Valid()` no longer accepts a token this close to expiry, so
+// token caches do not return it.
+const execUnchangedRetryBefore = 5 * time.Second
+
+// errTokenUnchanged is returned by `execCommand.refresh()` when the credentials source
+// returns the current token, e.g. an unexpired cached token.
+var errTokenUnchanged = errors.New("credentials source returned the same token")
+
+// forwardSignals are the signals `exec` relays to the child process.
+var forwardSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+var signalNames = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+	"KILL": syscall.SIGKILL,
+}
+
+func parseSignal(name string) (syscall.Signal, error) {
+	name = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "SIG")
+	if sig, ok := signalNames[name]; ok {
+		return sig, nil
+	}
+	return 0, usageErrorf("signal not supported (%s)", name)
+}
+
+type execCommand struct {
+	TokenEnv      []string      `long:"token-env" description:"Additional variable for the access token, repeatable"`
+	HeaderEnv     []string      `long:"header-env" description:"Additional variable for the Authorization header value, repeatable"`
+	OnExpiry      string        `long:"on-expiry" description:"Action when the process outlives the token" choice:"restart" choice:"signal" choice:"none" default:"restart"`
+	Signal        string        `long:"signal" description:"Signal that stops the process to restart it, or notifies it of a new token file, defaults to TERM or HUP"`
+	RefreshBefore time.Duration `long:"refresh-before" description:"Refresh this long before the token expires" default:"1m"`
+	StopTimeout   time.Duration `long:"stop-timeout" description:"Wait before killing a process being restarted" default:"10s"`
+	g             *globalOptions
+}
+
+func (cmd *execCommand) Execute(args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("command is required, e.g. goauth exec --account my-app -- command args")
+	}
+	sig, err := parseSignal(firstNonEmpty(cmd.Signal, map[string]string{onExpirySignal: "HUP"}[cmd.OnExpiry], "TERM"))
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	tok, _, err := cmd.g.NewToken(ctx, "")
+	if err != nil {
+		return err
+	}
+	tokenFile := ""
+	if cmd.OnExpiry == onExpirySignal {
+		if tokenFile, err = writeTokenFile("", tok); err != nil {
+			return err
+		}
+		defer os.Remove(tokenFile)
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, forwardSignals...)
+	defer signal.Stop(sigs)
+
+	for {
+		child := exec.Command(args[0], args[1:]...) // #nosec G204
+		child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+		child.Env = cmd.environ(tok, tokenFile)
+		if err := child.Start(); err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				return &exitCodeError{code: 127, err: err}
+			}
+			return err
+		}
+		done := make(chan error, 1)
+		go func() { done <- child.Wait() }()
+		restart, failures := false, 0
+		refreshAt := cmd.refreshAt(tok)
+		for !restart {
+			timer := refreshTimer(refreshAt)
+			select {
+			case err := <-done:
+				stopTimer(timer)
+				return childExit(child, err)
+			case s := <-sigs:
+				stopTimer(timer)
+				_ = child.Process.Signal(s)
+			case <-timerC(timer):
+				newTok, err := cmd.refresh(ctx, tok)
+				if err != nil {
+					failures++
+					refreshAt = retryAt(tok, err, failures)
+					fmt.Fprintf(os.Stderr, "goauth: token refresh failed, retrying in %s: %s\n",
+						time.Until(refreshAt).Round(time.Second), err.Error())
+					continue
+				}
+				failures = 0
+				tok, refreshAt = newTok, cmd.refreshAt(newTok)
+				if cmd.OnExpiry == onExpirySignal {
+					if _, err := writeTokenFile(tokenFile, tok); err != nil {
+						return err
+					}
+					_ = child.Process.Signal(sig)
+					continue
+				}
+				_ = child.Process.Signal(sig)
+				select {
+				case <-done:
+				case <-time.After(cmd.StopTimeout):
+					_ = child.Process.Kill()
+					<-done
+				}
+				restart = true
+			}
+		}
+	}
+}
+
+// environ returns the current environment with the token variables for `tok` added.
+func (cmd *execCommand) environ(tok *oauth2.Token, tokenFile string) []string {
+	header := tok.Type() + " " + tok.AccessToken
+	vars := map[string]string{
+		envAccessToken: tok.AccessToken,
+		envAuthHeader:  header}
+	if !tok.Expiry.IsZero() {
+		vars[envTokenExpiry] = tok.Expiry.UTC().Format(time.RFC3339)
+	}
+	if tokenFile != "" {
+		vars[envAccessTokenFile] = tokenFile
+	}
+	for _, name := range cmd.TokenEnv {
+		vars[name] = tok.AccessToken
+	}
+	for _, name := range cmd.HeaderEnv {
+		vars[name] = header
+	}
+	env := []string{}
+	for _, kv := range os.Environ() {
+		if k, _, _ := strings.Cut(kv, "="); vars[k] == "" {
+			env = append(env, kv)
+		}
+	}
+	for k, v := range vars {
+		env = append(env, k+"="+v)
+	}
+	return env
+}
+
+// refreshAt returns when to refresh `tok`: `RefreshBefore` it expires, but no earlier than
+// halfway to expiry so short-lived tokens are not refreshed continuously. It returns the zero
+// time if the token does not expire or `--on-expiry none` is set.
+func (cmd *execCommand) refreshAt(tok *oauth2.Token) time.Time {
+	if cmd.OnExpiry == onExpiryNone || tok.Expiry.IsZero() {
+		return time.Time{}
+	}
+	now := time.Now()
+	half := now.Add(max(tok.Expiry.Sub(now)/2, time.Second))
+	if at := tok.Expiry.Add(-cmd.RefreshBefore); at.After(half) {
+		return at
+	}
+	return half
+}
+
+// refreshTimer returns a timer that fires at `at`. It returns nil if `at` is zero.
+func refreshTimer(at time.Time) *time.Timer {
+	if at.IsZero() {
+		return nil
+	}
+	return time.NewTimer(time.Until(at))
+}
+
+// retryAt returns when to retry after `failures` consecutive failed refreshes of `tok`. If
+// the credentials source returned `tok` again, the retry waits until it is about to expire
+// instead of asking the source for the same token repeatedly.
+func retryAt(tok *oauth2.Token, err error, failures int) time.Time {
+	at := time.Now().Add(min(execRetryInterval<<min(failures-1, 10), execRetryMax))
+	if errors.Is(err, errTokenUnchanged) {
+		if unchangedAt := tok.Expiry.Add(-execUnchangedRetryBefore); unchangedAt.After(at) {
+			return unchangedAt
+		}
+	}
+	return at
+}
+
+// refresh gets a new token for the account, or from the credentials sources if no account
+// is set. It returns `errTokenUnchanged` if the new token does not expire later than `tok`.
+func (cmd *execCommand) refresh(ctx context.Context, tok *oauth2.Token) (*oauth2.Token, error) {
+	var newTok *oauth2.Token
+	var err error
+	if cmd.g.CredsPath != "" && cmd.g.Account != "" {
+		newTok, err = cmd.g.fileProvider().Refresh(ctx, "")
+	} else {
+		newTok, _, err = cmd.g.NewToken(ctx, "")
+	}
+	if err != nil {
+		return nil, err
+	} else if newTok.AccessToken == tok.AccessToken ||
+		(!newTok.Expiry.IsZero() && !newTok.Expiry.After(tok.Expiry)) {
+		return nil, errTokenUnchanged
+	}
+	return newTok, nil
+}
+
+// writeTokenFile writes the access token to `filename`, or to a new temporary file if empty,
+// and returns the file name.
+func writeTokenFile(filename string, tok *oauth2.Token) (string, error) {
+	if filename == "" {
+		f, err := os.CreateTemp("", "goauth-token-*")
+		if err != nil {
+			return "", err
+		}
+		filename = f.Name()
+		if err := f.Close(); err != nil {
+			return "", err
+		}
+	}
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, []byte(tok.AccessToken), 0600); err != nil {
+		return "", err
+	}
+	return filename, os.Rename(tmp, filename)
+}
+
+// childExit converts the child process exit status to an `exitCodeError`, using the shell
+// convention of 128 plus the signal number for processes ended by a signal.
+func childExit(child *exec.Cmd, err error) error {
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return err
+	}
+	if ws, ok := child.ProcessState.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return &exitCodeError{code: 128 + int(ws.Signal())}
+	} else if code := child.ProcessState.ExitCode(); code != 0 {
+		return &exitCodeError{code: code}
+	}
+	return nil
+}
+
+func timerC(t *time.Timer) <-chan time.Time {
+	if t == nil {
+		return nil
+	}
+	return t.C
+}
+
+func stopTimer(t *time.Timer) {
+	if t != nil {
+		t.Stop()
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/grokify/goauth"
+	"golang.org/x/oauth2"
+)
+
+func TestExecEnviron(t *testing.T) {
+	t.Setenv(envAccessToken, "stale")
+	t.Setenv("GOAUTH_EXEC_TEST", "kept")
+	cmd := &execCommand{TokenEnv: []string{"API_TOKEN"}, HeaderEnv: []string{"API_AUTH"}}
+	expiry := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	env := cmd.environ(&oauth2.Token{AccessToken: "abc", TokenType: "Bearer", Expiry: expiry}, "/tmp/token")
+	for _, want := range []string{
+		envAccessToken + "=abc",
+		envAuthHeader + "=Bearer abc",
+		envTokenExpiry + "=2030-01-02T03:04:05Z",
+		envAccessTokenFile + "=/tmp/token",
+		"API_TOKEN=abc",
+		"API_AUTH=Bearer abc",
+		"GOAUTH_EXEC_TEST=kept",
+	} {
+		if !slices.Contains(env, want) {
+			t.Errorf("execCommand.environ(): want [%s], got [%v]", want, env)
+		}
+	}
+	if slices.Contains(env, envAccessToken+"=stale") {
+		t.Errorf("execCommand.environ(): want [%s] replaced, got [%v]", envAccessToken, env)
+	}
+}
+
+var refreshAtTests = []struct {
+	onExpiry  string
+	expiresIn time.Duration
+	wantIn    time.Duration
+}{
+	{onExpiryRestart, time.Hour, time.Hour - time.Minute},
+	{onExpiryRestart, time.Minute, 30 * time.Second},
+	{onExpiryRestart, 0, 0},
+	{onExpiryNone, time.Hour, 0},
+}
+
+func TestExecRefreshAt(t *testing.T) {
+	for _, tt := range refreshAtTests {
+		cmd := &execCommand{OnExpiry: tt.onExpiry, RefreshBefore: time.Minute}
+		tok := &oauth2.Token{AccessToken: "abc"}
+		if tt.expiresIn > 0 {
+			tok.Expiry = time.Now().Add(tt.expiresIn)
+		}
+		at := cmd.refreshAt(tok)
+		if tt.wantIn == 0 {
+			if !at.IsZero() {
+				t.Errorf("execCommand.refreshAt(%s, %s): want [zero], got [%v]", tt.onExpiry, tt.expiresIn, at)
+			}
+		} else if got := time.Until(at); got > tt.wantIn || got < tt.wantIn-time.Second {
+			t.Errorf("execCommand.refreshAt(%s, %s): want [%s], got [%s]", tt.onExpiry, tt.expiresIn, tt.wantIn, got)
+		}
+	}
+}
+
+var retryAtTests = []struct {
+	err       error
+	failures  int
+	expiresIn time.Duration
+	wantIn    time.Duration
+}{
+	{errors.New("network"), 1, time.Hour, execRetryInterval},
+	{errors.New("network"), 3, time.Hour, 4 * execRetryInterval},
+	{errors.New("network"), 100, time.Hour, execRetryMax},
+	{errTokenUnchanged, 1, time.Hour, time.Hour - execUnchangedRetryBefore},
+	{errTokenUnchanged, 1, 10 * time.Second, execRetryInterval},
+}
+
+func TestExecRetryAt(t *testing.T) {
+	for _, tt := range retryAtTests {
+		tok := &oauth2.Token{AccessToken: "abc", Expiry: time.Now().Add(tt.expiresIn)}
+		if got := time.Until(retryAt(tok, tt.err, tt.failures)); got > tt.wantIn || got < tt.wantIn-time.Second {
+			t.Errorf("retryAt(%v, %d): want [%s], got [%s]", tt.err, tt.failures, tt.wantIn, got)
+		}
+	}
+}
+
+func TestExecRefreshUnchanged(t *testing.T) {
+	tok := &oauth2.Token{AccessToken: "cached", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour).Round(time.Second)}
+	b, err := json.Marshal(tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenFile := filepath.Join(t.TempDir(), "token.json")
+	if err := os.WriteFile(tokenFile, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(goauth.EnvPrefixDefault+goauth.EnvSuffixToken, "")
+	cmd := &execCommand{g: &globalOptions{Options: goauth.Options{TokenFile: tokenFile, NoCache: true}}}
+	if _, err := cmd.refresh(context.Background(), tok); !errors.Is(err, errTokenUnchanged) {
+		t.Errorf("execCommand.refresh(): want [%v], got [%v]", errTokenUnchanged, err)
+	}
+}
+
+var childExitTests = []struct {
+	script   string
+	wantCode int
+}{
+	{"exit 0", 0},
+	{"exit 3", 3},
+	{"kill -TERM $$", 143},
+	{"kill -KILL $$", 137},
+}
+
+func TestChildExit(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	for _, tt := range childExitTests {
+		child := exec.Command("sh", "-c", tt.script)
+		err := childExit(child, child.Run())
+		var exitErr *exitCodeError
+		if tt.wantCode == 0 {
+			if err != nil {
+				t.Errorf("childExit(%s): want [nil], got [%v]", tt.script, err)
+			}
+		} else if !errors.As(err, &exitErr) || exitErr.code != tt.wantCode {
+			t.Errorf("childExit(%s): want code [%d], got [%v]", tt.script, tt.wantCode, err)
+		}
+	}
+}
+
+var parseSignalTests = []struct {
+	name    string
+	want    syscall.Signal
+	wantErr bool
+}{
+	{"HUP", syscall.SIGHUP, false},
+	{"sigterm", syscall.SIGTERM, false},
+	{" SIGKILL ", syscall.SIGKILL, false},
+	{"USR1", 0, true},
+}
+
+func TestParseSignal(t *testing.T) {
+	for _, tt := range parseSignalTests {
+		if got, err := parseSignal(tt.name); (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseSignal(%s): want [%v], got [%v] err [%v]", tt.name, tt.want, got, err)
+		}
+	}
+}
+
+func TestWriteTokenFile(t *testing.T) {
+	filename, err := writeTokenFile("", &oauth2.Token{AccessToken: "abc"})
+	if err != nil {
+		t.Fatalf("writeTokenFile(): err [%v]", err)
+	}
+	defer os.Remove(filename)
+	if _, err := writeTokenFile(filename, &oauth2.Token{AccessToken: "def"}); err != nil {
+		t.Fatalf("writeTokenFile(%s): err [%v]", filename, err)
+	}
+	if b, err := os.ReadFile(filename); err != nil || string(b) != "def" {
+		t.Errorf("writeTokenFile(%s): want [def], got [%s] err [%v]", filename, b, err)
+	} else if fi, err := os.Stat(filename); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("writeTokenFile(%s): want mode [0600], got [%v] err [%v]", filename, fi, err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(filename), filepath.Base(filename)+".tmp")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("writeTokenFile(%s): want temporary file removed, got [%v]", filename, err)
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grokify/goauth"
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/goauth/endpoints"
+	"golang.org/x/oauth2"
+	"golang.org/x/term"
+)
+
+const defaultCredsPath = "credentials.json"
+
+type initCommand struct {
+	opts *goauth.Options
+}
+
+// Execute interactively builds an account and adds it to a credentials set file.
+func (cmd *initCommand) Execute(args []string) error {
+	p := newPrompter(os.Stdin, os.Stdout)
+	ctx := context.Background()
+
+	path, err := p.Ask("Credentials file", firstNonEmpty(cmd.opts.CredsPath, defaultCredsPath))
+	if err != nil {
+		return err
+	}
+	key, err := p.AskRequired("Account key", cmd.opts.Account)
+	if err != nil {
+		return err
+	}
+	if set, err := goauth.ReadFileCredentialsSet(path, false); err == nil {
+		if _, ok := set.Credentials[key]; ok {
+			if ok, err := p.Confirm(fmt.Sprintf("Account `%s` exists. Replace it?", key), false); err != nil {
+				return err
+			} else if !ok {
+				return errors.New("init cancelled")
+			}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	creds, err := askCredentials(p)
+	if err != nil {
+		return err
+	}
+
+	if creds.Type == goauth.TypeOAuth2 {
+		if ok, err := p.Confirm("Run the token flow now to verify?", true); err != nil {
+			return err
+		} else if ok {
+			if err := verifyCredentials(ctx, p.w, creds); err != nil {
+				return err
+			}
+		}
+	}
+
+	set := goauth.CredentialsSet{Credentials: map[string]goauth.Credentials{key: creds}}
+	if err := set.WriteFileMerge(path, "", "  ", 0600); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(p.w, "Wrote account `%s` to %s\n", key, path)
+	return err
+}
+
+func askCredentials(p *prompter) (goauth.Credentials, error) {
+	svcs := endpoints.Services()
+	fmt.Fprintln(p.w, "Services:")
+	for i, svc := range svcs {
+		fmt.Fprintf(p.w, "  %2d) %s\n", i+1, svc)
+	}
+	svc, err := p.Ask("Service (name or number, blank for custom)", "")
+	if err != nil {
+		return goauth.Credentials{}, err
+	}
+	if i, err := strconv.Atoi(svc); err == nil && i > 0 && i <= len(svcs) {
+		svc = svcs[i-1]
+	} else if svc = strings.ToLower(svc); svc != "" && !slices.Contains(svcs, svc) {
+		return goauth.Credentials{}, fmt.Errorf("service not found (%s)", svc)
+	}
+	creds := goauth.Credentials{Service: svc}
+	if svc != "" && endpoints.RequiresSubdomain(svc) {
+		if creds.Subdomain, err = p.AskRequired("Subdomain", ""); err != nil {
+			return creds, err
+		}
+	}
+
+	defaultType := goauth.TypeOAuth2
+	if svc == "" {
+		defaultType = ""
+	}
+	if creds.Type, err = p.Choose("Credential type", []string{goauth.TypeOAuth2, goauth.TypeBasic, goauth.TypeHeaderQuery}, defaultType); err != nil {
+		return creds, err
+	}
+	switch creds.Type {
+	case goauth.TypeOAuth2:
+		creds.OAuth2, err = askOAuth2(p, svc, creds.Subdomain)
+	case goauth.TypeBasic:
+		creds.Basic, err = askBasic(p)
+	case goauth.TypeHeaderQuery:
+		creds.HeaderQuery, err = askHeaderQuery(p)
+	}
+	return creds, err
+}
+
+func askOAuth2(p *prompter, svc, subdomain string) (*goauth.CredentialsOAuth2, error) {
+	oc := &goauth.CredentialsOAuth2{}
+	var err error
+	if svc == "" {
+		if oc.ServerURL, err = p.Ask("API server URL", ""); err != nil {
+			return nil, err
+		} else if oc.Endpoint.AuthURL, err = p.Ask("Authorization URL", ""); err != nil {
+			return nil, err
+		} else if oc.Endpoint.TokenURL, err = p.AskRequired("Token URL", ""); err != nil {
+			return nil, err
+		}
+	} else if ep, svrURL, err := endpoints.NewEndpoint(svc, subdomain); err != nil {
+		return nil, err
+	} else {
+		oc.Endpoint = oauth2.Endpoint{AuthURL: ep.AuthURL, TokenURL: ep.TokenURL}
+		oc.ServerURL = svrURL
+	}
+	if oc.GrantType, err = p.Choose("Grant type", []string{
+		authutil.GrantTypeAuthorizationCode,
+		authutil.GrantTypeClientCredentials,
+		authutil.GrantTypePassword}, authutil.GrantTypeAuthorizationCode); err != nil {
+		return nil, err
+	} else if oc.ClientID, err = p.AskRequired("Client ID", ""); err != nil {
+		return nil, err
+	} else if oc.ClientSecret, err = p.AskSecret("Client secret"); err != nil {
+		return nil, err
+	}
+	switch oc.GrantType {
+	case authutil.GrantTypeAuthorizationCode:
+		if oc.RedirectURL, err = p.Ask("Redirect URL", "https://grokify.github.io/goauth/oauth2callback/"); err != nil {
+			return nil, err
+		}
+	case authutil.GrantTypePassword:
+		if oc.Username, err = p.AskRequired("Username", ""); err != nil {
+			return nil, err
+		} else if oc.Password, err = p.AskSecret("Password"); err != nil {
+			return nil, err
+		}
+	}
+	if scopes, err := p.Ask("Scopes (comma separated)", ""); err != nil {
+		return nil, err
+	} else {
+		oc.Scopes = splitTrim(scopes, ",")
+	}
+	// Endpoints for known services are inflated when the file is read.
+	if svc != "" {
+		oc.Endpoint = oauth2.Endpoint{}
+		oc.ServerURL = ""
+	}
+	return oc, nil
+}
+
+func askBasic(p *prompter) (*goauth.CredentialsBasicAuth, error) {
+	c := &goauth.CredentialsBasicAuth{}
+	var err error
+	if c.ServerURL, err = p.Ask("API server URL", ""); err != nil {
+		return nil, err
+	} else if c.Username, err = p.AskRequired("Username", ""); err != nil {
+		return nil, err
+	} else if c.Password, err = p.AskSecret("Password"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func askHeaderQuery(p *prompter) (*goauth.CredentialsHeaderQuery, error) {
+	c := &goauth.CredentialsHeaderQuery{}
+	var err error
+	if c.ServerURL, err = p.Ask("API server URL", ""); err != nil {
+		return nil, err
+	}
+	in, err := p.Choose("Send credential in", []string{"header", "query"}, "header")
+	if err != nil {
+		return nil, err
+	}
+	name, err := p.AskRequired("Parameter name", "")
+	if err != nil {
+		return nil, err
+	}
+	val, err := p.AskSecret("Parameter value")
+	if err != nil {
+		return nil, err
+	}
+	if in == "header" {
+		c.Header = http.Header{}
+		c.Header.Set(name, val)
+	} else {
+		c.Query = map[string][]string{name: {val}}
+	}
+	return c, nil
+}
+
+func verifyCredentials(ctx context.Context, w io.Writer, creds goauth.Credentials) error {
+	// Copy so endpoint inflation is not written to the file.
+	oc := *creds.OAuth2
+	creds.OAuth2 = &oc
+	if err := creds.Inflate(); err != nil {
+		return err
+	}
+	tok, err := creds.NewTokenCLI(ctx, "")
+	if err != nil {
+		return fmt.Errorf("token flow failed: %w", err)
+	}
+	if tok.Expiry.IsZero() {
+		_, err = fmt.Fprintln(w, "Token retrieved")
+	} else {
+		_, err = fmt.Fprintf(w, "Token retrieved, expires %s\n", tok.Expiry.Format(time.RFC3339))
+	}
+	return err
+}
+
+// prompter reads answers from a terminal or other reader.
+type prompter struct {
+	r  *bufio.Reader
+	w  io.Writer
+	fd int
+}
+
+func newPrompter(in *os.File, w io.Writer) *prompter {
+	return &prompter{r: bufio.NewReader(in), w: w, fd: int(in.Fd())}
+}
+
+// Ask prompts for a value, returning `def` if the answer is blank.
+func (p *prompter) Ask(label, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.w, "%s [%s]: ", label, def)
+	} else {
+		fmt.Fprintf(p.w, "%s: ", label)
+	}
+	line, err := p.r.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", err
+	}
+	if line = strings.TrimSpace(line); line == "" {
+		return def, nil
+	}
+	return line, nil
+}
+
+// AskRequired prompts until a non-empty value is provided.
+func (p *prompter) AskRequired(label, def string) (string, error) {
+	for {
+		if v, err := p.Ask(label, def); err != nil || v != "" {
+			return v, err
+		}
+		fmt.Fprintf(p.w, "%s is required\n", label)
+	}
+}
+
+// AskSecret prompts for a value without echoing it when reading from a terminal.
+func (p *prompter) AskSecret(label string) (string, error) {
+	if !term.IsTerminal(p.fd) {
+		return p.Ask(label, "")
+	}
+	fmt.Fprintf(p.w, "%s: ", label)
+	b, err := term.ReadPassword(p.fd)
+	fmt.Fprintln(p.w)
+	return strings.TrimSpace(string(b)), err
+}
+
+// Choose prompts for one of `opts` by value or 1-based number.
+func (p *prompter) Choose(label string, opts []string, def string) (string, error) {
+	for {
+		v, err := p.Ask(fmt.Sprintf("%s (%s)", label, strings.Join(opts, ", ")), def)
+		if err != nil {
+			return "", err
+		}
+		if i, err := strconv.Atoi(v); err == nil && i > 0 && i <= len(opts) {
+			return opts[i-1], nil
+		} else if slices.Contains(opts, strings.ToLower(v)) {
+			return strings.ToLower(v), nil
+		}
+		fmt.Fprintf(p.w, "Please choose one of: %s\n", strings.Join(opts, ", "))
+	}
+}
+
+// Confirm prompts for a yes or no answer.
+func (p *prompter) Confirm(label string, def bool) (bool, error) {
+	defStr := "y/N"
+	if def {
+		defStr = "Y/n"
+	}
+	v, err := p.Ask(fmt.Sprintf("%s [%s]", label, defStr), "")
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(v) {
+	case "":
+		return def, nil
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func splitTrim(s, sep string) []string {
+	var out []string
+	for _, v := range strings.Split(s, sep) {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/grokify/goauth"
+)
+
+func newTestPrompter(input string) *prompter {
+	return &prompter{r: bufio.NewReader(strings.NewReader(input)), w: io.Discard, fd: -1}
+}
+
+func TestAskCredentials(t *testing.T) {
+	// Custom service, an invalid type choice, then a header credential.
+	p := newTestPrompter("\nsaml\n3\nhttps://api.example.com\nheader\nX-Api-Key\nabc\n")
+	creds, err := askCredentials(p)
+	if err != nil {
+		t.Fatalf("askCredentials(): err [%v]", err)
+	}
+	if creds.Type != goauth.TypeHeaderQuery || creds.HeaderQuery == nil {
+		t.Fatalf("askCredentials(): want type [%s], got [%s]", goauth.TypeHeaderQuery, creds.Type)
+	}
+	if v := creds.HeaderQuery.Header.Get("X-Api-Key"); v != "abc" || creds.HeaderQuery.ServerURL != "https://api.example.com" {
+		t.Errorf("askCredentials(): want header [abc] server [https://api.example.com], got [%s] [%s]", v, creds.HeaderQuery.ServerURL)
+	}
+	if _, err := askCredentials(newTestPrompter("nosuchservice\n")); err == nil {
+		t.Errorf("askCredentials(nosuchservice): want err, got nil")
+	}
+}
+
+var confirmTests = []struct {
+	input string
+	def   bool
+	want  bool
+}{
+	{"\n", true, true},
+	{"\n", false, false},
+	{"y\n", false, true},
+	{"YES\n", false, true},
+	{"n\n", true, false},
+}
+
+func TestPrompterConfirm(t *testing.T) {
+	for _, tt := range confirmTests {
+		if got, err := newTestPrompter(tt.input).Confirm("Save", tt.def); err != nil || got != tt.want {
+			t.Errorf("prompter.Confirm(%q, %v): want [%v], got [%v] err [%v]", tt.input, tt.def, tt.want, got, err)
+		}
+	}
+}
+
+func TestPrompterAskRequired(t *testing.T) {
+	if got, err := newTestPrompter("\n \nvalue\n").AskRequired("Name", ""); err != nil || got != "value" {
+		t.Errorf("prompter.AskRequired(): want [value], got [%s] err [%v]", got, err)
+	}
+	if _, err := newTestPrompter("").AskRequired("Name", ""); err == nil {
+		t.Errorf("prompter.AskRequired(EOF): want err, got nil")
+	}
+}
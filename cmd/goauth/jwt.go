@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/grokify/goauth"
+)
+
+// jwtArg returns the token from the first argument, or from stdin if none or `-`.
+func jwtArg(args []string) (string, error) {
+	if len(args) > 0 && args[0] != "-" {
+		return strings.TrimSpace(args[0]), nil
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	} else if line = strings.TrimSpace(line); line == "" {
+		return "", usageErrorf("token argument is required")
+	}
+	return line, nil
+}
+
+type jwtOutput struct {
+	Header map[string]any `json:"header"`
+	Claims jwt.MapClaims  `json:"claims"`
+	Valid  *bool          `json:"valid,omitempty"`
+}
+
+func (g *globalOptions) printJWT(tok *jwt.Token, valid *bool) error {
+	claims, _ := tok.Claims.(jwt.MapClaims)
+	return g.print(jwtOutput{Header: tok.Header, Claims: claims, Valid: valid}, nil)
+}
+
+type jwtDecodeCommand struct {
+	g *globalOptions
+}
+
+func (cmd *jwtDecodeCommand) Execute(args []string) error {
+	s, err := jwtArg(args)
+	if err != nil {
+		return err
+	}
+	tok, _, err := jwt.NewParser().ParseUnverified(s, jwt.MapClaims{})
+	if err != nil {
+		return err
+	}
+	return cmd.g.printJWT(tok, nil)
+}
+
+// jwtKeyOptions selects the key used to sign or verify a JWT.
+type jwtKeyOptions struct {
+	Key     string `long:"key" description:"HMAC secret"`
+	KeyFile string `long:"key-file" description:"PEM key file for RS, PS, ES and EdDSA algorithms, or HMAC secret file"`
+}
+
+// key returns the HMAC secret or PEM file bytes, falling back to a `jwt` type account's private key.
+func (o jwtKeyOptions) key(g *globalOptions) ([]byte, *goauth.CredentialsJWT, error) {
+	var jc *goauth.CredentialsJWT
+	if g.CredsPath != "" && g.Account != "" {
+		if creds, err := g.Credentials(); err != nil {
+			return nil, nil, err
+		} else if creds.JWT == nil {
+			return nil, nil, configError(fmt.Errorf("account `%s` is not type `%s`", g.Account, goauth.TypeJWT))
+		} else {
+			jc = creds.JWT
+		}
+	}
+	switch {
+	case o.Key != "":
+		return []byte(o.Key), jc, nil
+	case o.KeyFile != "":
+		b, err := os.ReadFile(o.KeyFile)
+		return b, jc, err
+	case jc != nil && jc.PrivateKey != "":
+		return []byte(jc.PrivateKey), jc, nil
+	default:
+		return nil, jc, usageErrorf("one of --key, --key-file or a `jwt` type --account is required")
+	}
+}
+
+// parseKey converts key bytes to the key type for the signing method.
+func parseKey(method jwt.SigningMethod, b []byte, private bool) (any, error) {
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if isPEM(b) {
+			return nil, fmt.Errorf("PEM key cannot be used as an HMAC secret (%s)", method.Alg())
+		}
+		return b, nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if private {
+			return jwt.ParseRSAPrivateKeyFromPEM(b)
+		}
+		return jwt.ParseRSAPublicKeyFromPEM(b)
+	case *jwt.SigningMethodECDSA:
+		if private {
+			return jwt.ParseECPrivateKeyFromPEM(b)
+		}
+		return jwt.ParseECPublicKeyFromPEM(b)
+	case *jwt.SigningMethodEd25519:
+		if private {
+			return jwt.ParseEdPrivateKeyFromPEM(b)
+		}
+		return jwt.ParseEdPublicKeyFromPEM(b)
+	default:
+		return nil, fmt.Errorf("signing method not supported (%s)", method.Alg())
+	}
+}
+
+// isPEM returns true if `b` is a PEM encoded key, which must not be used as an HMAC secret.
+func isPEM(b []byte) bool {
+	return bytes.Contains(b, []byte("-----BEGIN "))
+}
+
+// verifyMethods returns the algorithms accepted for a verification key, so the token's `alg`
+// header cannot choose a method for another key type, e.g. HS256 with a public key as the
+// secret. If `alg` is empty, they are derived from the key type.
+func verifyMethods(alg string, key []byte) ([]string, error) {
+	var methods []string
+	if !isPEM(key) {
+		methods = []string{"HS256", "HS384", "HS512"}
+	} else if _, err := jwt.ParseRSAPublicKeyFromPEM(key); err == nil {
+		methods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+	} else if _, err := jwt.ParseECPublicKeyFromPEM(key); err == nil {
+		methods = []string{"ES256", "ES384", "ES512"}
+	} else if _, err := jwt.ParseEdPublicKeyFromPEM(key); err == nil {
+		methods = []string{"EdDSA"}
+	} else {
+		return nil, usageErrorf("verification key is not a PEM public key or certificate")
+	}
+	if alg == "" {
+		return methods, nil
+	} else if !slices.Contains(methods, alg) {
+		return nil, usageErrorf("--alg %s cannot be used with this key", alg)
+	}
+	return []string{alg}, nil
+}
+
+type jwtVerifyCommand struct {
+	jwtKeyOptions
+	Alg      string `long:"alg" description:"Required algorithm, e.g. HS256 or RS256, defaults to the account's or those for the key type"`
+	Issuer   string `long:"iss" description:"Required issuer"`
+	Audience string `long:"aud" description:"Required audience"`
+	g        *globalOptions
+}
+
+func (cmd *jwtVerifyCommand) Execute(args []string) error {
+	s, err := jwtArg(args)
+	if err != nil {
+		return err
+	}
+	keyBytes, jc, err := cmd.key(cmd.g)
+	if err != nil {
+		return err
+	}
+	alg := strings.ToUpper(strings.TrimSpace(cmd.Alg))
+	if alg == "" && jc != nil {
+		alg = strings.ToUpper(strings.TrimSpace(jc.SigningMethod))
+	}
+	methods, err := verifyMethods(alg, keyBytes)
+	if err != nil {
+		return err
+	}
+	opts := []jwt.ParserOption{jwt.WithValidMethods(methods)}
+	if cmd.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cmd.Issuer))
+	}
+	if cmd.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cmd.Audience))
+	}
+	tok, err := jwt.NewParser(opts...).Parse(s, func(t *jwt.Token) (any, error) {
+		return parseKey(t.Method, keyBytes, false)
+	})
+	if err != nil {
+		return authErrorf("jwt verification failed: %w", err)
+	}
+	valid := tok.Valid
+	return cmd.g.printJWT(tok, &valid)
+}
+
+type jwtSignCommand struct {
+	jwtKeyOptions
+	Alg      string        `long:"alg" description:"Signing algorithm, defaults to the account's or HS256"`
+	Claims   string        `long:"claims" description:"Claims as a JSON object"`
+	Claim    []string      `long:"claim" description:"Claim as key=value, repeatable"`
+	Issuer   string        `long:"iss" description:"Issuer claim"`
+	Subject  string        `long:"sub" description:"Subject claim"`
+	Audience string        `long:"aud" description:"Audience claim"`
+	Expires  time.Duration `long:"exp" description:"Expiration from now, e.g. 1h"`
+	g        *globalOptions
+}
+
+func (cmd *jwtSignCommand) Execute(args []string) error {
+	keyBytes, jc, err := cmd.key(cmd.g)
+	if err != nil {
+		return err
+	}
+	claims := jwt.MapClaims{}
+	if cmd.Claims != "" {
+		if err := json.Unmarshal([]byte(cmd.Claims), &claims); err != nil {
+			return usageErrorf("--claims: %w", err)
+		}
+	}
+	for _, kv := range cmd.Claim {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return usageErrorf("--claim must be key=value (%s)", kv)
+		}
+		claims[strings.TrimSpace(k)] = v
+	}
+	alg := strings.ToUpper(strings.TrimSpace(cmd.Alg))
+	if jc != nil {
+		alg = firstNonEmpty(alg, strings.ToUpper(strings.TrimSpace(jc.SigningMethod)))
+		if jc.Issuer != "" && cmd.Issuer == "" {
+			claims["iss"] = jc.Issuer
+		}
+	}
+	for k, v := range map[string]string{"iss": cmd.Issuer, "sub": cmd.Subject, "aud": cmd.Audience} {
+		if v != "" {
+			claims[k] = v
+		}
+	}
+	now := time.Now()
+	claims["iat"] = now.Unix()
+	if cmd.Expires > 0 {
+		claims["exp"] = now.Add(cmd.Expires).Unix()
+	}
+	method := jwt.GetSigningMethod(firstNonEmpty(alg, goauth.SigningMethodHS256))
+	if method == nil {
+		return usageErrorf("signing method not supported (%s)", alg)
+	}
+	key, err := parseKey(method, keyBytes, true)
+	if err != nil {
+		return err
+	}
+	s, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		return err
+	}
+	return cmd.g.print(map[string]string{"token": s}, func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, s)
+		return err
+	})
+}
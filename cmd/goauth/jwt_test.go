@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWTVerifyAlgorithmConfusion(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey(): err [%v]", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("x509.MarshalPKIXPublicKey(): err [%v]", err)
+	}
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	keyFile := filepath.Join(t.TempDir(), "pub.pem")
+	if err := os.WriteFile(keyFile, pubPEM, 0600); err != nil {
+		t.Fatalf("os.WriteFile(): err [%v]", err)
+	}
+	// A token signed with HS256 using the public key as the secret.
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "admin"}).SignedString(pubPEM)
+	if err != nil {
+		t.Fatalf("jwt.SignedString(HS256): err [%v]", err)
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user"}).SignedString(priv)
+	if err != nil {
+		t.Fatalf("jwt.SignedString(RS256): err [%v]", err)
+	}
+
+	tests := []struct {
+		token   string
+		alg     string
+		wantErr bool
+	}{
+		{forged, "", true},
+		{forged, "HS256", true},
+		{signed, "", false},
+		{signed, "RS256", false},
+		{signed, "PS256", true},
+	}
+	for _, tt := range tests {
+		cmd := &jwtVerifyCommand{jwtKeyOptions: jwtKeyOptions{KeyFile: keyFile}, Alg: tt.alg, g: &globalOptions{}}
+		if err := cmd.Execute([]string{tt.token}); (err != nil) != tt.wantErr {
+			t.Errorf("jwtVerifyCommand.Execute(alg %q): want error [%v], got [%v]", tt.alg, tt.wantErr, err)
+		}
+	}
+}
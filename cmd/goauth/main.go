@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"log/slog"
 	"os"
@@ -10,20 +9,82 @@ import (
 	flags "github.com/jessevdk/go-flags"
 )
 
-func main() {
-	cli := goauth.CLIRequest{}
-	_, err := flags.Parse(&cli)
-	if err != nil {
-		slog.Error(err.Error())
-		os.Exit(1)
+// globalOptions are the flags shared by all subcommands.
+type globalOptions struct {
+	goauth.Options
+	Output string `short:"o" long:"output" description:"Output format, raw, headers and ndjson apply to request" choice:"text" choice:"json" choice:"raw" choice:"headers" choice:"ndjson" default:"text"`
+}
+
+type command struct {
+	name, short, long string
+	data              any
+	subcommands       []command
+}
+
+func commands(g *globalOptions) []command {
+	return []command{
+		{"token", "Get and manage tokens", "Get, refresh, revoke, and introspect tokens for --account, and manage the token cache.", &struct{}{}, []command{
+			{"get", "Get an access token", "Print an access token from the first configured credentials source. Use --verbose to show which source was used.", &tokenGetCommand{g: g}, nil},
+			{"refresh", "Get a new token for --account", "Get a new token for --account using the cached refresh token if available, ignoring any unexpired cached token.", &tokenRefreshCommand{g: g}, nil},
+			{"revoke", "Revoke a token", "Revoke a token using the account's RFC 7009 revocation endpoint and remove it from the token cache.", &tokenRevokeCommand{g: g}, nil},
+			{"introspect", "Introspect a token", "Call the account's RFC 7662 introspection endpoint. Exits with the auth error code if the token is not active.", &tokenIntrospectCommand{g: g}, nil},
+			{"clear", "Remove cached tokens", "Remove cached tokens for --account, or all cached tokens. Use --no-cache to bypass the cache for a request.", &tokenClearCommand{g: g}, nil}}},
+		{"creds", "Manage credentials set files", "List, show, validate, import, and export accounts in the --creds credentials set file.", &struct{}{}, []command{
+			{"list", "List accounts", "List the accounts in the credentials set file.", &credsListCommand{g: g}, nil},
+			{"show", "Show an account", "Show the --account credentials. Secrets are redacted unless --reveal is set.", &credsShowCommand{g: g}, nil},
+			{"validate", "Validate accounts", "Check that --account, or every account, has the settings required by its type and grant type.", &credsValidateCommand{g: g}, nil},
+			{"import", "Import an account", "Add --account to the credentials set file from another credentials set file or from environment variables, without changing other entries.", &credsImportCommand{g: g}, nil},
+			{"export", "Export an account", "Export an account as a .env file, Kubernetes Secret manifest, or curl/HTTPie command. Secrets are redacted unless --reveal is set.", &credsExportCommand{g: g}, nil}}},
+		{"jwt", "Decode, verify, and sign JWTs", "Decode, verify, and sign JSON Web Tokens.", &struct{}{}, []command{
+			{"decode", "Decode a JWT without verifying it", "Print the header and claims of a JWT given as an argument or on stdin, without verifying the signature.", &jwtDecodeCommand{g: g}, nil},
+			{"verify", "Verify a JWT", "Verify the signature and registered claims of a JWT. Exits with the auth error code if verification fails.", &jwtVerifyCommand{g: g}, nil},
+			{"sign", "Sign a JWT", "Create a signed JWT from claims, using a key or a `jwt` type --account.", &jwtSignCommand{g: g}, nil}}},
+		{"agent", "Run a token agent", "Run a daemon which holds the --creds accounts in memory and serves access tokens to local processes over a Unix socket, refreshing them before they expire, like ssh-agent. Clients find the socket with --socket, GOAUTH_AGENT_SOCK, or the default path.", &struct{}{}, []command{
+			{"start", "Start the agent", "Start the agent in the foreground, listening on a socket only accessible by the current user. Tokens are read from and written to the token cache unless --no-cache is set.", &agentStartCommand{g: g}, nil},
+			{"token", "Get an access token from the agent", "Print an access token for --account from the agent. Use --refresh to get a new token, e.g. after the current token was rejected.", &agentTokenCommand{g: g}, nil},
+			{"list", "List the agent's accounts", "List the accounts served by the agent.", &agentListCommand{g: g}, nil},
+			{"stop", "Stop the agent", "Stop the agent.", &agentStopCommand{}, nil}}},
+		{"credential-helper", "Act as a credential helper for other tools", "Provide credentials from accounts and the token cache to git, Docker, and tools that run a command for an ExecCredential token. A link to goauth named git-credential-goauth or docker-credential-goauth runs the git or docker helper.", &struct{}{}, []command{
+			{"git", "git credential helper", "Implement the git credential helper protocol: get, store, and erase, keyed by host. Use as: git config credential.helper '!goauth credential-helper git --creds credentials.json'.", &credentialHelperGitCommand{g: g}, nil},
+			{"docker", "Docker credential helper", "Implement the Docker credential helper protocol: get, store, erase, list, and version, keyed by registry. Set GOAUTH_CREDS when run as docker-credential-goauth.", &credentialHelperDockerCommand{g: g}, nil},
+			{"exec", "Print an ExecCredential", "Print a Kubernetes client.authentication.k8s.io ExecCredential with an access token for --account, for kubeconfig exec plugins and similar tools.", &credentialHelperExecCommand{g: g}, nil}}},
+		{"exec", "Run a command with an access token", "Run a command with the access token in GOAUTH_ACCESS_TOKEN and the Authorization header value in GOAUTH_AUTH_HEADER, e.g. goauth exec --account my-app -- command args. If the command outlives the token, the token is refreshed and the command is restarted, or signalled to read GOAUTH_ACCESS_TOKEN_FILE with --on-expiry signal. Signals are forwarded and the command's exit code is returned.", &execCommand{g: g}, nil},
+		{"proxy", "Run an authenticating reverse proxy", "Run a local reverse proxy which forwards requests to upstream APIs with credentials from the account for each --route, or --account for all requests. Inbound Authorization headers are removed. Listens on loopback only unless --allow-remote is set.", &proxyCommand{g: g}, nil},
+		{"request", "Make an API request", "Get credentials and make an API request, printing the response status, headers, and body. Use --output json, raw, headers, or ndjson for scripts, --follow-pages to request each next page, and --jq to extract values from JSON bodies.", &requestCommand{g: g}, nil},
+		{"serve", "Run test servers", "Run local servers for testing.", &struct{}{}, []command{
+			{"mock-introspect", "Run a mock introspection server", "Run a mock RFC 7662 token introspection endpoint at POST /introspect.", &serveMockIntrospectCommand{g: g}, nil}}},
+		{"init", "Add an account interactively", "Interactively create an account for a known service or custom endpoint, optionally verify it with the token flow, and add it to a credentials set file.", &initCommand{opts: &g.Options}, nil},
 	}
+}
 
-	err = cli.Do(context.Background(), "", os.Stdout)
-	if err != nil {
-		slog.Error(err.Error())
-		os.Exit(2)
+type commandAdder interface {
+	AddCommand(command, shortDescription, longDescription string, data any) (*flags.Command, error)
+}
+
+func addCommands(parent commandAdder, cmds []command) error {
+	for _, c := range cmds {
+		if fc, err := parent.AddCommand(c.name, c.short, c.long, c.data); err != nil {
+			return err
+		} else if err := addCommands(fc, c.subcommands); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	fmt.Println("\nDONE")
-	os.Exit(0)
+func main() {
+	g := &globalOptions{}
+	parser := flags.NewParser(g, flags.HelpFlag|flags.PassDoubleDash)
+	if err := addCommands(parser, commands(g)); err != nil {
+		slog.Error(err.Error())
+		os.Exit(exitError)
+	}
+	if _, err := parser.ParseArgs(helperArgs(os.Args)); err != nil {
+		if flags.WroteHelp(err) {
+			fmt.Println(err.Error())
+			os.Exit(exitOK)
+		}
+		os.Exit(g.fail(err))
+	}
+	os.Exit(exitOK)
 }
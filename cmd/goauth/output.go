@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/grokify/goauth"
+	"github.com/grokify/goauth/authutil"
+	flags "github.com/jessevdk/go-flags"
+	"golang.org/x/oauth2"
+)
+
+const outputJSON = goauth.OutputJSON
+
+// Exit codes by error class.
+const (
+	exitOK      = 0
+	exitError   = 1 // unclassified
+	exitUsage   = 2 // invalid flags or arguments
+	exitConfig  = 3 // credentials file, account, or settings
+	exitAuth    = 4 // authorization server rejected credentials, or token invalid or inactive
+	exitNetwork = 5 // connection, DNS, or TLS failure
+)
+
+var (
+	errUsage  = errors.New("usage")
+	errConfig = errors.New("config")
+	errAuth   = errors.New("auth")
+)
+
+// classError marks an error with an error class.
+type classError struct {
+	class error
+	err   error
+}
+
+func (e *classError) Error() string   { return e.err.Error() }
+func (e *classError) Unwrap() []error { return []error{e.class, e.err} }
+
+func usageErrorf(format string, a ...any) error {
+	return &classError{class: errUsage, err: fmt.Errorf(format, a...)}
+}
+
+func configError(err error) error {
+	return &classError{class: errConfig, err: err}
+}
+
+// exitCodeError exits with `code`, reporting `err` if set. It is used to forward the exit
+// code of `exec` child processes.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func (e *exitCodeError) Unwrap() error { return e.err }
+
+func authErrorf(format string, a ...any) error {
+	return &classError{class: errAuth, err: fmt.Errorf(format, a...)}
+}
+
+// errorClass returns the error class name and exit code for an error.
+func errorClass(err error) (string, int) {
+	var flagsErr *flags.Error
+	var retrieveErr *oauth2.RetrieveError
+	var respErr *authutil.ResponseError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var netErr net.Error
+	var urlErr *url.Error
+	switch {
+	case errors.Is(err, errUsage), errors.As(err, &flagsErr),
+		errors.Is(err, goauth.ErrOutputNotSupported), errors.Is(err, goauth.ErrQueryInvalid):
+		return "usage", exitUsage
+	case errors.Is(err, errAuth), errors.As(err, &retrieveErr), errors.As(err, &respErr),
+		errors.Is(err, jwt.ErrTokenMalformed), errors.Is(err, jwt.ErrTokenSignatureInvalid),
+		errors.Is(err, jwt.ErrTokenExpired), errors.Is(err, jwt.ErrTokenNotValidYet),
+		errors.Is(err, jwt.ErrTokenUnverifiable), errors.Is(err, jwt.ErrTokenInvalidClaims):
+		return "auth", exitAuth
+	case errors.Is(err, errConfig), errors.Is(err, goauth.ErrAccountNotFound),
+		errors.Is(err, goauth.ErrNoCredentials), errors.Is(err, goauth.ErrTypeNotSupported),
+		errors.Is(err, os.ErrNotExist), errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return "config", exitConfig
+	case errors.As(err, &netErr), errors.As(err, &urlErr):
+		return "network", exitNetwork
+	default:
+		return "error", exitError
+	}
+}
+
+// fail reports an error on stderr, as JSON with `--output json`, and returns the exit code.
+func (g *globalOptions) fail(err error) int {
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) {
+		if exitErr.err == nil {
+			return exitErr.code
+		}
+		fmt.Fprintf(os.Stderr, "goauth: %s\n", exitErr.err.Error())
+		return exitErr.code
+	}
+	class, code := errorClass(err)
+	if g.Output == outputJSON {
+		b, _ := json.Marshal(map[string]any{
+			"error":    err.Error(),
+			"class":    class,
+			"exitCode": code})
+		fmt.Fprintln(os.Stderr, string(b))
+	} else {
+		fmt.Fprintf(os.Stderr, "goauth: %s error: %s\n", class, err.Error())
+	}
+	return code
+}
+
+// print writes `v` as indented JSON with `--output json`, otherwise calls `text`.
+func (g *globalOptions) print(v any, text func(w io.Writer) error) error {
+	if g.Output == outputJSON || text == nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	return text(os.Stdout)
+}
+
+// requireAccount returns a usage error if `--creds` or `--account` is not set.
+func (g *globalOptions) requireAccount() error {
+	if g.CredsPath == "" || g.Account == "" {
+		return usageErrorf("--creds and --account are required")
+	}
+	return nil
+}
+
+// fileProvider returns the provider for the `--creds` / `--account` account with the token cache.
+func (g *globalOptions) fileProvider() goauth.FileProvider {
+	return goauth.FileProvider{
+		Filename: g.CredsPath,
+		Account:  g.Account,
+		Cache:    g.TokenCache()}
+}
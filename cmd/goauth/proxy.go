@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/grokify/goauth"
+	"github.com/grokify/goauth/authproxy"
+)
+
+type proxyCommand struct {
+	Addr        string   `long:"addr" description:"Listen address" default:"127.0.0.1:9000"`
+	Route       []string `long:"route" description:"Route as [HOST][/PREFIX]=ACCOUNT[@UPSTREAM], repeatable. Defaults to all requests for --account"`
+	Upstream    string   `long:"upstream" description:"Upstream URL for --account when --route is not set, defaults to the account's server URL"`
+	KeepPrefix  bool     `long:"keep-prefix" description:"Forward route path prefixes instead of removing them"`
+	AllowRemote bool     `long:"allow-remote" description:"Allow listening on addresses other than loopback"`
+	AllowHost   []string `long:"allow-host" description:"Accept requests for a host besides localhost, loopback IPs and route hosts, repeatable"`
+	Log         bool     `long:"log" description:"Log requests and responses to stderr with credentials redacted"`
+	g           *globalOptions
+}
+
+func (cmd *proxyCommand) Execute(args []string) error {
+	if cmd.g.CredsPath == "" {
+		return usageErrorf("--creds is required")
+	} else if !cmd.AllowRemote && !authproxy.IsLoopback(cmd.Addr) {
+		return usageErrorf("--addr is not a loopback address, set --allow-remote to listen on it (%s)", cmd.Addr)
+	}
+	routes, err := cmd.routes()
+	if err != nil {
+		return err
+	}
+	set, err := cmd.g.CredentialsSet(true)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	routes, err = authproxy.NewRoutesClients(set, routes, func(account string) (*http.Client, error) {
+		file := goauth.FileProvider{Filename: cmd.g.CredsPath, Account: account, Cache: cmd.g.TokenCache()}
+		clt, _, err := goauth.ProviderChain{goauth.CachedTokenProvider{FileProvider: file}, file}.Resolve(ctx)
+		return clt, err
+	})
+	if err != nil {
+		return configError(err)
+	}
+	var logger *slog.Logger
+	if cmd.Log {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	p, err := authproxy.New(routes, logger)
+	if err != nil {
+		return configError(err)
+	}
+	p.AllowedHosts = cmd.AllowHost
+	for _, rt := range routes {
+		fmt.Fprintf(os.Stderr, "Route %s\n", rt.String())
+	}
+	fmt.Fprintf(os.Stderr, "Serving authenticating proxy at http://%s\n", cmd.Addr)
+	svr := &http.Server{
+		Addr:              cmd.Addr,
+		Handler:           p,
+		ReadHeaderTimeout: 10 * time.Second}
+	return svr.ListenAndServe()
+}
+
+// routes returns the `--route` routes, or a route for all requests to `--account`.
+func (cmd *proxyCommand) routes() ([]authproxy.Route, error) {
+	routes := []authproxy.Route{}
+	for _, s := range cmd.Route {
+		rt, err := authproxy.ParseRoute(s)
+		if err != nil {
+			return nil, usageErrorf("--route: %w", err)
+		}
+		rt.StripPrefix = !cmd.KeepPrefix
+		routes = append(routes, rt)
+	}
+	if len(routes) > 0 {
+		return routes, nil
+	} else if cmd.g.Account == "" {
+		return nil, usageErrorf("one of --route or --account is required")
+	}
+	rt := authproxy.Route{Account: cmd.g.Account}
+	if cmd.Upstream != "" {
+		u, err := authproxy.ParseUpstream(cmd.Upstream)
+		if err != nil {
+			return nil, usageErrorf("--upstream: %w", err)
+		}
+		rt.Upstream = u
+	}
+	return []authproxy.Route{rt}, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/grokify/goauth"
+	"github.com/grokify/mogo/net/http/httpsimple"
+)
+
+type requestCommand struct {
+	httpsimple.CLI
+	goauth.ResponseOptions
+	goauth.AccountsOptions
+	g *globalOptions
+}
+
+func (cmd *requestCommand) Execute(args []string) error {
+	if cmd.URL == "" {
+		return usageErrorf("--url is required")
+	}
+	cli := goauth.CLIRequest{
+		Options:  cmd.g.Options,
+		Request:  cmd.CLI,
+		Output:   cmd.g.Output,
+		Response: cmd.ResponseOptions}
+	if cmd.AccountsOptions.IsSet() {
+		return cmd.executeAccounts(cli)
+	}
+	return cli.Do(context.Background(), "", os.Stdout)
+}
+
+// executeAccounts runs the request for each selected account and writes the results. It
+// returns an error exit code without a message if any account failed.
+func (cmd *requestCommand) executeAccounts(cli goauth.CLIRequest) error {
+	switch {
+	case cmd.g.CredsPath == "":
+		return usageErrorf("--creds is required with --accounts, --all, or --match")
+	case cmd.g.Account != "":
+		return usageErrorf("--account cannot be used with --accounts, --all, or --match")
+	case cmd.g.Token != "" || cmd.g.TokenFile != "":
+		return usageErrorf("--token and --token-file cannot be used with --accounts, --all, or --match")
+	case cmd.g.Output != goauth.OutputText && cmd.g.Output != goauth.OutputJSON && cmd.g.Output != goauth.OutputNDJSON:
+		return usageErrorf("--output must be text, json, or ndjson with --accounts, --all, or --match")
+	}
+	results, err := cli.DoAccounts(context.Background(), cmd.AccountsOptions)
+	if err != nil {
+		return configError(err)
+	} else if err := results.Write(os.Stdout, cmd.g.Output, cmd.Query != ""); err != nil {
+		return err
+	} else if results.Failed() > 0 {
+		return &exitCodeError{code: exitError}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/grokify/goauth/authutil/introspect"
+)
+
+type serveMockIntrospectCommand struct {
+	Addr        string   `long:"addr" description:"Listen address, use :8000 to listen on all interfaces" default:"127.0.0.1:8000"`
+	ActiveToken []string `long:"active-token" description:"Token reported as active, repeatable"`
+	Username    string   `long:"username" description:"username in introspection responses"`
+	ClientID    string   `long:"client-id" description:"client_id in introspection responses"`
+	Scope       string   `long:"scope" description:"scope in introspection responses"`
+	g           *globalOptions
+}
+
+func (cmd *serveMockIntrospectCommand) Execute(args []string) error {
+	svr := introspect.NewMockServer(introspect.IntrospectResponse{
+		Username: cmd.Username,
+		ClientID: cmd.ClientID,
+		Scope:    cmd.Scope},
+		cmd.ActiveToken)
+	fmt.Fprintf(os.Stderr, "Serving mock introspection endpoint at POST http://%s/introspect\n", cmd.Addr)
+	return svr.ListenAndServe(cmd.Addr)
+}
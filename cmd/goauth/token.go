@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/grokify/goauth"
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/goauth/authutil/introspect"
+	"golang.org/x/oauth2"
+)
+
+type tokenOutput struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitzero"`
+	Source       string    `json:"source,omitempty"`
+}
+
+func (g *globalOptions) printToken(tok *oauth2.Token, source string) error {
+	return g.print(tokenOutput{
+		AccessToken:  tok.AccessToken,
+		TokenType:    tok.TokenType,
+		RefreshToken: tok.RefreshToken,
+		Expiry:       tok.Expiry.UTC(),
+		Source:       source,
+	}, func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, tok.AccessToken)
+		return err
+	})
+}
+
+type tokenGetCommand struct {
+	g *globalOptions
+}
+
+func (cmd *tokenGetCommand) Execute(args []string) error {
+	tok, results, err := cmd.g.NewToken(context.Background(), "")
+	if err != nil {
+		return err
+	}
+	return cmd.g.printToken(tok, results.Used())
+}
+
+type tokenRefreshCommand struct {
+	g *globalOptions
+}
+
+func (cmd *tokenRefreshCommand) Execute(args []string) error {
+	if err := cmd.g.requireAccount(); err != nil {
+		return err
+	}
+	tok, err := cmd.g.fileProvider().Refresh(context.Background(), "")
+	if err != nil {
+		return err
+	}
+	return cmd.g.printToken(tok, goauth.ProviderNameFile)
+}
+
+// tokenEndpointOptions selects the token and endpoint for revocation and introspection.
+type tokenEndpointOptions struct {
+	URL      string `long:"url" description:"Endpoint URL, overriding the account setting"`
+	Refresh  bool   `long:"refresh" description:"Use the cached refresh token instead of the access token"`
+	TypeHint string `long:"type-hint" description:"token_type_hint parameter, e.g. access_token or refresh_token"`
+}
+
+// resolve returns the account's OAuth 2.0 settings, if any, and the token to send: the
+// first argument, the `--token` flag, or the token resolved for the account.
+func (o tokenEndpointOptions) resolve(ctx context.Context, g *globalOptions, args []string) (*goauth.CredentialsOAuth2, string, error) {
+	var oc *goauth.CredentialsOAuth2
+	if g.CredsPath != "" && g.Account != "" {
+		if creds, err := g.Credentials(); err != nil {
+			return nil, "", err
+		} else {
+			oc = creds.OAuth2
+		}
+	}
+	switch {
+	case len(args) > 0:
+		return oc, strings.TrimSpace(args[0]), nil
+	case g.Token != "" && !o.Refresh:
+		return oc, strings.TrimSpace(g.Token), nil
+	case o.Refresh:
+		if err := g.requireAccount(); err != nil {
+			return nil, "", err
+		}
+		file := g.fileProvider()
+		tok, err := goauth.ProviderChain{goauth.CachedTokenProvider{FileProvider: file}, file}.Token(ctx)
+		if err != nil {
+			return nil, "", err
+		} else if tok.RefreshToken == "" {
+			return nil, "", configError(fmt.Errorf("no refresh token for account `%s`", g.Account))
+		}
+		return oc, tok.RefreshToken, nil
+	default:
+		tok, _, err := g.NewToken(ctx, "")
+		if err != nil {
+			return nil, "", err
+		}
+		return oc, tok.AccessToken, nil
+	}
+}
+
+func (o tokenEndpointOptions) typeHint() string {
+	if o.TypeHint != "" {
+		return o.TypeHint
+	} else if o.Refresh {
+		return authutil.TokenTypeHintRefreshToken
+	}
+	return ""
+}
+
+type tokenRevokeCommand struct {
+	tokenEndpointOptions
+	g *globalOptions
+}
+
+func (cmd *tokenRevokeCommand) Execute(args []string) error {
+	ctx := context.Background()
+	oc, token, err := cmd.resolve(ctx, cmd.g, args)
+	if err != nil {
+		return err
+	}
+	revocationURL := cmd.URL
+	clientID, clientSecret := "", ""
+	if oc != nil {
+		revocationURL = firstNonEmpty(revocationURL, oc.RevocationURL)
+		clientID, clientSecret = oc.ClientID, oc.ClientSecret
+	}
+	if revocationURL == "" {
+		return configError(errors.New("no revocation url: set --url or `oauth2.revocationURL`"))
+	}
+	if err := authutil.RevokeToken(ctx, revocationURL, clientID, clientSecret, token, cmd.typeHint()); err != nil {
+		return err
+	}
+	if cache := cmd.g.TokenCache(); cache != nil && cmd.g.CredsPath != "" && cmd.g.Account != "" {
+		if key, err := cmd.g.fileProvider().CacheKey(); err == nil {
+			if err := cache.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	return cmd.g.print(map[string]any{"revoked": true}, func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, "Token revoked")
+		return err
+	})
+}
+
+type tokenIntrospectCommand struct {
+	tokenEndpointOptions
+	g *globalOptions
+}
+
+func (cmd *tokenIntrospectCommand) Execute(args []string) error {
+	ctx := context.Background()
+	oc, token, err := cmd.resolve(ctx, cmd.g, args)
+	if err != nil {
+		return err
+	}
+	introspectionURL := cmd.URL
+	clientID, clientSecret := "", ""
+	if oc != nil {
+		introspectionURL = firstNonEmpty(introspectionURL, oc.IntrospectionURL)
+		clientID, clientSecret = oc.ClientID, oc.ClientSecret
+	}
+	if introspectionURL == "" {
+		return configError(errors.New("no introspection url: set --url or `oauth2.introspectionURL`"))
+	}
+	ir, err := introspect.Introspect(ctx, introspectionURL, clientID, clientSecret, token, cmd.typeHint())
+	if err != nil {
+		return err
+	}
+	if err := cmd.g.print(ir, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "active: %v\nsubject: %s\nusername: %s\nclient_id: %s\nscope: %s\nexp: %d\n",
+			ir.Active, ir.Subject, ir.Username, ir.ClientID, ir.Scope, ir.Expiration)
+		return err
+	}); err != nil {
+		return err
+	} else if !ir.Active {
+		return authErrorf("token is not active")
+	}
+	return nil
+}
+
+type tokenClearCommand struct {
+	g *globalOptions
+}
+
+func (cmd *tokenClearCommand) Execute(args []string) error {
+	cache, err := authutil.NewTokenCache("")
+	if err != nil {
+		return err
+	}
+	if cmd.g.Account == "" {
+		if count, err := cache.Clear(); err != nil {
+			return err
+		} else {
+			return cmd.g.print(map[string]any{"removed": count, "dir": cache.Dir}, func(w io.Writer) error {
+				_, err := fmt.Fprintf(w, "Removed %d cached tokens from %s\n", count, cache.Dir)
+				return err
+			})
+		}
+	} else if err := cmd.g.requireAccount(); err != nil {
+		return err
+	}
+	if key, err := cmd.g.fileProvider().CacheKey(); err != nil {
+		return err
+	} else if err := cache.Delete(key); err != nil {
+		return err
+	}
+	return cmd.g.print(map[string]any{"removed": 1, "account": cmd.g.Account}, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Removed cached token for account `%s`\n", cmd.g.Account)
+		return err
+	})
+}
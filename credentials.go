@@ -39,6 +39,13 @@ type Credentials struct {
 	OAuth2       *CredentialsOAuth2       `json:"oauth2,omitempty"`
 	Token        *oauth2.Token            `json:"token,omitempty"`
 	Additional   url.Values               `json:"additional,omitempty"`
+	// Transport configures the HTTP transport for token and API requests of any type.
+	Transport *authutil.TransportConfig `json:"transport,omitempty"`
+	// HTTPClient, if set, is used for token endpoint requests instead of a client built from
+	// `Transport` or one set under the `oauth2.HTTPClient` context key.
+	HTTPClient *http.Client `json:"-"`
+	// Custom holds settings decoded for a type registered with `RegisterType()`.
+	Custom any `json:"-"`
 }
 
 func NewCredentialsFromCLI(inclAccountsOnError bool) (Credentials, error) {
@@ -111,6 +118,7 @@ var (
 	ErrOAuth2NotPopulated      = errors.New("oauth2 is not populated")
 	ErrTypeNotSupported        = errors.New("credentials type not supported")
 	ErrGCPSANotPopulated       = errors.New("gcp service account credentials are not populated")
+	ErrAccountNotFound         = errors.New("credentials key not found")
 )
 
 func NewClient(ctx context.Context, goauthfile, goauthkey string) (*http.Client, error) {
@@ -122,27 +130,35 @@ func NewClient(ctx context.Context, goauthfile, goauthkey string) (*http.Client,
 }
 
 func (creds *Credentials) NewClient(ctx context.Context) (*http.Client, error) {
-	switch creds.Type {
-	case TypeBasic:
-		if creds.Basic == nil {
-			return nil, ErrBasicAuthNotPopulated
-		} else {
-			return creds.Basic.NewClient()
-		}
-	case TypeGCPSA:
-		if creds.GCPSA == nil {
-			return nil, ErrGCPSANotPopulated
-		} else {
-			return creds.GCPSA.NewClient(ctx)
-		}
-	case TypeHeaderQuery:
-		if creds.HeaderQuery == nil {
-			return nil, ErrHeaderQueryNotPopulated
-		}
-		return creds.HeaderQuery.NewClient(), nil
-	case TypeJWT:
-		return nil, ErrJWTNotSupported
+	ctx, err := creds.tokenContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var clt *http.Client
+	if ct, ok := LookupType(creds.Type); ok {
+		clt, err = ct.NewClient(ctx, creds)
+	} else {
+		clt, err = newClientOAuth2(ctx, creds)
+	}
+	if err != nil || creds.Transport == nil {
+		return clt, err
 	}
+	return creds.Transport.ApplyToClient(clt)
+}
+
+// tokenContext adds the client for token requests to the context, using `HTTPClient` or
+// a client built from `Transport` if set.
+func (creds *Credentials) tokenContext(ctx context.Context) (context.Context, error) {
+	if creds.HTTPClient != nil {
+		return authutil.ContextWithHTTPClient(ctx, creds.HTTPClient), nil
+	} else if creds.Transport != nil {
+		return creds.Transport.ContextWithClient(ctx)
+	}
+	return ctx, nil
+}
+
+// newClientOAuth2 is the client factory for OAuth 2.0 types and credentials without a registered type.
+func newClientOAuth2(ctx context.Context, creds *Credentials) (*http.Client, error) {
 	if creds.Token != nil {
 		return authutil.NewClientToken(authutil.TokenBearer, creds.Token.AccessToken, false), nil
 	}
@@ -169,23 +185,21 @@ func (creds *Credentials) NewSimpleClient(ctx context.Context) (*httpsimple.Clie
 }
 
 func (creds *Credentials) NewSimpleClientHTTP(httpClient *http.Client) (*httpsimple.Client, error) {
-	switch creds.Type {
-	case TypeJWT:
-		return nil, ErrJWTNotSupported
-	case TypeBasic:
-		return &httpsimple.Client{
-			BaseURL:    creds.Basic.ServerURL,
-			HTTPClient: httpClient}, nil
-	case TypeHeaderQuery:
-		return &httpsimple.Client{
-			BaseURL:    creds.HeaderQuery.ServerURL,
-			HTTPClient: httpClient}, nil
-	case TypeOAuth2:
+	if svrURL, err := creds.ServerURL(); err != nil {
+		return nil, err
+	} else {
 		return &httpsimple.Client{
-			BaseURL:    creds.OAuth2.ServerURL,
+			BaseURL:    svrURL,
 			HTTPClient: httpClient}, nil
-	default:
-		return nil, ErrTypeNotSupported
+	}
+}
+
+// ServerURL returns the API base URL for the credentials type, e.g. `oauth2.serverURL`.
+func (creds *Credentials) ServerURL() (string, error) {
+	if ct, ok := LookupType(creds.Type); !ok || ct.ServerURL == nil {
+		return "", ErrTypeNotSupported
+	} else {
+		return ct.ServerURL(creds)
 	}
 }
 
@@ -217,6 +231,10 @@ func (creds *Credentials) ExistingValidToken() (*oauth2.Token, error) {
 }
 
 func (creds *Credentials) NewToken(ctx context.Context) (*oauth2.Token, error) {
+	ctx, err := creds.tokenContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	switch creds.Type {
 	case TypeOAuth2:
 		if creds.OAuth2 == nil {
@@ -239,7 +257,11 @@ func (creds *Credentials) NewToken(ctx context.Context) (*oauth2.Token, error) {
 // NewTokenCLI retrieves a token using CLI approach for
 // OAuth 2.0 authorization code or password grant.
 func (creds *Credentials) NewTokenCLI(ctx context.Context, oauth2State string) (*oauth2.Token, error) {
-	if strings.EqualFold(strings.TrimSpace(creds.OAuth2.GrantType), authutil.GrantTypeAuthorizationCode) {
+	ctx, err := creds.tokenContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if creds.OAuth2 != nil && strings.EqualFold(strings.TrimSpace(creds.OAuth2.GrantType), authutil.GrantTypeAuthorizationCode) {
 		return NewTokenCLI(ctx, *creds, oauth2State)
 	} else {
 		return creds.NewToken(ctx)
@@ -0,0 +1,308 @@
+package goauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/mogo/type/maputil"
+)
+
+const (
+	ExportFormatDotEnv           = "dotenv"
+	ExportFormatKubernetesSecret = "k8ssecret"
+	ExportFormatCurl             = "curl"
+	ExportFormatHTTPie           = "httpie"
+
+	// RedactedValue replaces secret values when exporting without `reveal`.
+	RedactedValue = "********"
+)
+
+var rxEnvNameUnsafe = regexp.MustCompile(`[^A-Z0-9_]+`)
+
+// EnvVarName converts a header or query parameter name to an environment variable name suffix.
+func EnvVarName(s string) string {
+	return strings.Trim(rxEnvNameUnsafe.ReplaceAllString(strings.ToUpper(strings.TrimSpace(s)), "_"), "_")
+}
+
+// ErrEnvNotRepresentable is returned by `Credentials.EnvVars()` for header or query
+// parameters which `NewCredentialsEnv()` cannot read back unchanged, e.g. parameters
+// with multiple values or names which do not survive the conversion to a variable name.
+var ErrEnvNotRepresentable = errors.New("credentials parameter cannot be represented as an environment variable")
+
+// EnvVars returns the credentials as environment variables using the naming scheme
+// read by `NewCredentialsEnv()`. Google OAuth 2.0 credentials are written as `oauth2`
+// credentials. The second return value lists the names of variables holding secrets.
+func (creds *Credentials) EnvVars(envPrefix string) (map[string]string, []string, error) {
+	vars := map[string]string{}
+	secrets := []string{}
+	add := func(suffix, val string, secret bool) {
+		if strings.TrimSpace(val) == "" {
+			return
+		}
+		vars[envPrefix+suffix] = val
+		if secret {
+			secrets = append(secrets, envPrefix+suffix)
+		}
+	}
+	if creds.Type == TypeGoogleOAuth2 {
+		add(EnvSuffixType, TypeOAuth2, false)
+	} else {
+		add(EnvSuffixType, creds.Type, false)
+	}
+	add(EnvSuffixService, creds.Service, false)
+
+	switch creds.Type {
+	case TypeBasic:
+		if creds.Basic == nil {
+			return nil, nil, ErrBasicAuthNotPopulated
+		}
+		add(EnvSuffixServerURL, creds.Basic.ServerURL, false)
+		add(EnvSuffixUsername, creds.Basic.Username, false)
+		add(EnvSuffixPassword, creds.Basic.Password, true)
+		add(EnvSuffixEncoded, creds.Basic.Encoded, true)
+	case TypeHeaderQuery:
+		if creds.HeaderQuery == nil {
+			return nil, nil, ErrHeaderQueryNotPopulated
+		}
+		add(EnvSuffixServerURL, creds.HeaderQuery.ServerURL, false)
+		for k, vals := range creds.HeaderQuery.Header {
+			if len(vals) > 1 || envHeaderName(EnvVarName(k)) != textproto.CanonicalMIMEHeaderKey(k) {
+				return nil, nil, fmt.Errorf("%w (header %s)", ErrEnvNotRepresentable, k)
+			}
+			add(EnvSuffixHeaderPrefix+EnvVarName(k), strings.Join(vals, ""), true)
+		}
+		for k, vals := range creds.HeaderQuery.Query {
+			if len(vals) > 1 || envQueryName(EnvVarName(k)) != k {
+				return nil, nil, fmt.Errorf("%w (query %s)", ErrEnvNotRepresentable, k)
+			}
+			add(EnvSuffixQueryPrefix+EnvVarName(k), strings.Join(vals, ""), true)
+		}
+	case TypeOAuth2, TypeGoogleOAuth2:
+		var oc CredentialsOAuth2
+		if creds.Type == TypeOAuth2 {
+			if creds.OAuth2 == nil {
+				return nil, nil, ErrOAuth2NotPopulated
+			}
+			oc = *creds.OAuth2
+		} else {
+			if creds.GoogleOAuth2 == nil {
+				return nil, nil, fmt.Errorf("credentials.%s is nil for type `%s`", TypeGoogleOAuth2, TypeGoogleOAuth2)
+			}
+			oc = creds.GoogleOAuth2.CredentialsOAuth2()
+			oc.Token = creds.GoogleOAuth2.Token
+		}
+		add(EnvSuffixServerURL, oc.ServerURL, false)
+		add(EnvSuffixClientID, oc.ClientID, false)
+		add(EnvSuffixClientSecret, oc.ClientSecret, true)
+		add(EnvSuffixAuthURL, oc.Endpoint.AuthURL, false)
+		add(EnvSuffixTokenURL, oc.Endpoint.TokenURL, false)
+		add(EnvSuffixRedirectURL, oc.RedirectURL, false)
+		add(EnvSuffixGrantType, oc.GrantType, false)
+		add(EnvSuffixScopes, strings.Join(oc.Scopes, ","), false)
+		add(EnvSuffixUsername, oc.Username, false)
+		add(EnvSuffixPassword, oc.Password, true)
+		if oc.Token != nil {
+			add(EnvSuffixToken, oc.Token.AccessToken, true)
+		}
+	default:
+		return nil, nil, ErrTypeNotSupported
+	}
+	if creds.Token != nil {
+		add(EnvSuffixToken, creds.Token.AccessToken, true)
+	}
+	slices.Sort(secrets)
+	return vars, slices.Compact(secrets), nil
+}
+
+func (creds *Credentials) envVarsExport(envPrefix string, reveal bool) (map[string]string, error) {
+	vars, secrets, err := creds.EnvVars(envPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if !reveal {
+		for _, k := range secrets {
+			vars[k] = RedactedValue
+		}
+	}
+	return vars, nil
+}
+
+// ExportDotEnv writes the credentials as a `.env` file which can be loaded by
+// `github.com/joho/godotenv` and read with `NewCredentialsEnv()`. Secrets are
+// redacted unless `reveal` is true.
+func (creds *Credentials) ExportDotEnv(w io.Writer, envPrefix string, reveal bool) error {
+	vars, err := creds.envVarsExport(envPrefix, reveal)
+	if err != nil {
+		return err
+	}
+	for _, k := range maputil.Keys(vars) {
+		if _, err := fmt.Fprintf(w, "%s=\"%s\"\n", k, dotEnvEscape(vars[k])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dotEnvEscape escapes a value for a double quoted `.env` value.
+func dotEnvEscape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		"\n", `\n`,
+		"\r", `\r`,
+		`"`, `\"`,
+		`!`, `\!`,
+		`$`, `\$`,
+		"`", "\\`").Replace(s)
+}
+
+// ExportKubernetesSecret writes the credentials as a Kubernetes `Secret` YAML manifest
+// using the same keys as `ExportDotEnv()`. Secrets are redacted unless `reveal` is true.
+func (creds *Credentials) ExportKubernetesSecret(w io.Writer, name, namespace, envPrefix string, reveal bool) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("kubernetes secret name must not be empty")
+	}
+	vars, err := creds.envVarsExport(envPrefix, reveal)
+	if err != nil {
+		return err
+	}
+	lines := []string{
+		"apiVersion: v1",
+		"kind: Secret",
+		"metadata:",
+		"  name: " + yamlString(name)}
+	if strings.TrimSpace(namespace) != "" {
+		lines = append(lines, "  namespace: "+yamlString(namespace))
+	}
+	lines = append(lines, "type: Opaque", "stringData:")
+	for _, k := range maputil.Keys(vars) {
+		lines = append(lines, "  "+k+": "+yamlString(vars[k]))
+	}
+	_, err = fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// yamlString returns a double quoted YAML scalar. JSON strings are valid YAML.
+func yamlString(s string) string {
+	if b, err := json.Marshal(s); err != nil {
+		return `""`
+	} else {
+		return string(b)
+	}
+}
+
+// ResolveRequest returns a copy of the supplied request with the `Authorization` header,
+// other headers, and query parameters added by the credentials' `*http.Client`, without
+// sending it.
+func (creds *Credentials) ResolveRequest(ctx context.Context, method, reqURL string) (*http.Request, error) {
+	if clt, err := creds.NewClient(ctx); err != nil {
+		return nil, err
+	} else if req, err := http.NewRequestWithContext(ctx, method, reqURL, nil); err != nil {
+		return nil, err
+	} else {
+		return authutil.ResolveRequest(clt, req)
+	}
+}
+
+// ExportCommand writes a ready-to-run `curl` or HTTPie command line for the request
+// including the credentials resolved by the account's `*http.Client`. Header and query
+// parameter values added by the credentials are redacted unless `reveal` is true.
+func (creds *Credentials) ExportCommand(ctx context.Context, w io.Writer, format, method, reqURL string, reveal bool) error {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "" {
+		method = http.MethodGet
+	}
+	orig, err := http.NewRequestWithContext(ctx, method, reqURL, nil)
+	if err != nil {
+		return err
+	}
+	req, err := creds.ResolveRequest(ctx, method, reqURL)
+	if err != nil {
+		return err
+	}
+	if !reveal {
+		redactAddedParams(orig, req)
+	}
+	var parts []string
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case ExportFormatCurl:
+		parts = []string{"curl", "-X", method, shellQuote(req.URL.String())}
+		for _, k := range maputil.Keys(req.Header) {
+			for _, v := range req.Header[k] {
+				parts = append(parts, "-H", shellQuote(k+": "+v))
+			}
+		}
+	case ExportFormatHTTPie:
+		parts = []string{"http", method, shellQuote(req.URL.String())}
+		for _, k := range maputil.Keys(req.Header) {
+			for _, v := range req.Header[k] {
+				parts = append(parts, shellQuote(k+":"+v))
+			}
+		}
+	default:
+		return fmt.Errorf("export command format not supported (%s)", format)
+	}
+	_, err = fmt.Fprintln(w, strings.Join(parts, " "))
+	return err
+}
+
+// redactAddedParams replaces header and query values which are not present in `orig`.
+func redactAddedParams(orig, req *http.Request) {
+	for k, vals := range req.Header {
+		for i, v := range vals {
+			if !slices.Contains(orig.Header.Values(k), v) {
+				vals[i] = RedactedValue
+			}
+		}
+	}
+	origQuery := orig.URL.Query()
+	qry := req.URL.Query()
+	for k, vals := range qry {
+		for i, v := range vals {
+			if !slices.Contains(origQuery[k], v) {
+				vals[i] = RedactedValue
+			}
+		}
+	}
+	req.URL.RawQuery = strings.ReplaceAll(qry.Encode(), url.QueryEscape(RedactedValue), RedactedValue)
+}
+
+// shellQuote quotes a string for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Export writes the credentials in the format specified by `opts.Format`.
+func (creds *Credentials) Export(ctx context.Context, w io.Writer, opts ExportOptions) error {
+	switch strings.ToLower(strings.TrimSpace(opts.Format)) {
+	case ExportFormatDotEnv, "":
+		return creds.ExportDotEnv(w, opts.EnvPrefix, opts.Reveal)
+	case ExportFormatKubernetesSecret:
+		return creds.ExportKubernetesSecret(w, opts.Name, opts.Namespace, opts.EnvPrefix, opts.Reveal)
+	case ExportFormatCurl, ExportFormatHTTPie:
+		return creds.ExportCommand(ctx, w, opts.Format, opts.Method, opts.URL, opts.Reveal)
+	default:
+		return fmt.Errorf("export format not supported (%s)", opts.Format)
+	}
+}
+
+// ExportOptions is used with `Credentials.Export()`. Tags support usage with
+// `github.com/jessevdk/go-flags`.
+type ExportOptions struct {
+	Format    string `long:"format" description:"Export format: dotenv, k8ssecret, curl, httpie" default:"dotenv"`
+	EnvPrefix string `long:"env-prefix" description:"Environment variable name prefix"`
+	Name      string `long:"name" description:"Kubernetes Secret name"`
+	Namespace string `long:"namespace" description:"Kubernetes Secret namespace"`
+	Method    string `short:"X" long:"request" description:"Request method for curl and httpie"`
+	URL       string `long:"url" description:"Request URL for curl and httpie"`
+	Reveal    bool   `long:"reveal" description:"Print secrets instead of redacting them"`
+}
@@ -0,0 +1,193 @@
+package goauth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/goauth/google"
+	"github.com/joho/godotenv"
+	"golang.org/x/oauth2"
+)
+
+var envVarsRoundTripTests = []struct {
+	name  string
+	creds Credentials
+}{
+	{"basic", Credentials{Type: TypeBasic, Basic: &CredentialsBasicAuth{
+		ServerURL: "https://api.example.com", Username: "alice", Password: `p@ss"word$`}}},
+	{"headerquery", Credentials{Type: TypeHeaderQuery, Service: "example", HeaderQuery: &CredentialsHeaderQuery{
+		ServerURL: "https://api.example.com",
+		Header:    http.Header{"X-Api-Key": []string{"abc"}, "Authorization": []string{"Token def"}},
+		Query:     url.Values{"api_key": []string{"ghi"}}}}},
+	{"oauth2", Credentials{Type: TypeOAuth2, OAuth2: &CredentialsOAuth2{
+		ServerURL:    "https://api.example.com",
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Endpoint:     oauth2.Endpoint{AuthURL: "https://example.com/authorize", TokenURL: "https://example.com/token"},
+		GrantType:    authutil.GrantTypeClientCredentials,
+		Scopes:       []string{"read", "write"}},
+		Token: &oauth2.Token{AccessToken: "tok"}}},
+	{"googleoauth2", Credentials{Type: TypeGoogleOAuth2, GoogleOAuth2: &CredentialsGoogleOAuth2{
+		GoogleWebCredentials: google.Credentials{ClientID: "gclient", ClientSecret: "gsecret",
+			AuthURI: "https://accounts.google.com/o/oauth2/auth", TokenURI: "https://oauth2.googleapis.com/token",
+			RedirectURIs: []string{"http://localhost:8080/callback"}},
+		Scopes: []string{"openid"}}}},
+}
+
+func TestEnvVarsRoundTrip(t *testing.T) {
+	for _, tt := range envVarsRoundTripTests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars, secrets, err := tt.creds.EnvVars("GOAUTHTEST_")
+			if err != nil {
+				t.Fatalf("Credentials.EnvVars(%s): err [%v]", tt.name, err)
+			}
+			for k, v := range vars {
+				t.Setenv(k, v)
+			}
+			loaded, ok := NewCredentialsEnv("GOAUTHTEST_")
+			if !ok {
+				t.Fatalf("NewCredentialsEnv(%s): want [true], got [false]", tt.name)
+			}
+			vars2, secrets2, err := loaded.EnvVars("GOAUTHTEST_")
+			if err != nil {
+				t.Fatalf("Credentials.EnvVars(%s) after load: err [%v]", tt.name, err)
+			}
+			if !maps.Equal(vars, vars2) {
+				t.Errorf("NewCredentialsEnv(%s): want [%v], got [%v]", tt.name, vars, vars2)
+			}
+			if !slices.Equal(secrets, secrets2) {
+				t.Errorf("NewCredentialsEnv(%s) secrets: want [%v], got [%v]", tt.name, secrets, secrets2)
+			}
+		})
+	}
+}
+
+func TestEnvVarsNotRepresentable(t *testing.T) {
+	for _, hq := range []CredentialsHeaderQuery{
+		{Header: http.Header{"X-Api-Key": []string{"a", "b"}}},
+		{Header: http.Header{"X_api_key": []string{"a"}}},
+		{Query: url.Values{"apiKey": []string{"a"}}},
+		{Query: url.Values{"key": []string{"a", "b"}}},
+	} {
+		creds := Credentials{Type: TypeHeaderQuery, HeaderQuery: &hq}
+		if _, _, err := creds.EnvVars(""); !errors.Is(err, ErrEnvNotRepresentable) {
+			t.Errorf("Credentials.EnvVars(%v): want [%v], got [%v]", hq, ErrEnvNotRepresentable, err)
+		}
+	}
+}
+
+func TestExportDotEnv(t *testing.T) {
+	creds := Credentials{Type: TypeBasic, Basic: &CredentialsBasicAuth{
+		ServerURL: "https://api.example.com", Username: "alice", Password: "a`b$c!d\\e\nf\"g"}}
+	vars, secrets, err := creds.EnvVars("MYAPP_")
+	if err != nil {
+		t.Fatalf("Credentials.EnvVars(): err [%v]", err)
+	}
+	for _, reveal := range []bool{true, false} {
+		var buf bytes.Buffer
+		if err := creds.ExportDotEnv(&buf, "MYAPP_", reveal); err != nil {
+			t.Fatalf("Credentials.ExportDotEnv(%v): err [%v]", reveal, err)
+		}
+		got, err := godotenv.Unmarshal(buf.String())
+		if err != nil {
+			t.Fatalf("godotenv.Unmarshal(%s): err [%v]", buf.String(), err)
+		}
+		want := maps.Clone(vars)
+		if !reveal {
+			for _, k := range secrets {
+				want[k] = RedactedValue
+			}
+		}
+		if !maps.Equal(want, got) {
+			t.Errorf("Credentials.ExportDotEnv(%v): want [%v], got [%v]", reveal, want, got)
+		}
+	}
+}
+
+func TestExportKubernetesSecret(t *testing.T) {
+	creds := Credentials{Type: TypeBasic, Basic: &CredentialsBasicAuth{Username: "alice", Password: "secret"}}
+	var buf bytes.Buffer
+	if err := creds.ExportKubernetesSecret(&buf, "my-app", "prod", "MYAPP_", false); err != nil {
+		t.Fatalf("Credentials.ExportKubernetesSecret(): err [%v]", err)
+	}
+	want := `apiVersion: v1
+kind: Secret
+metadata:
+  name: "my-app"
+  namespace: "prod"
+type: Opaque
+stringData:
+  MYAPP_PASSWORD: "` + RedactedValue + `"
+  MYAPP_TYPE: "basic"
+  MYAPP_USERNAME: "alice"
+`
+	if buf.String() != want {
+		t.Errorf("Credentials.ExportKubernetesSecret(): want [%s], got [%s]", want, buf.String())
+	}
+	if err := creds.ExportKubernetesSecret(&buf, " ", "", "", false); err == nil {
+		t.Errorf("Credentials.ExportKubernetesSecret(empty name): want err, got nil")
+	}
+}
+
+var exportCommandTests = []struct {
+	format string
+	reveal bool
+	want   string
+}{
+	{ExportFormatCurl, false, `curl -X GET 'https://api.example.com/v1?api_key=` + RedactedValue + `&page=2' -H 'X-Api-Key: ` + RedactedValue + `'`},
+	{ExportFormatCurl, true, `curl -X GET 'https://api.example.com/v1?api_key=ghi&page=2' -H 'X-Api-Key: def'`},
+	{ExportFormatHTTPie, true, `http GET 'https://api.example.com/v1?api_key=ghi&page=2' 'X-Api-Key:def'`},
+}
+
+func TestExportCommand(t *testing.T) {
+	creds := Credentials{Type: TypeHeaderQuery, HeaderQuery: &CredentialsHeaderQuery{
+		Header: http.Header{"X-Api-Key": []string{"def"}},
+		Query:  url.Values{"api_key": []string{"ghi"}}}}
+	for _, tt := range exportCommandTests {
+		var buf bytes.Buffer
+		if err := creds.ExportCommand(context.Background(), &buf, tt.format, "", "https://api.example.com/v1?page=2", tt.reveal); err != nil {
+			t.Fatalf("Credentials.ExportCommand(%s): err [%v]", tt.format, err)
+		}
+		if got := strings.TrimSpace(buf.String()); got != tt.want {
+			t.Errorf("Credentials.ExportCommand(%s, %v): want [%s], got [%s]", tt.format, tt.reveal, tt.want, got)
+		}
+	}
+}
+
+func TestRedactAddedParams(t *testing.T) {
+	orig, err := http.NewRequest(http.MethodGet, "https://api.example.com/v1?page=2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig.Header.Set("Accept", "application/json")
+	req := orig.Clone(orig.Context())
+	req.Header.Set("Authorization", "Bearer abc")
+	req.Header.Set("X-Api-Key", "def")
+	qry := req.URL.Query()
+	qry.Set("api_key", "ghi")
+	req.URL.RawQuery = qry.Encode()
+
+	redactAddedParams(orig, req)
+	for k, want := range map[string]string{
+		"Accept":        "application/json",
+		"Authorization": RedactedValue,
+		"X-Api-Key":     RedactedValue} {
+		if got := req.Header.Get(k); got != want {
+			t.Errorf("redactAddedParams() header %s: want [%s], got [%s]", k, want, got)
+		}
+	}
+	if want := "api_key=" + RedactedValue + "&page=2"; req.URL.RawQuery != want {
+		t.Errorf("redactAddedParams() query: want [%s], got [%s]", want, req.URL.RawQuery)
+	}
+	if strings.Contains(req.URL.String(), "ghi") {
+		t.Errorf("redactAddedParams() url: want redacted, got [%s]", req.URL.String())
+	}
+}
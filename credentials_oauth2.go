@@ -46,6 +46,16 @@ type CredentialsOAuth2 struct {
 	AuthCodeExchangeOpts map[string][]string `json:"authCodeExchangeOpts,omitempty"`
 	TokenBodyOpts        url.Values          `json:"tokenBodyOpts,omitempty"`
 	Metadata             map[string]string   `json:"metadata,omitempty"`
+	RevocationURL        string              `json:"revocationURL,omitempty"`    // RFC 7009
+	IntrospectionURL     string              `json:"introspectionURL,omitempty"` // RFC 7662
+	// HTTPClient, if set, is used for token endpoint requests instead of a client set under
+	// the `oauth2.HTTPClient` context key.
+	HTTPClient *http.Client `json:"-"`
+}
+
+// tokenContext adds `HTTPClient`, if set, to the context for token endpoint requests.
+func (oc *CredentialsOAuth2) tokenContext(ctx context.Context) context.Context {
+	return authutil.ContextWithHTTPClient(ctx, oc.HTTPClient)
 }
 
 func ParseCredentialsOAuth2(b []byte) (CredentialsOAuth2, error) {
@@ -123,7 +133,7 @@ func (oc *CredentialsOAuth2) Exchange(ctx context.Context, code string, opts map
 	authCodeOptions.AddMap(oc.AuthCodeExchangeOpts)
 	authCodeOptions.AddMap(opts)
 	cfg := oc.Config()
-	return cfg.Exchange(ctx, code, authCodeOptions...)
+	return cfg.Exchange(oc.tokenContext(ctx), code, authCodeOptions...)
 }
 
 func (oc *CredentialsOAuth2) IsGrantType(grantType string) bool {
@@ -137,6 +147,7 @@ func (oc *CredentialsOAuth2) InflateURL(apiURLPath string) string {
 }
 
 func (oc *CredentialsOAuth2) NewClient(ctx context.Context) (*http.Client, *oauth2.Token, error) {
+	ctx = oc.tokenContext(ctx)
 	if tok, err := oc.NewToken(ctx); err != nil {
 		return nil, tok, err
 	} else {
@@ -154,6 +165,7 @@ func (oc *CredentialsOAuth2) NewClient(ctx context.Context) (*http.Client, *oaut
 // auth code and then `Exchange` it for a token. The `state` value is currently a randomly generated
 // string as this should be used for testing purposes only.
 func (oc *CredentialsOAuth2) NewToken(ctx context.Context) (*oauth2.Token, error) {
+	ctx = oc.tokenContext(ctx)
 	if oc.Token != nil && len(strings.TrimSpace(oc.Token.AccessToken)) > 0 {
 		return oc.Token, nil
 	} else if strings.Contains(strings.ToLower(oc.GrantType), "jwt") {
@@ -223,11 +235,12 @@ func (oc *CredentialsOAuth2) newTokenPasswordCredentialsRequest() (*httpsimple.R
 
 // NewTokenPasswordCredentials provides fine-grained token request.
 func (oc *CredentialsOAuth2) NewTokenPasswordCredentials(ctx context.Context) (*oauth2.Token, error) {
+	ctx = oc.tokenContext(ctx)
 	if sreq, err := oc.newTokenPasswordCredentialsRequest(); err != nil {
 		return nil, err
 	} else if hreq, err := sreq.HTTPRequest(ctx); err != nil {
 		return nil, err
-	} else if resp, err := ctxhttp.Do(ctx, &http.Client{}, hreq); err != nil {
+	} else if resp, err := ctxhttp.Do(ctx, authutil.ContextClient(ctx), hreq); err != nil {
 		return nil, err
 	} else if resp.StatusCode >= 300 {
 		return nil, fmt.Errorf("receted status code (%d)", resp.StatusCode)
@@ -275,6 +288,7 @@ func (oc *CredentialsOAuth2) RefreshToken(ctx context.Context, tok *oauth2.Token
 }
 
 func (oc *CredentialsOAuth2) RefreshTokenSimple(ctx context.Context, refreshToken string) (*oauth2.Token, []byte, error) {
+	ctx = oc.tokenContext(ctx)
 	basicAuthHeader, err := oc.BasicAuthHeader()
 	if err != nil {
 		return nil, []byte{}, err
@@ -296,7 +310,7 @@ func (oc *CredentialsOAuth2) RefreshTokenSimple(ctx context.Context, refreshToke
 		Body: []byte(body.Encode()),
 	}
 
-	if resp, err := sr.Do(ctx, nil); err != nil {
+	if resp, err := sr.Do(ctx, authutil.ContextClient(ctx)); err != nil {
 		return nil, []byte{}, err
 	} else if tokBody, err := io.ReadAll(resp.Body); err != nil {
 		return nil, tokBody, err
@@ -331,14 +345,43 @@ func (oc *CredentialsOAuth2) PasswordRequestBody() url.Values {
 	return body
 }
 
+// Environment variable name suffixes used by `NewCredentialsEnv()`,
+// `NewCredentialsOAuth2Env()` and `Credentials.EnvVars()`. Each is appended to an application specific prefix.
+const (
+	EnvSuffixType         = "TYPE"
+	EnvSuffixService      = "SERVICE"
+	EnvSuffixServerURL    = "SERVER_URL"
+	EnvSuffixClientID     = "CLIENT_ID"
+	EnvSuffixClientSecret = "CLIENT_SECRET" // #nosec G101
+	EnvSuffixAuthURL      = "AUTH_URL"
+	EnvSuffixTokenURL     = "TOKEN_URL" // #nosec G101
+	EnvSuffixRedirectURL  = "REDIRECT_URL"
+	EnvSuffixGrantType    = "GRANT_TYPE"
+	EnvSuffixScopes       = "SCOPES"
+	EnvSuffixUsername     = "USERNAME"
+	EnvSuffixPassword     = "PASSWORD" // #nosec G101
+	EnvSuffixEncoded      = "ENCODED"
+	EnvSuffixToken        = "TOKEN" // #nosec G101
+	EnvSuffixHeaderPrefix = "HEADER_"
+	EnvSuffixQueryPrefix  = "QUERY_"
+)
+
+// NewCredentialsOAuth2Env reads the OAuth 2.0 variables only. Use `NewCredentialsEnv()`
+// to read all variables written by `Credentials.EnvVars()`.
 func NewCredentialsOAuth2Env(envPrefix string) CredentialsOAuth2 {
 	creds := CredentialsOAuth2{
-		ClientID:     os.Getenv(envPrefix + "CLIENT_ID"),
-		ClientSecret: os.Getenv(envPrefix + "CLIENT_SECRET"),
-		ServerURL:    os.Getenv(envPrefix + "SERVER_URL"),
-		Username:     os.Getenv(envPrefix + "USERNAME"),
-		Password:     os.Getenv(envPrefix + "PASSWORD")}
-	if len(strings.TrimSpace(creds.Username)) > 0 {
+		ClientID:     os.Getenv(envPrefix + EnvSuffixClientID),
+		ClientSecret: os.Getenv(envPrefix + EnvSuffixClientSecret),
+		ServerURL:    os.Getenv(envPrefix + EnvSuffixServerURL),
+		RedirectURL:  os.Getenv(envPrefix + EnvSuffixRedirectURL),
+		GrantType:    strings.TrimSpace(os.Getenv(envPrefix + EnvSuffixGrantType)),
+		Scopes:       stringsutil.SplitTrimSpace(os.Getenv(envPrefix+EnvSuffixScopes), ",", true),
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  os.Getenv(envPrefix + EnvSuffixAuthURL),
+			TokenURL: os.Getenv(envPrefix + EnvSuffixTokenURL)},
+		Username: os.Getenv(envPrefix + EnvSuffixUsername),
+		Password: os.Getenv(envPrefix + EnvSuffixPassword)}
+	if creds.GrantType == "" && len(strings.TrimSpace(creds.Username)) > 0 {
 		creds.GrantType = authutil.GrantTypePassword
 	}
 	return creds
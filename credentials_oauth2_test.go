@@ -0,0 +1,67 @@
+package goauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grokify/goauth/authutil"
+)
+
+type countingTransport struct {
+	count int
+}
+
+func (ct *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ct.count++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+var tokenClientTests = []struct {
+	grantType   string
+	explicit    bool
+	wantToken   string
+	wantCounted int
+}{
+	{authutil.GrantTypeClientCredentials, true, "abc", 1},
+	{authutil.GrantTypeClientCredentials, false, "abc", 1},
+	{authutil.GrantTypePassword, true, "abc", 1},
+	{authutil.GrantTypePassword, false, "abc", 1},
+}
+
+func TestCredentialsOAuth2TokenHTTPClient(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"abc","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer svr.Close()
+
+	for _, tt := range tokenClientTests {
+		xport := &countingTransport{}
+		clt := &http.Client{Transport: xport}
+		oc := CredentialsOAuth2{
+			ClientID:     "id",
+			ClientSecret: "secret",
+			Username:     "user",
+			Password:     "pass",
+			GrantType:    tt.grantType}
+		oc.Endpoint.TokenURL = svr.URL
+		ctx := context.Background()
+		if tt.explicit {
+			oc.HTTPClient = clt
+		} else {
+			ctx = authutil.ContextWithHTTPClient(ctx, clt)
+		}
+		tok, err := oc.NewToken(ctx)
+		if err != nil {
+			t.Fatalf("CredentialsOAuth2.NewToken(%s): err [%v]", tt.grantType, err)
+		}
+		if tok.AccessToken != tt.wantToken {
+			t.Errorf("CredentialsOAuth2.NewToken(%s): want token [%s], got [%s]", tt.grantType, tt.wantToken, tok.AccessToken)
+		}
+		if xport.count != tt.wantCounted {
+			t.Errorf("CredentialsOAuth2.NewToken(%s) explicit [%v]: want requests [%d], got [%d]", tt.grantType, tt.explicit, tt.wantCounted, xport.count)
+		}
+	}
+}
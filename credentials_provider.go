@@ -0,0 +1,587 @@
+package goauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/grokify/goauth/authutil"
+	"golang.org/x/oauth2"
+)
+
+const (
+	ProviderNameToken       = "token"
+	ProviderNameEnv         = "env"
+	ProviderNameCache       = "cache"
+	ProviderNameFile        = "file"
+	ProviderNameInteractive = "interactive"
+
+	// EnvPrefixDefault is the environment variable prefix used by `Options`, e.g. `GOAUTH_TOKEN`.
+	EnvPrefixDefault = "GOAUTH_"
+)
+
+var (
+	// ErrProviderSkipped is wrapped by errors returned from `Provider.NewClient()` when the
+	// provider has nothing to offer, so the next provider in a `ProviderChain` is tried.
+	ErrProviderSkipped = errors.New("provider skipped")
+	// ErrNoCredentials is returned by `ProviderChain.NewClient()` when every provider is skipped.
+	ErrNoCredentials = errors.New("no credentials found")
+)
+
+// Provider resolves an `*http.Client` or `*oauth2.Token` from a single credentials source.
+// Methods return an error wrapping `ErrProviderSkipped` with the reason when the source is
+// not configured. Other errors stop the chain.
+type Provider interface {
+	Name() string
+	NewClient(ctx context.Context) (*http.Client, error)
+	Token(ctx context.Context) (*oauth2.Token, error)
+}
+
+// skipf returns an error wrapping `ErrProviderSkipped` with the reason.
+func skipf(format string, a ...any) error {
+	return fmt.Errorf("%w: %s", ErrProviderSkipped, fmt.Sprintf(format, a...))
+}
+
+// ProviderResult reports the outcome of a provider in a `ProviderChain`.
+type ProviderResult struct {
+	Provider string
+	Skipped  bool
+	Reason   string
+}
+
+func (res ProviderResult) String() string {
+	if res.Skipped {
+		return fmt.Sprintf("%s: skipped: %s", res.Provider, res.Reason)
+	} else if res.Reason != "" {
+		return fmt.Sprintf("%s: failed: %s", res.Provider, res.Reason)
+	}
+	return fmt.Sprintf("%s: used", res.Provider)
+}
+
+// ProviderResults is the list of providers tried by `ProviderChain.Resolve()`.
+type ProviderResults []ProviderResult
+
+// Used returns the name of the provider which supplied the client, if any.
+func (results ProviderResults) Used() string {
+	for _, res := range results {
+		if !res.Skipped && res.Reason == "" {
+			return res.Provider
+		}
+	}
+	return ""
+}
+
+// Write writes one line per provider result.
+func (results ProviderResults) Write(w io.Writer) error {
+	for _, res := range results {
+		if _, err := fmt.Fprintln(w, res.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ProviderChain tries providers in order and uses the first one which is not skipped.
+type ProviderChain []Provider
+
+// Resolve returns the client from the first provider which is not skipped along with the
+// results of each provider tried. A provider error other than a skip stops the chain.
+func (pc ProviderChain) Resolve(ctx context.Context) (*http.Client, ProviderResults, error) {
+	var clt *http.Client
+	results, err := pc.resolve(func(p Provider) (err error) {
+		clt, err = p.NewClient(ctx)
+		return err
+	})
+	return clt, results, err
+}
+
+// ResolveToken is like `Resolve()` but returns an access token.
+func (pc ProviderChain) ResolveToken(ctx context.Context) (*oauth2.Token, ProviderResults, error) {
+	var tok *oauth2.Token
+	results, err := pc.resolve(func(p Provider) (err error) {
+		tok, err = p.Token(ctx)
+		return err
+	})
+	return tok, results, err
+}
+
+func (pc ProviderChain) resolve(try func(p Provider) error) (ProviderResults, error) {
+	var results ProviderResults
+	var reasons []string
+	for _, p := range pc {
+		err := try(p)
+		if errors.Is(err, ErrProviderSkipped) {
+			reason := strings.TrimPrefix(err.Error(), ErrProviderSkipped.Error()+": ")
+			results = append(results, ProviderResult{Provider: p.Name(), Skipped: true, Reason: reason})
+			reasons = append(reasons, p.Name()+": "+reason)
+			continue
+		} else if err != nil {
+			results = append(results, ProviderResult{Provider: p.Name(), Reason: err.Error()})
+			return results, fmt.Errorf("credentials provider `%s`: %w", p.Name(), err)
+		}
+		results = append(results, ProviderResult{Provider: p.Name()})
+		return results, nil
+	}
+	return results, fmt.Errorf("%w (%s)", ErrNoCredentials, strings.Join(reasons, "; "))
+}
+
+// NewClient returns the client from the first provider which is not skipped.
+func (pc ProviderChain) NewClient(ctx context.Context) (*http.Client, error) {
+	clt, _, err := pc.Resolve(ctx)
+	return clt, err
+}
+
+// Token returns the access token from the first provider which is not skipped.
+func (pc ProviderChain) Token(ctx context.Context) (*oauth2.Token, error) {
+	tok, _, err := pc.ResolveToken(ctx)
+	return tok, err
+}
+
+// TokenProvider uses an explicit access token, e.g. from the `--token` flag.
+type TokenProvider struct {
+	AccessToken string
+	TokenType   string // defaults to `Bearer`
+}
+
+func (p TokenProvider) Name() string { return ProviderNameToken }
+
+func (p TokenProvider) NewClient(ctx context.Context) (*http.Client, error) {
+	if tok, err := p.Token(ctx); err != nil {
+		return nil, err
+	} else {
+		return authutil.NewClientToken(tok.TokenType, tok.AccessToken, false), nil
+	}
+}
+
+func (p TokenProvider) Token(ctx context.Context) (*oauth2.Token, error) {
+	if strings.TrimSpace(p.AccessToken) == "" {
+		return nil, skipf("no token set")
+	}
+	tokenType := authutil.TokenBearer
+	if strings.TrimSpace(p.TokenType) != "" {
+		tokenType = p.TokenType
+	}
+	return &oauth2.Token{AccessToken: strings.TrimSpace(p.AccessToken), TokenType: tokenType}, nil
+}
+
+// EnvProvider uses credentials from environment variables read by `NewCredentialsEnv()`.
+type EnvProvider struct {
+	Prefix string
+}
+
+func (p EnvProvider) Name() string { return ProviderNameEnv }
+
+func (p EnvProvider) NewClient(ctx context.Context) (*http.Client, error) {
+	if creds, err := p.credentials(); err != nil {
+		return nil, err
+	} else {
+		return creds.NewClient(ctx)
+	}
+}
+
+func (p EnvProvider) Token(ctx context.Context) (*oauth2.Token, error) {
+	if creds, err := p.credentials(); err != nil {
+		return nil, err
+	} else if tok := creds.currentToken(); tok != nil {
+		return tok, nil
+	} else if _, ok := creds.oauth2Credentials(); !ok {
+		return nil, skipf("credentials type `%s` does not use tokens", creds.Type)
+	} else {
+		return creds.NewToken(ctx)
+	}
+}
+
+func (p EnvProvider) credentials() (Credentials, error) {
+	creds, ok := NewCredentialsEnv(p.Prefix)
+	if !ok {
+		return creds, skipf("none of `%s`, `%s` or `%s` set",
+			p.Prefix+EnvSuffixToken, p.Prefix+EnvSuffixType, p.Prefix+EnvSuffixClientID)
+	} else if err := creds.Inflate(); err != nil {
+		return creds, err
+	} else if creds.RequiresInteraction() {
+		return creds, skipf("authorization code grant requires the interactive flow")
+	}
+	return creds, nil
+}
+
+// CachedTokenProvider uses an unexpired token from a token file, or the token cached for a
+// `CredentialsSet` file account, refreshing it when expired if it has a refresh token.
+type CachedTokenProvider struct {
+	Filepath string
+	FileProvider
+}
+
+func (p CachedTokenProvider) Name() string { return ProviderNameCache }
+
+func (p CachedTokenProvider) NewClient(ctx context.Context) (*http.Client, error) {
+	if strings.TrimSpace(p.Filepath) != "" {
+		if tok, err := p.tokenFile(); err != nil {
+			return nil, err
+		} else {
+			return authutil.NewClientToken(authutil.TokenBearer, tok.AccessToken, false), nil
+		}
+	} else if ctx, ts, err := p.tokenSource(ctx); err != nil {
+		return nil, err
+	} else {
+		return oauth2.NewClient(ctx, ts), nil
+	}
+}
+
+func (p CachedTokenProvider) Token(ctx context.Context) (*oauth2.Token, error) {
+	if strings.TrimSpace(p.Filepath) != "" {
+		return p.tokenFile()
+	} else if _, ts, err := p.tokenSource(ctx); err != nil {
+		return nil, err
+	} else {
+		return ts.Token()
+	}
+}
+
+func (p CachedTokenProvider) tokenFile() (*oauth2.Token, error) {
+	if _, err := os.Stat(p.Filepath); errors.Is(err, os.ErrNotExist) {
+		return nil, skipf("token file not found (%s)", p.Filepath)
+	}
+	tok, err := authutil.ReadTokenFile(p.Filepath)
+	if err != nil {
+		return nil, err
+	} else if !tok.Valid() {
+		return nil, skipf("cached token expired (%s)", p.Filepath)
+	}
+	return tok, nil
+}
+
+// tokenSource returns a caching token source for the account, refreshing the cached token
+// if expired, along with the context for token requests.
+func (p CachedTokenProvider) tokenSource(ctx context.Context) (context.Context, oauth2.TokenSource, error) {
+	if p.Cache == nil {
+		return ctx, nil, skipf("token cache disabled")
+	}
+	creds, err := p.credentials()
+	if err != nil {
+		return ctx, nil, err
+	}
+	oc, ok := creds.oauth2Credentials()
+	if !ok {
+		return ctx, nil, skipf("credentials type `%s` does not use cached tokens", creds.Type)
+	}
+	key := p.cacheKey(oc.Scopes)
+	tok, err := p.Cache.Get(key)
+	if err != nil {
+		return ctx, nil, err
+	} else if tok == nil {
+		return ctx, nil, skipf("no cached token for account `%s`", p.Account)
+	} else if !tok.Valid() && tok.RefreshToken == "" {
+		return ctx, nil, skipf("cached token for account `%s` expired", p.Account)
+	}
+	ctx, err = creds.tokenContext(ctx)
+	if err != nil {
+		return ctx, nil, err
+	}
+	cfg := oc.Config()
+	ts := p.Cache.TokenSource(key, cfg.TokenSource(ctx, tok))
+	if _, err := ts.Token(); err != nil {
+		return ctx, nil, skipf("cached token for account `%s` could not be refreshed: %s", p.Account, err.Error())
+	}
+	return ctx, ts, nil
+}
+
+// FileProvider uses an account in a `CredentialsSet` file without user interaction. If
+// `Cache` is set, tokens retrieved for the account are cached.
+type FileProvider struct {
+	Filename string
+	Account  string
+	Cache    *authutil.TokenCache
+}
+
+func (p FileProvider) Name() string { return ProviderNameFile }
+
+func (p FileProvider) NewClient(ctx context.Context) (*http.Client, error) {
+	if creds, err := p.credentialsNonInteractive(); err != nil {
+		return nil, err
+	} else {
+		hadToken := creds.currentToken() != nil
+		clt, err := creds.NewClient(ctx)
+		if err != nil || hadToken {
+			return clt, err
+		}
+		return clt, p.cacheToken(&creds)
+	}
+}
+
+func (p FileProvider) Token(ctx context.Context) (*oauth2.Token, error) {
+	creds, err := p.credentialsNonInteractive()
+	if err != nil {
+		return nil, err
+	} else if tok := creds.currentToken(); tok != nil {
+		return tok, nil
+	} else if _, ok := creds.oauth2Credentials(); !ok {
+		return nil, fmt.Errorf("credentials type `%s` does not use tokens", creds.Type)
+	}
+	tok, err := creds.NewToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	creds.Token = tok
+	return tok, p.cacheToken(&creds)
+}
+
+func (p FileProvider) credentials() (Credentials, error) {
+	if strings.TrimSpace(p.Filename) == "" {
+		return Credentials{}, skipf("no credentials file set")
+	} else if strings.TrimSpace(p.Account) == "" {
+		return Credentials{}, skipf("no account set")
+	}
+	return NewCredentialsFromSetFile(p.Filename, p.Account, true)
+}
+
+func (p FileProvider) credentialsNonInteractive() (Credentials, error) {
+	if creds, err := p.credentials(); err != nil {
+		return creds, err
+	} else if creds.RequiresInteraction() {
+		return creds, skipf("account `%s` uses authorization code grant which requires the interactive flow", p.Account)
+	} else {
+		return creds, nil
+	}
+}
+
+func (p FileProvider) cacheKey(scopes []string) string {
+	return authutil.TokenCacheKey(p.Filename, p.Account, scopes)
+}
+
+// cacheToken caches the token retrieved for `creds`, if any.
+func (p FileProvider) cacheToken(creds *Credentials) error {
+	if p.Cache == nil {
+		return nil
+	} else if oc, ok := creds.oauth2Credentials(); !ok {
+		return nil
+	} else if tok := creds.currentToken(); tok == nil {
+		return nil
+	} else {
+		return p.Cache.Put(p.cacheKey(oc.Scopes), tok)
+	}
+}
+
+// CacheKey returns the token cache key for the account.
+func (p FileProvider) CacheKey() (string, error) {
+	if creds, err := p.credentials(); err != nil {
+		return "", err
+	} else {
+		return creds.TokenCacheKey(p.Filename, p.Account)
+	}
+}
+
+// TokenCacheKey returns the token cache key for the credentials of `account` in the
+// credentials set file `filename`, as used by `FileProvider`.
+func (creds *Credentials) TokenCacheKey(filename, account string) (string, error) {
+	if oc, ok := creds.oauth2Credentials(); !ok {
+		return "", fmt.Errorf("credentials type `%s` does not use cached tokens", creds.Type)
+	} else {
+		return authutil.TokenCacheKey(filename, account, oc.Scopes), nil
+	}
+}
+
+// Refresh gets a new token for the account and caches it, ignoring any unexpired cached
+// token. The cached refresh token is used if available, otherwise the account's grant is
+// run, using the interactive flow with `state` for the authorization code grant.
+func (p FileProvider) Refresh(ctx context.Context, state string) (*oauth2.Token, error) {
+	creds, err := p.credentials()
+	if err != nil {
+		return nil, err
+	}
+	oc, ok := creds.oauth2Credentials()
+	if !ok {
+		return nil, fmt.Errorf("credentials type `%s` does not use tokens", creds.Type)
+	}
+	if p.Cache != nil {
+		if cached, err := p.Cache.Get(p.cacheKey(oc.Scopes)); err != nil {
+			return nil, err
+		} else if cached != nil && cached.RefreshToken != "" {
+			tctx, err := creds.tokenContext(ctx)
+			if err != nil {
+				return nil, err
+			}
+			cfg := oc.Config()
+			if tok, err := cfg.TokenSource(tctx, &oauth2.Token{RefreshToken: cached.RefreshToken}).Token(); err == nil {
+				return tok, p.Cache.Put(p.cacheKey(oc.Scopes), tok)
+			}
+		}
+	}
+	creds.Token = nil
+	if creds.OAuth2 != nil {
+		creds.OAuth2.Token = nil
+	}
+	if creds.GoogleOAuth2 != nil {
+		creds.GoogleOAuth2.Token = nil
+	}
+	var tok *oauth2.Token
+	if creds.RequiresInteraction() {
+		tok, err = creds.NewTokenCLI(ctx, state)
+	} else {
+		tok, err = creds.NewToken(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	creds.Token = tok
+	return tok, p.cacheToken(&creds)
+}
+
+// RenewToken returns a new token without user interaction, using the refresh token of `tok`
+// if set and the credentials' grant otherwise. A static token set on the credentials is
+// returned as is. The authorization code grant requires a refresh token.
+func (creds *Credentials) RenewToken(ctx context.Context, tok *oauth2.Token) (*oauth2.Token, error) {
+	oc, ok := creds.oauth2Credentials()
+	if !ok {
+		return nil, fmt.Errorf("credentials type `%s` does not use tokens", creds.Type)
+	}
+	if tok != nil && tok.RefreshToken != "" {
+		tctx, err := creds.tokenContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cfg := oc.Config()
+		newTok, err := cfg.TokenSource(tctx, &oauth2.Token{RefreshToken: tok.RefreshToken}).Token()
+		if err == nil || oc.IsGrantType(authutil.GrantTypeAuthorizationCode) {
+			return newTok, err
+		}
+	}
+	if cur := creds.currentToken(); cur != nil {
+		return cur, nil
+	} else if creds.RequiresInteraction() {
+		return nil, errors.New("authorization code grant requires the interactive flow or a refresh token")
+	}
+	return creds.NewToken(ctx)
+}
+
+// InteractiveProvider runs the interactive token flow for an account in a `CredentialsSet`
+// file, e.g. the authorization code grant via the browser.
+type InteractiveProvider struct {
+	FileProvider
+	State   string
+	Enabled bool
+}
+
+func (p InteractiveProvider) Name() string { return ProviderNameInteractive }
+
+func (p InteractiveProvider) NewClient(ctx context.Context) (*http.Client, error) {
+	if tok, err := p.Token(ctx); err != nil {
+		return nil, err
+	} else {
+		return authutil.NewClientToken(authutil.TokenBearer, tok.AccessToken, false), nil
+	}
+}
+
+func (p InteractiveProvider) Token(ctx context.Context) (*oauth2.Token, error) {
+	if !p.Enabled {
+		return nil, skipf("interactive flow not enabled")
+	}
+	creds, err := p.credentials()
+	if err != nil {
+		return nil, err
+	} else if _, ok := creds.oauth2Credentials(); !ok {
+		return nil, skipf("credentials type `%s` has no interactive flow", creds.Type)
+	}
+	tok, err := creds.NewTokenCLI(ctx, p.State)
+	if err != nil {
+		return nil, err
+	}
+	creds.Token = tok
+	return tok, p.cacheToken(&creds)
+}
+
+// oauth2Credentials returns the OAuth 2.0 credentials for `oauth2` and `googleoauth2` types.
+func (creds *Credentials) oauth2Credentials() (CredentialsOAuth2, bool) {
+	switch {
+	case creds.Type == TypeGoogleOAuth2 && creds.GoogleOAuth2 != nil:
+		oc := creds.GoogleOAuth2.CredentialsOAuth2()
+		oc.Token = creds.GoogleOAuth2.Token
+		return oc, true
+	case creds.OAuth2 != nil:
+		return *creds.OAuth2, true
+	default:
+		return CredentialsOAuth2{}, false
+	}
+}
+
+// currentToken returns the token set on the credentials, if any.
+func (creds *Credentials) currentToken() *oauth2.Token {
+	if creds.Token != nil && strings.TrimSpace(creds.Token.AccessToken) != "" {
+		return creds.Token
+	} else if oc, ok := creds.oauth2Credentials(); ok && oc.Token != nil &&
+		strings.TrimSpace(oc.Token.AccessToken) != "" {
+		return oc.Token
+	}
+	return nil
+}
+
+// RequiresInteraction returns true if a token can only be obtained with the authorization
+// code flow because the credentials have no access token.
+func (creds *Credentials) RequiresInteraction() bool {
+	oc, ok := creds.oauth2Credentials()
+	return ok && creds.currentToken() == nil &&
+		oc.IsGrantType(authutil.GrantTypeAuthorizationCode)
+}
+
+// NewCredentialsEnv reads credentials from environment variables using the naming scheme
+// written by `Credentials.EnvVars()`. `<PREFIX>TOKEN` alone is used as a bearer token. The
+// type defaults to `oauth2`. Header names are converted to canonical form and query
+// parameter names to lower case. The bool is false if no credentials variables are set.
+func NewCredentialsEnv(envPrefix string) (Credentials, bool) {
+	getenv := func(suffix string) string { return strings.TrimSpace(os.Getenv(envPrefix + suffix)) }
+	creds := Credentials{
+		Type:    getenv(EnvSuffixType),
+		Service: getenv(EnvSuffixService)}
+	if tok := getenv(EnvSuffixToken); tok != "" {
+		creds.Token = &oauth2.Token{AccessToken: tok, TokenType: authutil.TokenBearer}
+	}
+	if creds.Type == "" {
+		if creds.Token == nil && getenv(EnvSuffixClientID) == "" {
+			return creds, false
+		}
+		creds.Type = TypeOAuth2
+	}
+	switch creds.Type {
+	case TypeBasic:
+		creds.Basic = &CredentialsBasicAuth{
+			ServerURL: getenv(EnvSuffixServerURL),
+			Username:  getenv(EnvSuffixUsername),
+			Password:  os.Getenv(envPrefix + EnvSuffixPassword),
+			Encoded:   getenv(EnvSuffixEncoded)}
+	case TypeHeaderQuery:
+		creds.HeaderQuery = &CredentialsHeaderQuery{
+			ServerURL: getenv(EnvSuffixServerURL),
+			Header:    http.Header{},
+			Query:     url.Values{}}
+		for _, kv := range os.Environ() {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok || !strings.HasPrefix(k, envPrefix) {
+				continue
+			}
+			if name, ok := strings.CutPrefix(k, envPrefix+EnvSuffixHeaderPrefix); ok && name != "" {
+				creds.HeaderQuery.Header.Set(envHeaderName(name), v)
+			} else if name, ok := strings.CutPrefix(k, envPrefix+EnvSuffixQueryPrefix); ok && name != "" {
+				creds.HeaderQuery.Query.Set(envQueryName(name), v)
+			}
+		}
+	case TypeOAuth2:
+		oc := NewCredentialsOAuth2Env(envPrefix)
+		creds.OAuth2 = &oc
+	}
+	return creds, true
+}
+
+// envHeaderName returns the header name for a `<PREFIX>HEADER_` variable name suffix.
+func envHeaderName(suffix string) string {
+	return textproto.CanonicalMIMEHeaderKey(strings.ReplaceAll(suffix, "_", "-"))
+}
+
+// envQueryName returns the query parameter name for a `<PREFIX>QUERY_` variable name suffix.
+func envQueryName(suffix string) string {
+	return strings.ToLower(suffix)
+}
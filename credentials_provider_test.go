@@ -0,0 +1,118 @@
+package goauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grokify/goauth/authutil"
+	"golang.org/x/oauth2"
+)
+
+var providerChainTests = []struct {
+	token      string
+	envToken   string
+	cacheToken string
+	account    string
+	wantUsed   string
+	wantErr    error
+}{
+	{"flag", "env", "cache", "file", ProviderNameToken, nil},
+	{"", "env", "cache", "file", ProviderNameEnv, nil},
+	{"", "", "cache", "file", ProviderNameCache, nil},
+	{"", "", "", "file", ProviderNameFile, nil},
+	{"", "", "", "authcode", "", ErrNoCredentials},
+}
+
+func TestProviderChain(t *testing.T) {
+	dir := t.TempDir()
+	credsFile := filepath.Join(dir, "creds.json")
+	if err := os.WriteFile(credsFile, []byte(`{"credentials":{
+		"file":{"type":"headerquery","headerquery":{"header":{"X-Api-Key":["abc"]}}},
+		"authcode":{"type":"oauth2","oauth2":{"grantType":"authorization_code"}}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range providerChainTests {
+		t.Setenv(EnvPrefixDefault+EnvSuffixToken, tt.envToken)
+		tokenFile := ""
+		if tt.cacheToken != "" {
+			tokenFile = filepath.Join(dir, "token.json")
+			if err := authutil.WriteTokenFile(tokenFile, &oauth2.Token{
+				AccessToken: tt.cacheToken,
+				Expiry:      time.Now().Add(time.Hour)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		opts := Options{CredsPath: credsFile, Account: tt.account, Token: tt.token, TokenFile: tokenFile, NoCache: true}
+		chain := opts.ProviderChain("")
+		chain[len(chain)-1] = InteractiveProvider{} // never prompt in tests
+		_, results, err := chain.Resolve(context.Background())
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ProviderChain.Resolve(%s): want err [%v], got [%v]", tt.account, tt.wantErr, err)
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("ProviderChain.Resolve(%s): err [%v]", tt.account, err)
+		}
+		if used := results.Used(); used != tt.wantUsed {
+			t.Errorf("ProviderChain.Resolve(%s): want provider [%s], got [%s]", tt.account, tt.wantUsed, used)
+		}
+		for _, res := range results[:len(results)-1] {
+			if !res.Skipped || res.Reason == "" {
+				t.Errorf("ProviderChain.Resolve(%s): want skip reason for [%s], got [%s]", tt.account, res.Provider, res.String())
+			}
+		}
+	}
+}
+
+func TestProviderChainInteractive(t *testing.T) {
+	for _, cli := range []bool{false, true} {
+		opts := Options{}
+		if cli {
+			opts.CLI = []bool{true}
+		}
+		chain := opts.ProviderChain("")
+		if p, ok := chain[len(chain)-1].(InteractiveProvider); !ok || p.Enabled != cli {
+			t.Errorf("Options.ProviderChain(cli=%v): want interactive enabled [%v], got [%v]", cli, cli, p.Enabled)
+		}
+	}
+}
+
+func TestProviderChainTokenCache(t *testing.T) {
+	requests := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"abc","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer svr.Close()
+
+	dir := t.TempDir()
+	credsFile := filepath.Join(dir, "creds.json")
+	if err := os.WriteFile(credsFile, []byte(`{"credentials":{"cc":{"type":"oauth2","oauth2":{
+		"clientID":"id","clientSecret":"secret","grantType":"client_credentials",
+		"endpoint":{"tokenURL":"`+svr.URL+`"}}}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	file := FileProvider{Filename: credsFile, Account: "cc", Cache: &authutil.TokenCache{Dir: filepath.Join(dir, "cache")}}
+	chain := ProviderChain{CachedTokenProvider{FileProvider: file}, file}
+
+	for i, wantUsed := range []string{ProviderNameFile, ProviderNameCache} {
+		_, results, err := chain.Resolve(context.Background())
+		if err != nil {
+			t.Fatalf("ProviderChain.Resolve(%d): err [%v]", i, err)
+		} else if used := results.Used(); used != wantUsed {
+			t.Errorf("ProviderChain.Resolve(%d): want provider [%s], got [%s]", i, wantUsed, used)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("ProviderChain.Resolve() token requests: want [%d], got [%d]", 1, requests)
+	}
+}
@@ -0,0 +1,198 @@
+package goauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CredentialsType is a credentials type which can be registered with `RegisterType()`.
+// Settings for a type are stored in the `Credentials` JSON under a property with the type's
+// name, e.g. `{"type":"mytype","mytype":{...}}`.
+type CredentialsType struct {
+	// Name is the `Credentials.Type` value and JSON property name for the type.
+	Name string
+	// Decode parses the type's JSON settings. The result is available as `Credentials.Custom`.
+	// Built-in types decode into their `Credentials` struct fields and leave this nil.
+	Decode func(data json.RawMessage) (any, error)
+	// NewClient returns an `*http.Client` for credentials of this type.
+	NewClient func(ctx context.Context, creds *Credentials) (*http.Client, error)
+	// ServerURL returns the API base URL used by `Credentials.NewSimpleClientHTTP()`. If nil,
+	// simple clients are not supported for the type.
+	ServerURL func(creds *Credentials) (string, error)
+}
+
+var (
+	typesMutex sync.RWMutex
+	types      = map[string]CredentialsType{}
+
+	// reservedTypeNames are `Credentials` JSON properties which cannot be used as type names.
+	reservedTypeNames = []string{"service", "type", "subdomain", "token", "transport", "additional"}
+)
+
+// RegisterType registers a credentials type so it can be used in `Credentials` JSON and
+// with `Credentials.NewClient()`. It is typically called from a package's `init()` function.
+func RegisterType(ct CredentialsType) error {
+	ct.Name = strings.TrimSpace(ct.Name)
+	if ct.Name == "" {
+		return errors.New("credentials type name must not be empty")
+	} else if ct.NewClient == nil {
+		return fmt.Errorf("credentials type `%s` has no client factory", ct.Name)
+	}
+	for _, name := range reservedTypeNames {
+		if ct.Name == name {
+			return fmt.Errorf("credentials type name is reserved (%s)", ct.Name)
+		}
+	}
+	typesMutex.Lock()
+	defer typesMutex.Unlock()
+	if _, ok := types[ct.Name]; ok {
+		return fmt.Errorf("credentials type already registered (%s)", ct.Name)
+	}
+	types[ct.Name] = ct
+	return nil
+}
+
+// MustRegisterType is like `RegisterType()` but panics on error.
+func MustRegisterType(ct CredentialsType) {
+	if err := RegisterType(ct); err != nil {
+		panic(err)
+	}
+}
+
+// LookupType returns the registered credentials type for a name.
+func LookupType(name string) (CredentialsType, bool) {
+	typesMutex.RLock()
+	defer typesMutex.RUnlock()
+	ct, ok := types[name]
+	return ct, ok
+}
+
+// Types returns the sorted names of registered credentials types.
+func Types() []string {
+	typesMutex.RLock()
+	defer typesMutex.RUnlock()
+	var names []string
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	MustRegisterType(CredentialsType{
+		Name: TypeBasic,
+		NewClient: func(ctx context.Context, creds *Credentials) (*http.Client, error) {
+			if creds.Basic == nil {
+				return nil, ErrBasicAuthNotPopulated
+			}
+			return creds.Basic.NewClient()
+		},
+		ServerURL: func(creds *Credentials) (string, error) {
+			if creds.Basic == nil {
+				return "", ErrBasicAuthNotPopulated
+			}
+			return creds.Basic.ServerURL, nil
+		}})
+	MustRegisterType(CredentialsType{
+		Name: TypeGCPSA,
+		NewClient: func(ctx context.Context, creds *Credentials) (*http.Client, error) {
+			if creds.GCPSA == nil {
+				return nil, ErrGCPSANotPopulated
+			}
+			return creds.GCPSA.NewClient(ctx)
+		}})
+	MustRegisterType(CredentialsType{
+		Name: TypeHeaderQuery,
+		NewClient: func(ctx context.Context, creds *Credentials) (*http.Client, error) {
+			if creds.HeaderQuery == nil {
+				return nil, ErrHeaderQueryNotPopulated
+			}
+			return creds.HeaderQuery.NewClient(), nil
+		},
+		ServerURL: func(creds *Credentials) (string, error) {
+			if creds.HeaderQuery == nil {
+				return "", ErrHeaderQueryNotPopulated
+			}
+			return creds.HeaderQuery.ServerURL, nil
+		}})
+	MustRegisterType(CredentialsType{
+		Name: TypeJWT,
+		NewClient: func(ctx context.Context, creds *Credentials) (*http.Client, error) {
+			return nil, ErrJWTNotSupported
+		},
+		ServerURL: func(creds *Credentials) (string, error) {
+			return "", ErrJWTNotSupported
+		}})
+	MustRegisterType(CredentialsType{
+		Name:      TypeOAuth2,
+		NewClient: newClientOAuth2,
+		ServerURL: func(creds *Credentials) (string, error) {
+			if creds.OAuth2 == nil {
+				return "", ErrOAuth2NotPopulated
+			}
+			return creds.OAuth2.ServerURL, nil
+		}})
+	MustRegisterType(CredentialsType{
+		Name:      TypeGoogleOAuth2,
+		NewClient: newClientOAuth2})
+}
+
+// credentialsJSON is used to avoid recursion in `Credentials` JSON methods.
+type credentialsJSON Credentials
+
+// UnmarshalJSON decodes `Credentials` including settings for registered types with a `Decode` function.
+func (creds *Credentials) UnmarshalJSON(data []byte) error {
+	var cj credentialsJSON
+	if err := json.Unmarshal(data, &cj); err != nil {
+		return err
+	}
+	*creds = Credentials(cj)
+	ct, ok := LookupType(creds.Type)
+	if !ok || ct.Decode == nil {
+		return nil
+	}
+	var props map[string]json.RawMessage
+	if err := json.Unmarshal(data, &props); err != nil {
+		return err
+	}
+	if raw, ok := props[creds.Type]; ok {
+		if custom, err := ct.Decode(raw); err != nil {
+			return fmt.Errorf("credentials type `%s`: %w", creds.Type, err)
+		} else {
+			creds.Custom = custom
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes `Credentials` including `Custom` settings under the type's property.
+func (creds Credentials) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(credentialsJSON(creds))
+	if err != nil || creds.Custom == nil || strings.TrimSpace(creds.Type) == "" {
+		return b, err
+	}
+	kb, err := json.Marshal(creds.Type)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := json.Marshal(creds.Custom)
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.TrimSuffix(b, []byte("}"))
+	if len(b) > 1 {
+		b = append(b, ',')
+	}
+	b = append(b, kb...)
+	b = append(b, ':')
+	b = append(b, vb...)
+	return append(b, '}'), nil
+}
@@ -0,0 +1,66 @@
+package goauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+type testSigner struct {
+	KeyID string `json:"keyID"`
+}
+
+func init() {
+	MustRegisterType(CredentialsType{
+		Name: "testsigner",
+		Decode: func(data json.RawMessage) (any, error) {
+			s := &testSigner{}
+			return s, json.Unmarshal(data, s)
+		},
+		NewClient: func(ctx context.Context, creds *Credentials) (*http.Client, error) {
+			return &http.Client{}, nil
+		},
+		ServerURL: func(creds *Credentials) (string, error) {
+			return "https://api.example.com", nil
+		}})
+}
+
+var registryTests = []struct {
+	credsJSON string
+	keyID     string
+}{
+	{`{"type":"testsigner","testsigner":{"keyID":"abc"}}`, "abc"},
+	{`{"service":"example","type":"testsigner","testsigner":{"keyID":"def"},"additional":{"a":["b"]}}`, "def"},
+}
+
+func TestRegisteredTypeJSON(t *testing.T) {
+	for _, tt := range registryTests {
+		var creds Credentials
+		if err := json.Unmarshal([]byte(tt.credsJSON), &creds); err != nil {
+			t.Fatalf("json.Unmarshal(%s): err [%v]", tt.credsJSON, err)
+		}
+		signer, ok := creds.Custom.(*testSigner)
+		if !ok || signer.KeyID != tt.keyID {
+			t.Errorf("json.Unmarshal(%s).Custom: want keyID [%s], got [%v]", tt.credsJSON, tt.keyID, creds.Custom)
+		}
+		b, err := json.Marshal(creds)
+		if err != nil {
+			t.Fatalf("json.Marshal(%s): err [%v]", tt.credsJSON, err)
+		}
+		var creds2 Credentials
+		if err := json.Unmarshal(b, &creds2); err != nil {
+			t.Fatalf("json.Unmarshal(%s): err [%v]", string(b), err)
+		} else if signer2, ok := creds2.Custom.(*testSigner); !ok || signer2.KeyID != tt.keyID {
+			t.Errorf("round trip (%s): want keyID [%s], got [%s]", tt.credsJSON, tt.keyID, string(b))
+		}
+		if clt, err := creds.NewSimpleClient(context.Background()); err != nil {
+			t.Errorf("NewSimpleClient(%s): err [%v]", tt.credsJSON, err)
+		} else if clt.BaseURL != "https://api.example.com" {
+			t.Errorf("NewSimpleClient(%s).BaseURL: want [%s], got [%s]", tt.credsJSON, "https://api.example.com", clt.BaseURL)
+		}
+	}
+	if err := RegisterType(CredentialsType{Name: TypeOAuth2, NewClient: newClientOAuth2}); err == nil {
+		t.Errorf("RegisterType(%s): want duplicate error, got nil", TypeOAuth2)
+	}
+}
@@ -37,7 +37,7 @@ func (set *CredentialsSet) Get(key string) (Credentials, error) {
 	if creds, ok := set.Credentials[key]; ok {
 		return creds, nil
 	}
-	return Credentials{}, fmt.Errorf("credentials key not found (%s)", key)
+	return Credentials{}, fmt.Errorf("%w (%s)", ErrAccountNotFound, key)
 }
 
 func (set *CredentialsSet) Inflate() error {
@@ -0,0 +1,179 @@
+package goauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/grokify/goauth/authutil"
+)
+
+const credentialsSetKey = "credentials"
+
+// WriteFileMerge writes the set's accounts to an existing credentials set file, replacing
+// accounts with the same key and appending new ones. The rest of the file, including other
+// accounts, other properties, key order and formatting, is left byte-for-byte unchanged.
+// If the file does not exist or is empty, it is written with `WriteFile()`.
+func (set *CredentialsSet) WriteFileMerge(filename, prefix, indent string, perm fs.FileMode) error {
+	b, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && len(bytes.TrimSpace(b)) == 0) {
+		return set.WriteFile(filename, prefix, indent, perm)
+	} else if err != nil {
+		return err
+	}
+	if filePrefix, fileIndent, ok := detectJSONIndent(b); ok {
+		prefix, indent = filePrefix, fileIndent
+	} else {
+		prefix, indent = "", ""
+	}
+	for _, key := range set.Keys() {
+		if b, err = mergeJSONAccount(b, key, set.Credentials[key], prefix, indent); err != nil {
+			return err
+		}
+	}
+	return authutil.WriteFileAtomic(filename, b, perm)
+}
+
+// jsonObjectSpans describes the byte offsets of a JSON object's member values and closing brace.
+type jsonObjectSpans struct {
+	members map[string][2]int
+	close   int
+}
+
+// mergeJSONAccount replaces or inserts a single account in a credentials set document.
+func mergeJSONAccount(b []byte, key string, creds Credentials, prefix, indent string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	top, accts, err := scanCredentialsSet(dec)
+	if err != nil {
+		return nil, err
+	}
+	if accts == nil {
+		val, err := marshalJSONValue(map[string]Credentials{key: creds}, prefix+indent, indent)
+		if err != nil {
+			return nil, err
+		}
+		return insertJSONMember(b, top, credentialsSetKey, val, prefix, indent, 1), nil
+	}
+	val, err := marshalJSONValue(creds, prefix+strings.Repeat(indent, 2), indent)
+	if err != nil {
+		return nil, err
+	}
+	if span, ok := accts.members[key]; ok {
+		return slices.Concat(b[:span[0]], val, b[span[1]:]), nil
+	}
+	return insertJSONMember(b, *accts, key, val, prefix, indent, 2), nil
+}
+
+// scanCredentialsSet returns the spans of the top-level object and the `credentials` object,
+// which is nil if not present.
+func scanCredentialsSet(dec *json.Decoder) (jsonObjectSpans, *jsonObjectSpans, error) {
+	var accts *jsonObjectSpans
+	top, err := scanJSONObject(dec, func(key string) (bool, error) {
+		if key != credentialsSetKey {
+			return false, nil
+		}
+		spans, err := scanJSONObject(dec, nil)
+		if err != nil {
+			return true, fmt.Errorf("property `%s`: %w", credentialsSetKey, err)
+		}
+		accts = &spans
+		return true, nil
+	})
+	return top, accts, err
+}
+
+// scanJSONObject reads an object from the decoder. `descend`, if provided, is called for each
+// key and can consume the value itself, in which case it returns true.
+func scanJSONObject(dec *json.Decoder, descend func(key string) (bool, error)) (jsonObjectSpans, error) {
+	spans := jsonObjectSpans{members: map[string][2]int{}}
+	if tok, err := dec.Token(); err != nil {
+		return spans, err
+	} else if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return spans, errors.New("json is not an object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return spans, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return spans, errors.New("json object key is not a string")
+		}
+		if descend != nil {
+			if done, err := descend(key); err != nil {
+				return spans, err
+			} else if done {
+				continue
+			}
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return spans, err
+		}
+		end := int(dec.InputOffset())
+		spans.members[key] = [2]int{end - len(raw), end}
+	}
+	if _, err := dec.Token(); err != nil {
+		return spans, err
+	}
+	spans.close = int(dec.InputOffset()) - 1
+	return spans, nil
+}
+
+// insertJSONMember inserts a member before the closing brace of an object at `depth`.
+func insertJSONMember(b []byte, obj jsonObjectSpans, key string, val []byte, prefix, indent string, depth int) []byte {
+	head := bytes.TrimRight(b[:obj.close], " \t\r\n")
+	kb, _ := json.Marshal(key)
+	var mem []byte
+	if len(obj.members) > 0 {
+		mem = append(mem, ',')
+	}
+	if indent == "" {
+		mem = append(mem, kb...)
+		mem = append(mem, ':')
+		mem = append(mem, val...)
+	} else {
+		mem = append(mem, "\n"+prefix+strings.Repeat(indent, depth)...)
+		mem = append(mem, kb...)
+		mem = append(mem, ": "...)
+		mem = append(mem, val...)
+		mem = append(mem, "\n"+prefix+strings.Repeat(indent, depth-1)...)
+	}
+	return slices.Concat(head, mem, b[obj.close:])
+}
+
+func marshalJSONValue(v any, prefix, indent string) ([]byte, error) {
+	if indent == "" {
+		return json.Marshal(v)
+	}
+	return json.MarshalIndent(v, prefix, indent)
+}
+
+// detectJSONIndent returns the prefix and indent of a multi-line JSON document, using the
+// leading whitespace of the first and second lines.
+func detectJSONIndent(b []byte) (string, string, bool) {
+	lines := strings.Split(strings.TrimRight(string(b), "\r\n"), "\n")
+	if len(lines) < 2 {
+		return "", "", false
+	}
+	leading := func(s string) string {
+		return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+	}
+	prefix := leading(lines[0])
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if ws := leading(line); len(ws) > len(prefix) && strings.HasPrefix(ws, prefix) {
+			return prefix, ws[len(prefix):], true
+		}
+		break
+	}
+	return prefix, "  ", true
+}
@@ -0,0 +1,100 @@
+package goauth
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var mergeJSONAccountTests = []struct {
+	name string
+	in   string
+	key  string
+	want string
+}{
+	{"replace indented", "{\n  \"credentials\": {\n    \"a\": {\"type\": \"old\"},\n    \"b\": {\"type\": \"basic\"}\n  }\n}\n", "a",
+		"{\n  \"credentials\": {\n    \"a\": {\n      \"type\": \"jwt\"\n    },\n    \"b\": {\"type\": \"basic\"}\n  }\n}\n"},
+	{"insert indented", "{\n  \"credentials\": {\n    \"b\": {\"type\": \"basic\"}\n  }\n}\n", "a",
+		"{\n  \"credentials\": {\n    \"b\": {\"type\": \"basic\"},\n    \"a\": {\n      \"type\": \"jwt\"\n    }\n  }\n}\n"},
+	{"insert empty", "{\n  \"credentials\": {}\n}\n", "a",
+		"{\n  \"credentials\": {\n    \"a\": {\n      \"type\": \"jwt\"\n    }\n  }\n}\n"},
+	{"missing credentials", "{\n  \"other\": 1\n}\n", "a",
+		"{\n  \"other\": 1,\n  \"credentials\": {\n    \"a\": {\n      \"type\": \"jwt\"\n    }\n  }\n}\n"},
+	{"compact replace", `{"credentials":{"a":{"type":"old"},"b":{}},"x":[1]}`, "a",
+		`{"credentials":{"a":{"type":"jwt"},"b":{}},"x":[1]}`},
+	{"compact insert", `{"x":true,"credentials":{"b":{}}}`, "a",
+		`{"x":true,"credentials":{"b":{},"a":{"type":"jwt"}}}`},
+	{"compact missing credentials", `{"x":true}`, "a",
+		`{"x":true,"credentials":{"a":{"type":"jwt"}}}`},
+	{"tab indented", "{\n\t\"credentials\": {\n\t\t\"b\": {}\n\t}\n}", "a",
+		"{\n\t\"credentials\": {\n\t\t\"b\": {},\n\t\t\"a\": {\n\t\t\t\"type\": \"jwt\"\n\t\t}\n\t}\n}"},
+}
+
+func TestMergeJSONAccount(t *testing.T) {
+	for _, tt := range mergeJSONAccountTests {
+		prefix, indent, ok := detectJSONIndent([]byte(tt.in))
+		if !ok {
+			prefix, indent = "", ""
+		}
+		got, err := mergeJSONAccount([]byte(tt.in), tt.key, Credentials{Type: TypeJWT}, prefix, indent)
+		if err != nil {
+			t.Errorf("mergeJSONAccount(%s): err [%v]", tt.name, err)
+		} else if string(got) != tt.want {
+			t.Errorf("mergeJSONAccount(%s): want [%s], got [%s]", tt.name, tt.want, got)
+		} else if !json.Valid(got) {
+			t.Errorf("mergeJSONAccount(%s): want valid JSON, got [%s]", tt.name, got)
+		}
+	}
+	for _, in := range []string{`[]`, `{"credentials":[]}`, `{"credentials":`} {
+		if _, err := mergeJSONAccount([]byte(in), "a", Credentials{}, "", ""); err == nil {
+			t.Errorf("mergeJSONAccount(%s): want error, got nil", in)
+		}
+	}
+}
+
+var detectJSONIndentTests = []struct {
+	in     string
+	prefix string
+	indent string
+	ok     bool
+}{
+	{`{"a":1}`, "", "", false},
+	{"{\n  \"a\": 1\n}", "", "  ", true},
+	{"{\n\t\"a\": 1\n}", "", "\t", true},
+	{"  {\n      \"a\": 1\n  }", "  ", "    ", true},
+	{"{\n\n    \"a\": 1\n}", "", "    ", true},
+	{"{\n}", "", "  ", true},
+}
+
+func TestDetectJSONIndent(t *testing.T) {
+	for _, tt := range detectJSONIndentTests {
+		prefix, indent, ok := detectJSONIndent([]byte(tt.in))
+		if prefix != tt.prefix || indent != tt.indent || ok != tt.ok {
+			t.Errorf("detectJSONIndent(%q): want [%q %q %v], got [%q %q %v]", tt.in, tt.prefix, tt.indent, tt.ok, prefix, indent, ok)
+		}
+	}
+}
+
+func TestWriteFileMerge(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "creds.json")
+	in := "{\n  \"note\": \"keep\",\n  \"credentials\": {\n    \"b\": {\"type\": \"basic\"}\n  }\n}\n"
+	if err := os.WriteFile(filename, []byte(in), 0600); err != nil {
+		t.Fatalf("os.WriteFile(): err [%v]", err)
+	}
+	set := CredentialsSet{Credentials: map[string]Credentials{"a": {Type: TypeJWT}}}
+	if err := set.WriteFileMerge(filename, "", "  ", 0600); err != nil {
+		t.Fatalf("CredentialsSet.WriteFileMerge(): err [%v]", err)
+	}
+	got, err := ReadFileCredentialsSet(filename, false)
+	if err != nil {
+		t.Fatalf("ReadFileCredentialsSet(): err [%v]", err)
+	} else if len(got.Credentials) != 2 || got.Credentials["a"].Type != TypeJWT || got.Credentials["b"].Type != TypeBasic {
+		t.Errorf("CredentialsSet.WriteFileMerge(): want accounts [a b], got [%v]", got.Credentials)
+	}
+	if fi, err := os.Stat(filename); err != nil {
+		t.Errorf("os.Stat(): err [%v]", err)
+	} else if fi.Mode().Perm() != 0600 {
+		t.Errorf("CredentialsSet.WriteFileMerge(): want mode [0600], got [%v]", fi.Mode().Perm())
+	}
+}
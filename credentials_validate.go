@@ -0,0 +1,214 @@
+package goauth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/grokify/goauth/authutil"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrRequired is wrapped by `Credentials.Validate()` errors for missing settings.
+	ErrRequired = errors.New("required")
+	// ErrInvalidTransport is wrapped by `Credentials.Validate()` errors for `Transport`
+	// settings which do not parse.
+	ErrInvalidTransport = errors.New("invalid transport config")
+)
+
+// Validate checks that the settings required by the credentials type and grant type are
+// present and that `Transport` settings parse. All problems found are returned joined with
+// `errors.Join()`.
+func (creds *Credentials) Validate() error {
+	var errs []error
+	missing := func(field string) {
+		errs = append(errs, fmt.Errorf("%s is %w for type `%s`", field, ErrRequired, creds.Type))
+	}
+	switch creds.Type {
+	case "":
+		errs = append(errs, fmt.Errorf("type is %w", ErrRequired))
+	case TypeBasic:
+		if creds.Basic == nil {
+			errs = append(errs, ErrBasicAuthNotPopulated)
+		} else if creds.Basic.Encoded == "" && creds.Basic.Username == "" {
+			missing("basic.username or basic.encoded")
+		}
+	case TypeHeaderQuery:
+		if creds.HeaderQuery == nil {
+			errs = append(errs, ErrHeaderQueryNotPopulated)
+		} else if len(creds.HeaderQuery.Header) == 0 && len(creds.HeaderQuery.Query) == 0 {
+			missing("headerquery.header or headerquery.query")
+		}
+	case TypeJWT:
+		if creds.JWT == nil {
+			errs = append(errs, ErrJWTNotPopulated)
+		} else {
+			if creds.JWT.PrivateKey == "" {
+				missing("jwt.privateKey")
+			}
+			if jwt.GetSigningMethod(strings.ToUpper(strings.TrimSpace(creds.JWT.SigningMethod))) == nil {
+				errs = append(errs, fmt.Errorf("jwt.signingMethod not supported (%s)", creds.JWT.SigningMethod))
+			}
+		}
+	case TypeGCPSA:
+		if creds.GCPSA == nil {
+			errs = append(errs, ErrGCPSANotPopulated)
+		} else if creds.GCPSA.GCPCredentials.PrivateKey == "" {
+			missing("gcpsa.gcpCredentials.private_key")
+		}
+	case TypeGoogleOAuth2:
+		if creds.GoogleOAuth2 == nil {
+			errs = append(errs, fmt.Errorf("credentials.%s is nil for type `%s`", TypeGoogleOAuth2, TypeGoogleOAuth2))
+		} else if creds.GoogleOAuth2.GoogleWebCredentials.ClientID == "" {
+			missing("googleoauth2.web.client_id")
+		}
+	case TypeOAuth2:
+		if creds.OAuth2 == nil {
+			errs = append(errs, ErrOAuth2NotPopulated)
+		} else if creds.currentToken() == nil {
+			errs = append(errs, creds.OAuth2.validate()...)
+		}
+	default:
+		if _, ok := LookupType(creds.Type); !ok {
+			errs = append(errs, fmt.Errorf("%w (%s)", ErrTypeNotSupported, creds.Type))
+		}
+	}
+	if creds.Transport != nil {
+		if _, err := creds.Transport.NewHTTPTransport(); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %w", ErrInvalidTransport, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validate checks the settings required by the grant type.
+func (oc *CredentialsOAuth2) validate() []error {
+	var errs []error
+	missing := func(field string) {
+		errs = append(errs, fmt.Errorf("oauth2.%s is %w for grant type `%s`", field, ErrRequired, oc.GrantType))
+	}
+	if oc.Endpoint.TokenURL == "" {
+		missing("endpoint.tokenURL")
+	}
+	switch {
+	case oc.GrantType == "":
+		errs = append(errs, fmt.Errorf("oauth2.grantType is %w", ErrRequired))
+	case oc.IsGrantType(authutil.GrantTypeAuthorizationCode):
+		if oc.ClientID == "" {
+			missing("clientID")
+		}
+		if oc.Endpoint.AuthURL == "" {
+			missing("endpoint.authURL")
+		}
+	case oc.IsGrantType(authutil.GrantTypeClientCredentials), oc.IsGrantType(authutil.GrantTypeAccountCredentials):
+		if oc.ClientID == "" {
+			missing("clientID")
+		}
+		if oc.ClientSecret == "" {
+			missing("clientSecret")
+		}
+	case oc.IsGrantType(authutil.GrantTypePassword):
+		if oc.Username == "" {
+			missing("username")
+		}
+		if oc.Password == "" {
+			missing("password")
+		}
+	case strings.Contains(strings.ToLower(oc.GrantType), TypeJWT):
+		if oc.JWT == "" {
+			missing("jwt")
+		}
+	}
+	return errs
+}
+
+// Redacted returns a copy of the credentials with secret values replaced by `RedactedValue`,
+// including `Transport` header values and proxy password. Settings of types registered with
+// `RegisterType()` are replaced entirely.
+func (creds Credentials) Redacted() Credentials {
+	redact := func(s string) string {
+		if s == "" {
+			return s
+		}
+		return RedactedValue
+	}
+	redactValues := func(m map[string][]string) map[string][]string {
+		if m == nil {
+			return nil
+		}
+		out := map[string][]string{}
+		for k := range m {
+			out[k] = []string{RedactedValue}
+		}
+		return out
+	}
+	redactToken := func(tok *oauth2.Token) *oauth2.Token {
+		if tok == nil {
+			return nil
+		}
+		return &oauth2.Token{
+			AccessToken:  redact(tok.AccessToken),
+			RefreshToken: redact(tok.RefreshToken),
+			TokenType:    tok.TokenType,
+			Expiry:       tok.Expiry}
+	}
+	if creds.Basic != nil {
+		b := *creds.Basic
+		b.Password = redact(b.Password)
+		b.Encoded = redact(b.Encoded)
+		creds.Basic = &b
+	}
+	if creds.HeaderQuery != nil {
+		hq := *creds.HeaderQuery
+		hq.Header = http.Header(redactValues(hq.Header))
+		hq.Query = url.Values(redactValues(hq.Query))
+		creds.HeaderQuery = &hq
+	}
+	if creds.GCPSA != nil {
+		gcp := *creds.GCPSA
+		gcp.GCPCredentials.PrivateKey = redact(gcp.GCPCredentials.PrivateKey)
+		gcp.GCPCredentials.ClientSecret = redact(gcp.GCPCredentials.ClientSecret)
+		creds.GCPSA = &gcp
+	}
+	if creds.GoogleOAuth2 != nil {
+		g := *creds.GoogleOAuth2
+		g.GoogleWebCredentials.ClientSecret = redact(g.GoogleWebCredentials.ClientSecret)
+		g.GoogleWebCredentials.PrivateKey = redact(g.GoogleWebCredentials.PrivateKey)
+		g.Token = redactToken(g.Token)
+		creds.GoogleOAuth2 = &g
+	}
+	if creds.JWT != nil {
+		j := *creds.JWT
+		j.PrivateKey = redact(j.PrivateKey)
+		creds.JWT = &j
+	}
+	if creds.OAuth2 != nil {
+		oc := *creds.OAuth2
+		oc.ClientSecret = redact(oc.ClientSecret)
+		oc.Password = redact(oc.Password)
+		oc.JWT = redact(oc.JWT)
+		oc.Token = redactToken(oc.Token)
+		creds.OAuth2 = &oc
+	}
+	creds.Token = redactToken(creds.Token)
+	if creds.Transport != nil {
+		tc := *creds.Transport
+		tc.Header = http.Header(redactValues(tc.Header))
+		if u, err := url.Parse(tc.ProxyURL); err != nil {
+			tc.ProxyURL = redact(tc.ProxyURL)
+		} else if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), RedactedValue)
+			tc.ProxyURL = u.String()
+		}
+		creds.Transport = &tc
+	}
+	if creds.Custom != nil {
+		creds.Custom = RedactedValue
+	}
+	creds.HTTPClient = nil
+	return creds
+}
@@ -0,0 +1,307 @@
+// Package credhelper implements credential helper protocols for external tools, including
+// git and Docker credential helpers and Kubernetes `ExecCredential`, backed by
+// `goauth.CredentialsSet` accounts and the token cache.
+package credhelper
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/grokify/goauth"
+	"github.com/grokify/goauth/authutil"
+)
+
+// DefaultTokenUsername is the username returned with access tokens when an account has no
+// username, e.g. for git hosts which accept a token as the password.
+const DefaultTokenUsername = "oauth2"
+
+// MetadataStored is the `basic` account metadata key set on accounts added by `Helper.Store()`.
+// Only these accounts are removed by `Helper.Erase()`.
+const MetadataStored = "credhelperStored"
+
+var ErrNotFound = errors.New("credentials not found")
+
+// Credential is a username and secret for a host.
+type Credential struct {
+	Account  string
+	Username string
+	Secret   string
+	Expiry   time.Time // zero if the secret does not expire
+}
+
+// Helper resolves credentials for hosts from the accounts in a credentials set file. The
+// account for a host is the `Hosts` entry for the host, `Account` if set and its server URL has
+// the host, or the account whose server URL has the host. The request scheme, `https` if not
+// set, must match the scheme of the account's server URL, so credentials for an `https`
+// server are never sent over `http`. Credentials are never returned for a host which is not
+// configured, so a helper is safe to call for any URL.
+type Helper struct {
+	Filename string
+	Account  string
+	Hosts    map[string]string // host to account
+	Username string            // username for token credentials, defaults to `DefaultTokenUsername`
+	Cache    *authutil.TokenCache
+}
+
+// HostOf returns the lowercase `host[:port]` of a URL or of a host with an optional path,
+// e.g. `https://index.docker.io/v1/` or `ghcr.io`.
+func HostOf(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "://") {
+		if u, err := url.Parse(s); err == nil {
+			return strings.ToLower(u.Host)
+		}
+	}
+	host, _, _ := strings.Cut(s, "/")
+	return strings.ToLower(host)
+}
+
+// schemeOf returns the lowercase scheme of a URL, or `https` for a host without a scheme.
+func schemeOf(s string) string {
+	if scheme, _, ok := strings.Cut(strings.TrimSpace(s), "://"); ok {
+		return strings.ToLower(scheme)
+	}
+	return "https"
+}
+
+// serverURLOf returns the API base URL for an account, if any.
+func serverURLOf(creds goauth.Credentials) string {
+	s, err := creds.ServerURL()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(s)
+}
+
+func (h Helper) set() (*goauth.CredentialsSet, error) {
+	if strings.TrimSpace(h.Filename) == "" {
+		return nil, errors.New("credentials set file is required")
+	}
+	return goauth.ReadFileCredentialsSet(h.Filename, true)
+}
+
+// LookupAccount returns the account for `serverURL`, a URL or a host, or `ErrNotFound`.
+func (h Helper) LookupAccount(set *goauth.CredentialsSet, serverURL string) (string, error) {
+	host, scheme := HostOf(serverURL), schemeOf(serverURL)
+	if account := h.Hosts[host]; account != "" {
+		// A `Hosts` entry selects the account by host, so only the scheme is checked.
+		if creds, err := set.Get(account); err == nil && (scheme == "https" || schemeOf(serverURLOf(creds)) == scheme) {
+			return account, nil
+		}
+		return "", fmt.Errorf("%w for `%s://%s` with account `%s`", ErrNotFound, scheme, host, account)
+	} else if h.Account != "" {
+		if creds, err := set.Get(h.Account); err == nil && serverURLMatches(creds, scheme, host) {
+			return h.Account, nil
+		}
+		return "", fmt.Errorf("%w for `%s://%s` with account `%s`", ErrNotFound, scheme, host, h.Account)
+	}
+	for _, key := range set.Keys() {
+		if serverURLMatches(set.Credentials[key], scheme, host) {
+			return key, nil
+		}
+	}
+	return "", fmt.Errorf("%w for `%s://%s`", ErrNotFound, scheme, host)
+}
+
+// serverURLMatches returns true if the account's server URL has `scheme` and `host`.
+func serverURLMatches(creds goauth.Credentials, scheme, host string) bool {
+	s := serverURLOf(creds)
+	return s != "" && HostOf(s) == host && schemeOf(s) == scheme
+}
+
+// Get returns the credential for `serverURL`, a URL or a host: the username and password of
+// `basic` accounts, or an access token from the token cache or the account's credentials.
+func (h Helper) Get(ctx context.Context, serverURL string) (Credential, error) {
+	set, err := h.set()
+	if err != nil {
+		return Credential{}, err
+	}
+	account, err := h.LookupAccount(set, serverURL)
+	if err != nil {
+		return Credential{}, err
+	}
+	return h.Credential(ctx, set, account)
+}
+
+// GetAccount returns the credential for an account, without matching a host, e.g. for
+// Kubernetes `ExecCredential` which has no host.
+func (h Helper) GetAccount(ctx context.Context, account string) (Credential, error) {
+	set, err := h.set()
+	if err != nil {
+		return Credential{}, err
+	}
+	return h.Credential(ctx, set, account)
+}
+
+// Credential returns the credential for an account.
+func (h Helper) Credential(ctx context.Context, set *goauth.CredentialsSet, account string) (Credential, error) {
+	creds, err := set.Get(account)
+	if err != nil {
+		return Credential{}, err
+	}
+	if creds.Type == goauth.TypeBasic && creds.Basic != nil {
+		username, password, err := basicUserPass(creds.Basic)
+		return Credential{Account: account, Username: username, Secret: password}, err
+	}
+	file := goauth.FileProvider{Filename: h.Filename, Account: account, Cache: h.Cache}
+	tok, err := goauth.ProviderChain{goauth.CachedTokenProvider{FileProvider: file}, file}.Token(ctx)
+	if err != nil {
+		return Credential{}, err
+	}
+	return Credential{
+		Account:  account,
+		Username: h.tokenUsername(creds),
+		Secret:   tok.AccessToken,
+		Expiry:   tok.Expiry}, nil
+}
+
+func (h Helper) tokenUsername(creds goauth.Credentials) string {
+	if h.Username != "" {
+		return h.Username
+	} else if creds.OAuth2 != nil && creds.OAuth2.Username != "" {
+		return creds.OAuth2.Username
+	}
+	return DefaultTokenUsername
+}
+
+// basicUserPass returns the username and password, decoding `Encoded` if set.
+func basicUserPass(c *goauth.CredentialsBasicAuth) (string, string, error) {
+	enc := strings.TrimSpace(c.Encoded)
+	if enc == "" {
+		return c.Username, c.Password, nil
+	}
+	if len(enc) > 6 && strings.EqualFold(enc[:6], "basic ") {
+		enc = strings.TrimSpace(enc[6:])
+	}
+	b, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return "", "", err
+	}
+	username, password, _ := strings.Cut(string(b), ":")
+	return username, password, nil
+}
+
+// Store saves a username and password for `serverURL`. A `basic` account for the host is
+// updated, or a new `basic` account is added. Token accounts are not changed, since their
+// tokens are already cached.
+func (h Helper) Store(serverURL, username, secret string) error {
+	set, err := h.set()
+	if errors.Is(err, fs.ErrNotExist) {
+		set = &goauth.CredentialsSet{}
+	} else if err != nil {
+		return err
+	}
+	host, scheme := HostOf(serverURL), schemeOf(serverURL)
+	account, err := h.LookupAccount(set, serverURL)
+	var creds goauth.Credentials
+	switch {
+	case errors.Is(err, ErrNotFound):
+		account = AccountName(host)
+		if scheme != "https" {
+			account = scheme + "_" + account
+		}
+		if !strings.Contains(serverURL, "://") {
+			serverURL = "https://" + serverURL
+		}
+		creds = goauth.Credentials{Type: goauth.TypeBasic, Basic: &goauth.CredentialsBasicAuth{
+			ServerURL: serverURL,
+			Metadata:  map[string]string{MetadataStored: "true"}}}
+	case err != nil:
+		return err
+	default:
+		if creds, err = set.Get(account); err != nil {
+			return err
+		} else if creds.Type != goauth.TypeBasic || creds.Basic == nil {
+			return nil
+		} else if u, p, err := basicUserPass(creds.Basic); err == nil && u == username && p == secret {
+			return nil
+		}
+	}
+	b := *creds.Basic
+	b.Username, b.Password, b.Encoded = username, secret, ""
+	creds.Basic = &b
+	out := goauth.CredentialsSet{Credentials: map[string]goauth.Credentials{account: creds}}
+	return out.WriteFileMerge(h.Filename, "", "  ", 0600)
+}
+
+// Erase removes the credential for `serverURL` after it was rejected. The cached token is
+// removed for token accounts. A `basic` account added by `Store()` is removed if `secret` is
+// empty or matches its password. Other `basic` accounts are not changed.
+func (h Helper) Erase(serverURL, secret string) error {
+	set, err := h.set()
+	if err != nil {
+		return err
+	}
+	account, err := h.LookupAccount(set, serverURL)
+	if err != nil {
+		return err
+	}
+	creds, err := set.Get(account)
+	if err != nil {
+		return err
+	}
+	if creds.Type != goauth.TypeBasic || creds.Basic == nil {
+		if h.Cache == nil {
+			return nil
+		}
+		key, err := goauth.FileProvider{Filename: h.Filename, Account: account}.CacheKey()
+		if err != nil {
+			return err
+		}
+		return h.Cache.Delete(key)
+	}
+	if creds.Basic.Metadata[MetadataStored] != "true" {
+		return nil
+	} else if _, password, err := basicUserPass(creds.Basic); err != nil {
+		return err
+	} else if secret != "" && secret != password {
+		return nil
+	}
+	raw, err := goauth.ReadFileCredentialsSet(h.Filename, false)
+	if err != nil {
+		return err
+	}
+	delete(raw.Credentials, account)
+	return raw.WriteFile(h.Filename, "", "  ", 0600)
+}
+
+// List returns the server URL and username of each account with a server URL. Tokens are
+// not requested.
+func (h Helper) List() (map[string]string, error) {
+	set, err := h.set()
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]string{}
+	for _, key := range set.Keys() {
+		creds := set.Credentials[key]
+		s := serverURLOf(creds)
+		if s == "" {
+			continue
+		}
+		if creds.Type == goauth.TypeBasic && creds.Basic != nil {
+			username, _, err := basicUserPass(creds.Basic)
+			if err != nil {
+				return nil, err
+			}
+			out[s] = username
+		} else {
+			out[s] = h.tokenUsername(creds)
+		}
+	}
+	return out, nil
+}
+
+var rxAccountName = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// AccountName returns the account name used for credentials stored for a host.
+func AccountName(host string) string {
+	return rxAccountName.ReplaceAllString(HostOf(host), "_")
+}
@@ -0,0 +1,135 @@
+package credhelper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var hostOfTests = []struct {
+	v    string
+	want string
+}{
+	{"https://index.docker.io/v1/", "index.docker.io"},
+	{"ghcr.io", "ghcr.io"},
+	{"GitHub.com/org/repo", "github.com"},
+	{"https://git.example.com:8443", "git.example.com:8443"},
+}
+
+func TestHostOf(t *testing.T) {
+	for _, tt := range hostOfTests {
+		if got := HostOf(tt.v); got != tt.want {
+			t.Errorf("HostOf(%s): want [%s], got [%s]", tt.v, tt.want, got)
+		}
+	}
+}
+
+func TestGitCredential(t *testing.T) {
+	in := "protocol=https\nhost=example.com\nwwwauth[]=Basic realm=\"x\"\n\nignored=1\n"
+	c, err := ReadGitCredential(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("ReadGitCredential(): err [%v]", err)
+	} else if c.ServerURL() != "https://example.com" || c["ignored"] != "" {
+		t.Errorf("ReadGitCredential(): want [https://example.com], got [%s] [%v]", c.ServerURL(), c)
+	}
+	delete(c, "wwwauth[]")
+	c.SetCredential(Credential{Username: "u", Secret: "p"})
+	var buf bytes.Buffer
+	if err := c.Write(&buf); err != nil {
+		t.Fatalf("GitCredential.Write(): err [%v]", err)
+	} else if want := "protocol=https\nhost=example.com\nusername=u\npassword=p\n"; buf.String() != want {
+		t.Errorf("GitCredential.Write(): want [%s], got [%s]", want, buf.String())
+	}
+}
+
+func TestHelperStoreEraseBasic(t *testing.T) {
+	h := Helper{Filename: filepath.Join(t.TempDir(), "creds.json")}
+	ctx := context.Background()
+	if _, err := h.Get(ctx, "git.example.com"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Helper.Get(): want [%v], got [%v]", os.ErrNotExist, err)
+	}
+	if err := h.Store("https://git.example.com", "bob", "pw1"); err != nil {
+		t.Fatalf("Helper.Store(): err [%v]", err)
+	} else if err := h.Store("https://git.example.com", "bob", "pw2"); err != nil {
+		t.Fatalf("Helper.Store(): err [%v]", err)
+	}
+	cred, err := h.Get(ctx, "git.example.com")
+	if err != nil {
+		t.Fatalf("Helper.Get(): err [%v]", err)
+	} else if cred.Account != "git.example.com" || cred.Username != "bob" || cred.Secret != "pw2" {
+		t.Errorf("Helper.Get(): want [git.example.com bob pw2], got [%s %s %s]", cred.Account, cred.Username, cred.Secret)
+	}
+	if list, err := h.List(); err != nil || list["https://git.example.com"] != "bob" {
+		t.Errorf("Helper.List(): want [bob], got [%v] err [%v]", list, err)
+	}
+	if _, err := h.Get(ctx, "other.example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Helper.Get(other): want [%v], got [%v]", ErrNotFound, err)
+	}
+	if err := h.Erase("https://git.example.com", "pw1"); err != nil {
+		t.Errorf("Helper.Erase(pw1): err [%v]", err)
+	} else if _, err := h.Get(ctx, "git.example.com"); err != nil {
+		t.Errorf("Helper.Erase(pw1): want credential kept, got err [%v]", err)
+	}
+	if err := h.Erase("https://git.example.com", "pw2"); err != nil {
+		t.Errorf("Helper.Erase(pw2): err [%v]", err)
+	} else if _, err := h.Get(ctx, "git.example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Helper.Erase(pw2): want [%v], got [%v]", ErrNotFound, err)
+	}
+}
+
+func TestNewExecCredential(t *testing.T) {
+	t.Setenv(EnvKubernetesExecInfo, `{"apiVersion":"client.authentication.k8s.io/v1beta1","kind":"ExecCredential"}`)
+	ec := NewExecCredential(Credential{Secret: "tok"}, "")
+	if ec.APIVersion != ExecCredentialAPIVersionV1beta1 || ec.Status == nil || ec.Status.Token != "tok" {
+		t.Errorf("NewExecCredential(): want [%s tok], got [%v]", ExecCredentialAPIVersionV1beta1, ec)
+	}
+}
+
+func TestHelperAccountHost(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "creds.json")
+	data := `{"credentials":{"git":{"type":"basic","basic":{"serverURL":"https://git.example.com","username":"alice","password":"pw"}}}}`
+	if err := os.WriteFile(filename, []byte(data), 0600); err != nil {
+		t.Fatalf("os.WriteFile(): err [%v]", err)
+	}
+	ctx := context.Background()
+	h := Helper{Filename: filename, Account: "git"}
+	if cred, err := h.Get(ctx, "git.example.com"); err != nil || cred.Secret != "pw" {
+		t.Errorf("Helper.Get(git.example.com): want [pw], got [%s] err [%v]", cred.Secret, err)
+	}
+	if _, err := h.Get(ctx, "evil.example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Helper.Get(evil.example.com): want [%v], got [%v]", ErrNotFound, err)
+	}
+	// Credentials for an `https` server are not returned for `http`.
+	for _, h := range []Helper{h, {Filename: filename}, {Filename: filename, Hosts: map[string]string{"git.example.com": "git"}}} {
+		if _, err := h.Get(ctx, "http://git.example.com"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Helper.Get(http://git.example.com): want [%v], got [%v]", ErrNotFound, err)
+		}
+		if cred, err := h.Get(ctx, "https://git.example.com"); err != nil || cred.Secret != "pw" {
+			t.Errorf("Helper.Get(https://git.example.com): want [pw], got [%s] err [%v]", cred.Secret, err)
+		}
+	}
+	// Credentials for other hosts are stored in a new account.
+	if err := h.Store("https://evil.example.com", "mallory", "stolen"); err != nil {
+		t.Fatalf("Helper.Store(evil.example.com): err [%v]", err)
+	} else if cred, err := h.GetAccount(ctx, "git"); err != nil || cred.Secret != "pw" {
+		t.Errorf("Helper.Store(evil.example.com): want account [git] unchanged, got [%s] err [%v]", cred.Secret, err)
+	}
+	// Credentials stored for `http` do not replace the `https` account for the host.
+	if err := h.Store("http://git.example.com", "bob", "plain"); err != nil {
+		t.Fatalf("Helper.Store(http://git.example.com): err [%v]", err)
+	} else if cred, err := (Helper{Filename: filename}).Get(ctx, "http://git.example.com"); err != nil || cred.Secret != "plain" {
+		t.Errorf("Helper.Store(http://git.example.com): want [plain], got [%s] err [%v]", cred.Secret, err)
+	} else if cred, err := h.Get(ctx, "https://git.example.com"); err != nil || cred.Secret != "pw" {
+		t.Errorf("Helper.Store(http://git.example.com): want https account unchanged, got [%s] err [%v]", cred.Secret, err)
+	}
+	// Accounts which were not added by `Store()` are not erased.
+	if err := h.Erase("https://git.example.com", "pw"); err != nil {
+		t.Errorf("Helper.Erase(git.example.com): err [%v]", err)
+	} else if _, err := h.Get(ctx, "git.example.com"); err != nil {
+		t.Errorf("Helper.Erase(git.example.com): want account kept, got err [%v]", err)
+	}
+}
@@ -0,0 +1,142 @@
+package credhelper
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Git credential helper attributes.
+const (
+	GitAttrProtocol       = "protocol"
+	GitAttrHost           = "host"
+	GitAttrPath           = "path"
+	GitAttrUsername       = "username"
+	GitAttrPassword       = "password"
+	GitAttrPasswordExpiry = "password_expiry_utc"
+)
+
+// GitCredential is the set of attributes exchanged with git credential helpers, e.g.
+// `protocol`, `host`, `username` and `password`.
+type GitCredential map[string]string
+
+// ReadGitCredential reads `key=value` lines until a blank line or EOF.
+func ReadGitCredential(r io.Reader) (GitCredential, error) {
+	c := GitCredential{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			break
+		}
+		if k, v, ok := strings.Cut(line, "="); ok {
+			c[k] = v
+		}
+	}
+	return c, scanner.Err()
+}
+
+// ServerURL returns `protocol://host`, with `https` if the protocol is not set.
+func (c GitCredential) ServerURL() string {
+	protocol := c[GitAttrProtocol]
+	if protocol == "" {
+		protocol = "https"
+	}
+	return protocol + "://" + c[GitAttrHost]
+}
+
+// Write writes the attributes as `key=value` lines, with `protocol`, `host`, `username` and
+// `password` first.
+func (c GitCredential) Write(w io.Writer) error {
+	order := []string{GitAttrProtocol, GitAttrHost, GitAttrPath, GitAttrUsername, GitAttrPassword}
+	var rest []string
+	for k := range c {
+		if !slices.Contains(order, k) {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	for _, k := range append(order, rest...) {
+		if v, ok := c[k]; ok && v != "" {
+			if strings.ContainsAny(v, "\n\x00") {
+				return fmt.Errorf("git credential attribute `%s` contains a newline or NUL", k)
+			} else if _, err := fmt.Fprintf(w, "%s=%s\n", k, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SetCredential sets the `username`, `password` and `password_expiry_utc` attributes.
+func (c GitCredential) SetCredential(cred Credential) {
+	c[GitAttrUsername] = cred.Username
+	c[GitAttrPassword] = cred.Secret
+	if !cred.Expiry.IsZero() {
+		c[GitAttrPasswordExpiry] = strconv.FormatInt(cred.Expiry.Unix(), 10)
+	}
+}
+
+// DockerErrCredentialsNotFound is the message Docker expects when a helper has no credentials.
+const DockerErrCredentialsNotFound = "credentials not found in native keychain"
+
+// DockerCredentials is the Docker credential helper `get` response and `store` request.
+type DockerCredentials struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// Kubernetes client authentication API versions for `ExecCredential`.
+const (
+	ExecCredentialAPIVersionV1      = "client.authentication.k8s.io/v1"
+	ExecCredentialAPIVersionV1beta1 = "client.authentication.k8s.io/v1beta1"
+	ExecCredentialKind              = "ExecCredential"
+
+	// EnvKubernetesExecInfo is set by kubectl with the `ExecCredential` request.
+	EnvKubernetesExecInfo = "KUBERNETES_EXEC_INFO"
+)
+
+// ExecCredential is the Kubernetes client-go exec plugin credential format, which is also
+// used by other tools that run a command to get a token.
+type ExecCredential struct {
+	APIVersion string                `json:"apiVersion"`
+	Kind       string                `json:"kind"`
+	Status     *ExecCredentialStatus `json:"status,omitempty"`
+}
+
+type ExecCredentialStatus struct {
+	Token               string `json:"token,omitempty"`
+	ExpirationTimestamp string `json:"expirationTimestamp,omitempty"`
+}
+
+// NewExecCredential returns an `ExecCredential` with the credential secret as the token. If
+// `apiVersion` is empty, the version from `KUBERNETES_EXEC_INFO` or v1 is used.
+func NewExecCredential(cred Credential, apiVersion string) ExecCredential {
+	if apiVersion == "" {
+		apiVersion = ExecCredentialAPIVersion()
+	}
+	status := &ExecCredentialStatus{Token: cred.Secret}
+	if !cred.Expiry.IsZero() {
+		status.ExpirationTimestamp = cred.Expiry.UTC().Format(time.RFC3339)
+	}
+	return ExecCredential{APIVersion: apiVersion, Kind: ExecCredentialKind, Status: status}
+}
+
+// ExecCredentialAPIVersion returns the API version requested in `KUBERNETES_EXEC_INFO`, or v1.
+func ExecCredentialAPIVersion() string {
+	var info ExecCredential
+	if s := os.Getenv(EnvKubernetesExecInfo); s != "" {
+		if err := json.Unmarshal([]byte(s), &info); err == nil && info.APIVersion != "" {
+			return info.APIVersion
+		}
+	}
+	return ExecCredentialAPIVersionV1
+}
@@ -0,0 +1,62 @@
+package endpoints
+
+import "sort"
+
+var serviceNames = []string{
+	ServiceAha,
+	ServiceAsana,
+	ServiceAtlassian,
+	ServiceEbay,
+	ServiceEbaySandbox,
+	ServiceFacebook,
+	ServiceGithub,
+	ServiceGoogle,
+	ServiceHubspot,
+	ServiceInstagram,
+	ServiceLyft,
+	ServiceMailchimp,
+	ServiceMonday,
+	ServicePagerduty,
+	ServicePaypal,
+	ServicePaypalSandbox,
+	ServicePipedrive,
+	ServicePracticesuite,
+	ServiceRingcentral,
+	ServiceRingcentralSandbox,
+	ServiceShippo,
+	ServiceShopify,
+	ServiceSlack,
+	ServiceStackoverflow,
+	ServiceStripe,
+	ServiceTodoist,
+	ServiceUber,
+	ServiceWepay,
+	ServiceWepaySandbox,
+	ServiceWrike,
+	ServiceWunderlist,
+	ServiceZoom,
+}
+
+// subdomainPlaceholder is used to resolve services which require a subdomain.
+const subdomainPlaceholder = "example"
+
+// Services returns the sorted names of services supported by `NewEndpoint()`.
+func Services() []string {
+	var svcs []string
+	for _, svc := range serviceNames {
+		if _, _, err := NewEndpoint(svc, subdomainPlaceholder); err == nil {
+			svcs = append(svcs, svc)
+		}
+	}
+	sort.Strings(svcs)
+	return svcs
+}
+
+// RequiresSubdomain returns true if `NewEndpoint()` requires a subdomain for the service.
+func RequiresSubdomain(serviceName string) bool {
+	if _, _, err := NewEndpoint(serviceName, ""); err == nil {
+		return false
+	}
+	_, _, err := NewEndpoint(serviceName, subdomainPlaceholder)
+	return err == nil
+}
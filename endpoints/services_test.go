@@ -0,0 +1,55 @@
+package endpoints
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// TestServiceNames checks that `serviceNames` matches the services handled by `NewEndpoint()`.
+func TestServiceNames(t *testing.T) {
+	for _, name := range serviceNames {
+		if _, _, err := NewEndpoint(name, subdomainPlaceholder); err != nil {
+			t.Errorf("NewEndpoint(%s): err [%v]", name, err)
+		}
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), "endpoints.go", nil, 0)
+	if err != nil {
+		t.Fatalf("parser.ParseFile(endpoints.go): err [%v]", err)
+	}
+	consts := map[string]string{}
+	data, err := parser.ParseFile(token.NewFileSet(), "data.go", nil, 0)
+	if err != nil {
+		t.Fatalf("parser.ParseFile(data.go): err [%v]", err)
+	}
+	ast.Inspect(data, func(n ast.Node) bool {
+		if vs, ok := n.(*ast.ValueSpec); ok && len(vs.Names) == 1 && len(vs.Values) == 1 {
+			if lit, ok := vs.Values[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				consts[vs.Names[0].Name], _ = strconv.Unquote(lit.Value)
+			}
+		}
+		return true
+	})
+	var cases []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		if cc, ok := n.(*ast.CaseClause); ok {
+			for _, e := range cc.List {
+				if id, ok := e.(*ast.Ident); ok && consts[id.Name] != "" {
+					cases = append(cases, consts[id.Name])
+				}
+			}
+		}
+		return true
+	})
+	if len(cases) == 0 {
+		t.Fatalf("NewEndpoint(): no service cases found")
+	}
+	for _, name := range cases {
+		if !slices.Contains(serviceNames, name) {
+			t.Errorf("serviceNames: want [%s] handled by NewEndpoint(), not found", name)
+		}
+	}
+}
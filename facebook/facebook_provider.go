@@ -0,0 +1,15 @@
+package facebook
+
+import (
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/goauth/endpoints"
+	"github.com/grokify/goauth/multiservice/providers"
+)
+
+func init() {
+	providers.MustRegister(providers.Provider{
+		Name:          endpoints.ServiceFacebook,
+		Endpoint:      providers.ServiceEndpoint(endpoints.ServiceFacebook),
+		DefaultScopes: []string{"public_profile", "email"},
+		NewClientUtil: func() authutil.OAuth2Util { return &ClientUtil{} }})
+}
@@ -25,6 +25,9 @@ type GoogleConfigFileStore struct {
 	UseDefaultDir  bool
 	ForceNewToken  bool
 	State          string
+	// TokenCache, if set, is used instead of `TokenPath` to store tokens, keyed by project ID,
+	// client ID and scopes.
+	TokenCache *authutil.TokenCache
 }
 
 // LoadCredentialsBytes set this after setting Scopes.
@@ -84,8 +87,27 @@ func (gc *GoogleConfigFileStore) SetDefaultFilepath() error {
 	return nil
 }
 
+// TokenCacheKey returns the `TokenCache` key for the loaded credentials and scopes.
+func (gc *GoogleConfigFileStore) TokenCacheKey() (string, error) {
+	if gc.Credentials == nil || len(strings.TrimSpace(gc.Credentials.ClientID)) == 0 {
+		return "", errors.New("err GoogleConfigFileStore.TokenCacheKey() - No Credentials Loaded")
+	}
+	return authutil.TokenCacheKey("",
+		fmt.Sprintf("google-%s-%s", strings.TrimSpace(gc.Credentials.ProjectID), strings.TrimSpace(gc.Credentials.ClientID)),
+		gc.Scopes), nil
+}
+
 // Client returns a `*http.Client`.
 func (gc *GoogleConfigFileStore) Client(ctx context.Context) (*http.Client, error) {
+	if gc.TokenCache != nil {
+		if gc.OAuthConfig == nil {
+			return nil, errors.New("err GoogleConfigFileStore.Client() - No Credentials Loaded")
+		} else if key, err := gc.TokenCacheKey(); err != nil {
+			return nil, err
+		} else {
+			return authutil.NewClientWebTokenCache(ctx, gc.OAuthConfig, gc.TokenCache, key, gc.ForceNewToken, gc.State)
+		}
+	}
 	return NewClientFileStore(
 		ctx,
 		gc.CredentialsRaw,
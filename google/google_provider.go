@@ -0,0 +1,15 @@
+package google
+
+import (
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/goauth/endpoints"
+	"github.com/grokify/goauth/multiservice/providers"
+)
+
+func init() {
+	providers.MustRegister(providers.Provider{
+		Name:          endpoints.ServiceGoogle,
+		Endpoint:      providers.ServiceEndpoint(endpoints.ServiceGoogle),
+		DefaultScopes: []string{"openid", "email", "profile"},
+		NewClientUtil: func() authutil.OAuth2Util { return &ClientUtil{} }})
+}
@@ -2,6 +2,7 @@ package metabase
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -45,6 +46,8 @@ type Config struct {
 	Username      string
 	Password      string
 	TLSSkipVerify bool
+	// HTTPClient, if set, is used for session requests.
+	HTTPClient *http.Client
 }
 
 func (cfg *Config) Validate() error {
@@ -72,6 +75,12 @@ func (cfg *Config) Validate() error {
 }
 
 func NewClient(cfg Config) (*http.Client, *AuthResponse, error) {
+	return NewClientContext(authutil.ContextWithHTTPClient(context.Background(), cfg.HTTPClient), cfg)
+}
+
+// NewClientContext is like `NewClient()` but uses the `*http.Client` in the
+// `oauth2.HTTPClient` context key for session requests.
+func NewClientContext(ctx context.Context, cfg Config) (*http.Client, *AuthResponse, error) {
 	cfg.SessionID = strings.TrimSpace(cfg.SessionID)
 	if len(cfg.SessionID) > 0 {
 		httpClient := NewClientSessionID(cfg.SessionID, cfg.TLSSkipVerify)
@@ -84,7 +93,8 @@ func NewClient(cfg Config) (*http.Client, *AuthResponse, error) {
 		}
 	}
 
-	return NewClientPassword(
+	return NewClientPasswordContext(
+		ctx,
 		cfg.BaseURL,
 		cfg.Username,
 		cfg.Password,
@@ -103,7 +113,14 @@ type AuthResponse struct {
 // NewClient returns a *http.Client that will add the Metabase Session
 // header to each request.
 func NewClientPassword(baseURL, username, password string, allowInsecure bool) (*http.Client, *AuthResponse, error) {
-	resp, err := AuthRequest(
+	return NewClientPasswordContext(context.Background(), baseURL, username, password, allowInsecure)
+}
+
+// NewClientPasswordContext is like `NewClientPassword()` but uses the `*http.Client` in the
+// `oauth2.HTTPClient` context key for the session request.
+func NewClientPasswordContext(ctx context.Context, baseURL, username, password string, allowInsecure bool) (*http.Client, *AuthResponse, error) {
+	resp, err := AuthRequestContext(
+		ctx,
 		urlutil.JoinAbsolute(baseURL, RelPathAPISession),
 		username,
 		password,
@@ -253,25 +270,45 @@ func NewClientEnv(initCfg ConfigEnvOpts) (*http.Client, *AuthResponse, error) {
 // in Metabase API requests. It follows the following curl command:
 // curl -v -H "Content-Type: application/json" -d '{"username":"myusername","password":"mypassword"}' -XPOST 'http://example.com/api/session'
 func AuthRequest(authURL, username, password string, tlsSkipVerify bool) (*http.Response, error) {
+	return AuthRequestContext(context.Background(), authURL, username, password, tlsSkipVerify)
+}
+
+// AuthRequestContext is like `AuthRequest()` but uses the `*http.Client` in the
+// `oauth2.HTTPClient` context key. `tlsSkipVerify` applies when the client uses an
+// `*http.Transport` or the default transport.
+func AuthRequestContext(ctx context.Context, authURL, username, password string, tlsSkipVerify bool) (*http.Response, error) {
 	bodyBytes, err := json.Marshal(authRequest{Username: username, Password: password}) //nolint:gosec // G117: Auth request for Metabase API session
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, authURL, bytes.NewBuffer(bodyBytes))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, authURL, bytes.NewBuffer(bodyBytes))
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Add(httputilmore.HeaderContentType, httputilmore.ContentTypeAppJSONUtf8)
 
-	client := &http.Client{}
+	client := authutil.ContextClient(ctx)
 
 	if tlsSkipVerify { // #nosec G402
 		// nosec - https://github.com/securego/gosec/issues/780
 		// nosec - https://github.com/securego/gosec/issues/278
-		client.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: tlsSkipVerify}}
+		var xport *http.Transport
+		if client.Transport == nil {
+			xport = &http.Transport{}
+		} else if x, ok := client.Transport.(*http.Transport); ok {
+			xport = x.Clone()
+		}
+		if xport != nil {
+			if xport.TLSClientConfig == nil {
+				xport.TLSClientConfig = &tls.Config{} // #nosec G402
+			}
+			xport.TLSClientConfig.InsecureSkipVerify = tlsSkipVerify // #nosec G402
+			clt := *client
+			clt.Transport = xport
+			client = &clt
+		}
 	}
 
 	return client.Do(req)
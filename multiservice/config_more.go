@@ -3,11 +3,13 @@ package multiservice
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/grokify/mogo/crypto/randutil"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/facebook"
+
+	"github.com/grokify/goauth/multiservice/providers"
 )
 
 // O2ConfigCanonical is similar to Google but includes scopes
@@ -31,18 +33,33 @@ func NewO2ConfigMoreFromJSON(bytes []byte) (*O2ConfigMore, error) {
 		return nil, err
 	}
 	o2cc.Provider = strings.ToLower(strings.TrimSpace(o2cc.Provider))
-	switch o2cc.Provider {
-	case "facebook":
-		if len(strings.TrimSpace(o2cc.AuthURI)) == 0 {
-			o2cc.AuthURI = facebook.Endpoint.AuthURL
-		}
-		if len(strings.TrimSpace(o2cc.TokenURI)) == 0 {
-			o2cc.TokenURI = facebook.Endpoint.TokenURL
-		}
+	if p, err := providers.Get(o2cc.Provider); err == nil {
+		o2cc.SetProviderDefaults(p)
 	}
 	return &o2cc, nil
 }
 
+// SetProviderDefaults sets empty endpoint URLs from the provider's endpoint, if it does not
+// require a subdomain, and empty scopes from the provider's default scopes.
+func (cm *O2ConfigMore) SetProviderDefaults(p *providers.Provider) {
+	if p.Endpoint != nil {
+		if ep, err := p.Endpoint(""); err == nil {
+			if len(strings.TrimSpace(cm.AuthURI)) == 0 {
+				cm.AuthURI = ep.AuthURL
+			}
+			if len(strings.TrimSpace(cm.TokenURI)) == 0 {
+				cm.TokenURI = ep.TokenURL
+			}
+		}
+	}
+	if len(cm.Scopes) == 0 {
+		cm.Scopes = slices.Clone(p.DefaultScopes)
+	}
+}
+
+// ProviderType returns the provider constant.
+//
+// Deprecated: use `providers.Get()` with `O2ConfigMore.Provider`.
 func (cm *O2ConfigMore) ProviderType() (OAuth2Provider, error) {
 	return ProviderStringToConst(cm.Provider)
 }
@@ -74,6 +91,10 @@ func (cm *O2ConfigMore) RedirectURL() string {
 	return redirectURL
 }
 
+// RandomState returns a state with a random integer suffix.
+//
+// Deprecated: the state is not signed, expiring or single use. Use `oauthstate.Manager`, which
+// `OAuth2Manager.ConnectHandler()` uses, or `oauthstate.Random()`.
 func RandomState(statePrefix string, randomSuffix bool) (string, error) {
 	parts := []string{}
 	if len(statePrefix) > 0 {
@@ -0,0 +1,221 @@
+package multiservice
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/goauth/multiservice/tokens"
+	"github.com/grokify/goauth/scim"
+)
+
+// ConnectionKeyPrefix is the token set key prefix for connections.
+const ConnectionKeyPrefix = "connections/"
+
+// ErrTenantIDRequired is returned when a connection has no tenant or user ID.
+var ErrTenantIDRequired = errors.New("connection tenant id is required")
+
+// Connection is an account at a service which a tenant, e.g. an end user, has authorized. A
+// tenant can have several connections to a service, e.g. two Google accounts. The token is
+// stored in `OAuth2Manager.TokenSet` under `Key()`, with the connection as metadata.
+type Connection struct {
+	TenantID     string     `json:"tenantId"`
+	ServiceKey   string     `json:"serviceKey"`
+	ConnectionID string     `json:"connectionId"`
+	Provider     string     `json:"provider,omitempty"`
+	Scopes       []string   `json:"scopes,omitempty"`
+	Identity     *scim.User `json:"identity,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+// Key returns the token set key for the connection.
+func (conn *Connection) Key() string {
+	return ConnectionKey(conn.TenantID, conn.ServiceKey, conn.ConnectionID)
+}
+
+// ConnectionKey returns the token set key for a connection. Empty trailing parts return the
+// prefix for listing, e.g. all of a tenant's connections.
+func ConnectionKey(tenantID, serviceKey, connectionID string) string {
+	key := ConnectionKeyPrefix + url.PathEscape(strings.TrimSpace(tenantID)) + "/"
+	if serviceKey = strings.TrimSpace(serviceKey); serviceKey != "" {
+		key += url.PathEscape(serviceKey) + "/"
+		if connectionID = strings.TrimSpace(connectionID); connectionID != "" {
+			key += url.PathEscape(connectionID)
+		}
+	}
+	return key
+}
+
+// CreateConnection stores `tok` as a new connection for a tenant. The provider account
+// identity is read with the service provider's `authutil.OAuth2Util`, if there is one, and its
+// ID is used as the connection ID, so connecting the same account again replaces the
+// connection. Otherwise a random connection ID is used. Granted scopes are read from the token
+// response `scope` field, or the configured scopes if not returned.
+func (cb *OAuth2Manager) CreateConnection(ctx context.Context, tenantID, serviceKey string, tok *oauth2.Token) (*Connection, error) {
+	if cb.ConfigSet == nil {
+		return nil, fmt.Errorf("OAuth2Manager.ConfigSet == nil")
+	} else if cb.TokenSet == nil {
+		return nil, fmt.Errorf("OAuth2Manager.TokenSet == nil")
+	} else if tenantID = strings.TrimSpace(tenantID); tenantID == "" {
+		return nil, ErrTenantIDRequired
+	} else if tok == nil {
+		return nil, errors.New("connection token is required")
+	}
+	cfgMore, err := cb.ConfigSet.Get(serviceKey)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := cb.identity(ctx, cfgMore, tok)
+	if err != nil {
+		return nil, err
+	}
+	return cb.storeConnection(ctx, tenantID, serviceKey, cfgMore, tok, identity)
+}
+
+// storeConnection stores `tok` as a connection with `identity`, which may be nil.
+func (cb *OAuth2Manager) storeConnection(ctx context.Context, tenantID, serviceKey string, cfgMore *O2ConfigMore, tok *oauth2.Token, identity *scim.User) (*Connection, error) {
+	conn := &Connection{
+		TenantID:   strings.TrimSpace(tenantID),
+		ServiceKey: strings.TrimSpace(serviceKey),
+		Provider:   cfgMore.Provider,
+		Scopes:     grantedScopes(tok, cfgMore.Scopes),
+		Identity:   identity,
+		CreatedAt:  time.Now().UTC()}
+	if identity != nil && strings.TrimSpace(identity.ID) != "" {
+		conn.ConnectionID = strings.TrimSpace(identity.ID)
+	} else {
+		conn.ConnectionID = rand.Text()
+	}
+	meta, err := json.Marshal(conn)
+	if err != nil {
+		return nil, err
+	}
+	ti := tokens.NewTokenInfo(conn.ServiceKey, conn.Provider, tok)
+	ti.Metadata = meta
+	return conn, cb.TokenSet.SetTokenInfo(ctx, conn.Key(), ti)
+}
+
+// identity returns the provider account for `tok`, or nil if the provider has no
+// `authutil.OAuth2Util`.
+func (cb *OAuth2Manager) identity(ctx context.Context, cfgMore *O2ConfigMore, tok *oauth2.Token) (*scim.User, error) {
+	var util authutil.OAuth2Util
+	if cb.ClientUtilFunc != nil {
+		if u, err := cb.ClientUtilFunc(cfgMore.Provider); err != nil {
+			return nil, err
+		} else {
+			util = u
+		}
+	} else if p, err := cb.providers().Get(cfgMore.Provider); err == nil {
+		util = p.ClientUtil()
+	}
+	if util == nil {
+		return nil, nil
+	}
+	util.SetClient(cfgMore.Config().Client(ctx, tok))
+	user, err := util.GetSCIMUser()
+	if err != nil {
+		return nil, fmt.Errorf("get connection identity (%s): %w", cfgMore.Provider, err)
+	}
+	return &user, nil
+}
+
+// grantedScopes returns the space delimited `scope` token response field, or `requested`.
+func grantedScopes(tok *oauth2.Token, requested []string) []string {
+	if scope, ok := tok.Extra("scope").(string); ok && strings.TrimSpace(scope) != "" {
+		return strings.Fields(scope)
+	}
+	return slices.Clone(requested)
+}
+
+// GetConnection returns a connection, or an error wrapping `tokens.ErrNotFound`.
+func (cb *OAuth2Manager) GetConnection(ctx context.Context, tenantID, serviceKey, connectionID string) (*Connection, error) {
+	if cb.TokenSet == nil {
+		return nil, fmt.Errorf("OAuth2Manager.TokenSet == nil")
+	} else if strings.TrimSpace(tenantID) == "" {
+		return nil, ErrTenantIDRequired
+	}
+	ti, err := cb.TokenSet.GetTokenInfo(ctx, ConnectionKey(tenantID, serviceKey, connectionID))
+	if err != nil {
+		return nil, err
+	}
+	return parseConnection(ti)
+}
+
+func parseConnection(ti *tokens.TokenInfo) (*Connection, error) {
+	conn := &Connection{}
+	if len(ti.Metadata) == 0 {
+		return nil, errors.New("token has no connection metadata")
+	}
+	return conn, json.Unmarshal(ti.Metadata, conn)
+}
+
+// ListConnections returns a tenant's connections sorted by service key and connection ID,
+// for all services if `serviceKey` is empty.
+func (cb *OAuth2Manager) ListConnections(ctx context.Context, tenantID, serviceKey string) ([]*Connection, error) {
+	if cb.TokenSet == nil {
+		return nil, fmt.Errorf("OAuth2Manager.TokenSet == nil")
+	} else if strings.TrimSpace(tenantID) == "" {
+		return nil, ErrTenantIDRequired
+	}
+	keys, err := cb.TokenSet.List(ctx, ConnectionKey(tenantID, serviceKey, ""))
+	if err != nil {
+		return nil, err
+	}
+	conns := []*Connection{}
+	for _, key := range keys {
+		ti, err := cb.TokenSet.GetTokenInfo(ctx, key)
+		if errors.Is(err, tokens.ErrNotFound) {
+			continue // disconnected since listing
+		} else if err != nil {
+			return nil, err
+		}
+		conn, err := parseConnection(ti)
+		if err != nil {
+			return nil, fmt.Errorf("connection (%s): %w", key, err)
+		}
+		conns = append(conns, conn)
+	}
+	return conns, nil
+}
+
+// Disconnect removes a connection and its token. The token is not revoked at the provider,
+// which can be done first with `authutil.RevokeToken()`.
+func (cb *OAuth2Manager) Disconnect(ctx context.Context, tenantID, serviceKey, connectionID string) error {
+	if cb.TokenSet == nil {
+		return fmt.Errorf("OAuth2Manager.TokenSet == nil")
+	} else if strings.TrimSpace(tenantID) == "" {
+		return ErrTenantIDRequired
+	} else if strings.TrimSpace(serviceKey) == "" || strings.TrimSpace(connectionID) == "" {
+		return errors.New("connection service key and connection id are required")
+	}
+	return cb.TokenSet.Delete(ctx, ConnectionKey(tenantID, serviceKey, connectionID))
+}
+
+// ConnectionClient returns a client for a connection which refreshes its token and stores
+// each refreshed token. See `tokens.TokenSource`.
+func (cb *OAuth2Manager) ConnectionClient(ctx context.Context, tenantID, serviceKey, connectionID string) (*http.Client, error) {
+	if cb.ConfigSet == nil {
+		return nil, fmt.Errorf("OAuth2Manager.ConfigSet == nil")
+	} else if _, err := cb.GetConnection(ctx, tenantID, serviceKey, connectionID); err != nil {
+		return nil, err
+	}
+	cfgMore, err := cb.ConfigSet.Get(serviceKey)
+	if err != nil {
+		return nil, err
+	}
+	tokenSource := tokens.NewTokenSource(ctx, cfgMore.Config(), cb.TokenSet, ConnectionKey(tenantID, serviceKey, connectionID))
+	if _, err := tokenSource.Token(); err != nil {
+		return nil, err
+	}
+	return oauth2.NewClient(ctx, oauth2.ReuseTokenSource(nil, tokenSource)), nil
+}
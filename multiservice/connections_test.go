@@ -0,0 +1,76 @@
+package multiservice
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/goauth/multiservice/tokens"
+	"github.com/grokify/goauth/scim"
+)
+
+type testClientUtil struct{ id string }
+
+func (u *testClientUtil) SetClient(*http.Client) {}
+
+func (u *testClientUtil) GetSCIMUser() (scim.User, error) {
+	return scim.User{ID: u.id, UserName: u.id + "@example.com"}, nil
+}
+
+func TestConnections(t *testing.T) {
+	ctx := context.Background()
+	mgr := NewOAuth2Manager()
+	mgr.ConfigSet.ConfigMoreMap["hubspot"] = &O2ConfigMore{Provider: "hubspot", Scopes: []string{"crm.read"}}
+	mgr.ConfigSet.ConfigMoreMap["google"] = &O2ConfigMore{Provider: "google"}
+	mgr.ClientUtilFunc = func(provider string) (authutil.OAuth2Util, error) {
+		if provider == "google" {
+			return &testClientUtil{id: "g-1"}, nil
+		}
+		return nil, nil
+	}
+	tok := &oauth2.Token{AccessToken: "a", Expiry: time.Now().Add(time.Hour)}
+
+	hub, err := mgr.CreateConnection(ctx, "user/1", "hubspot", tok)
+	if err != nil {
+		t.Fatalf("OAuth2Manager.CreateConnection(hubspot): err [%v]", err)
+	} else if hub.ConnectionID == "" || hub.Identity != nil || strings.Join(hub.Scopes, " ") != "crm.read" {
+		t.Errorf("OAuth2Manager.CreateConnection(hubspot): want random id, no identity and [crm.read], got [%v]", hub)
+	}
+	for range 2 {
+		goog, err := mgr.CreateConnection(ctx, "user/1", "google", tok.WithExtra(map[string]any{"scope": "email profile"}))
+		if err != nil {
+			t.Fatalf("OAuth2Manager.CreateConnection(google): err [%v]", err)
+		} else if goog.ConnectionID != "g-1" || goog.Identity.UserName != "g-1@example.com" || strings.Join(goog.Scopes, " ") != "email profile" {
+			t.Errorf("OAuth2Manager.CreateConnection(google): want [g-1] with identity and [email profile], got [%v]", goog)
+		}
+	}
+	if _, err := mgr.CreateConnection(ctx, "user-2", "google", tok); err != nil {
+		t.Fatalf("OAuth2Manager.CreateConnection(user-2): err [%v]", err)
+	}
+
+	conns, err := mgr.ListConnections(ctx, "user/1", "")
+	if err != nil || len(conns) != 2 || conns[0].ServiceKey != "google" || conns[1].ServiceKey != "hubspot" {
+		t.Errorf("OAuth2Manager.ListConnections(user/1): want [google hubspot], got [%v] err [%v]", conns, err)
+	}
+	if conns, err := mgr.ListConnections(ctx, "user/1", "hubspot"); err != nil || len(conns) != 1 || conns[0].ConnectionID != hub.ConnectionID {
+		t.Errorf("OAuth2Manager.ListConnections(user/1, hubspot): want [%s], got [%v] err [%v]", hub.ConnectionID, conns, err)
+	}
+
+	if clt, err := mgr.ConnectionClient(ctx, "user/1", "google", "g-1"); err != nil || clt == nil {
+		t.Errorf("OAuth2Manager.ConnectionClient(g-1): err [%v]", err)
+	}
+	if err := mgr.Disconnect(ctx, "user/1", "google", "g-1"); err != nil {
+		t.Errorf("OAuth2Manager.Disconnect(g-1): err [%v]", err)
+	} else if _, err := mgr.ConnectionClient(ctx, "user/1", "google", "g-1"); !errors.Is(err, tokens.ErrNotFound) {
+		t.Errorf("OAuth2Manager.ConnectionClient(g-1) disconnected: want [%v], got [%v]", tokens.ErrNotFound, err)
+	}
+	if _, err := mgr.ListConnections(ctx, " ", ""); !errors.Is(err, ErrTenantIDRequired) {
+		t.Errorf("OAuth2Manager.ListConnections(empty): want [%v], got [%v]", ErrTenantIDRequired, err)
+	}
+}
@@ -8,19 +8,28 @@ import (
 	"strings"
 
 	"github.com/grokify/mogo/os/osutil"
+	"golang.org/x/oauth2"
 
-	"github.com/grokify/goauth/aha"
 	"github.com/grokify/goauth/authutil"
-	"github.com/grokify/goauth/facebook"
-	"github.com/grokify/goauth/google"
+	"github.com/grokify/goauth/multiservice/providers"
 	"github.com/grokify/goauth/multiservice/tokens"
 	"github.com/grokify/goauth/multiservice/tokens/tokensetmemory"
-	"github.com/grokify/goauth/ringcentral"
+
+	// Built-in providers. Other providers are registered by importing their package.
+	_ "github.com/grokify/goauth/aha"
+	_ "github.com/grokify/goauth/facebook"
+	_ "github.com/grokify/goauth/google"
+	_ "github.com/grokify/goauth/ringcentral"
 )
 
 type OAuth2Manager struct {
 	ConfigSet *ConfigMoreSet
 	TokenSet  tokens.TokenSet
+	// Providers defaults to `providers.Default`.
+	Providers *providers.Registry
+	// ClientUtilFunc returns the `authutil.OAuth2Util` used to read a connection's identity for
+	// a provider, or nil if there is none. It defaults to the registered provider's `ClientUtil`.
+	ClientUtilFunc func(provider string) (authutil.OAuth2Util, error)
 }
 
 func NewOAuth2Manager() *OAuth2Manager {
@@ -42,12 +51,20 @@ func (cb *OAuth2Manager) GetClient(ctx context.Context, serviceKey string) (*htt
 	if err != nil {
 		return nil, err
 	}
-	cfg := cfgMore.Config()
-	tok, err := cb.TokenSet.GetToken(serviceKey)
-	if err != nil {
+	// Refreshed tokens are stored so rotated refresh tokens are not lost.
+	tokenSource := tokens.NewTokenSource(ctx, cfgMore.Config(), cb.TokenSet, serviceKey)
+	if _, err := tokenSource.Token(); err != nil {
 		return nil, err
 	}
-	return cfg.Client(ctx, tok), nil
+	return oauth2.NewClient(ctx, oauth2.ReuseTokenSource(nil, tokenSource)), nil
+}
+
+// providers returns `OAuth2Manager.Providers` or `providers.Default`.
+func (cb *OAuth2Manager) providers() *providers.Registry {
+	if cb.Providers != nil {
+		return cb.Providers
+	}
+	return providers.Default
 }
 
 type AppURLs struct {
@@ -81,25 +98,26 @@ func EnvOAuth2ConfigMap(env []osutil.EnvVar, prefix string) (*ConfigMoreSet, err
 	return cfgs, nil
 }
 
+// NewClientUtilForProviderType returns the `authutil.OAuth2Util` for a provider.
+//
+// Deprecated: use `NewClientUtilForProviderTypeString()` or `providers.Get()`.
 func NewClientUtilForProviderType(providerType OAuth2Provider) (authutil.OAuth2Util, error) {
-	switch providerType {
-	case Aha:
-		return &aha.ClientUtil{}, nil
-	case Facebook:
-		return &facebook.ClientUtil{}, nil
-	case Google:
-		return &google.ClientUtil{}, nil
-	case RingCentral:
-		return &ringcentral.ClientUtil{}, nil
-	default:
-		return nil, fmt.Errorf("cannot find ClientUtil for provider type [%s]", providerType)
-	}
+	return NewClientUtilForProviderTypeString(providerType.String())
 }
 
+// NewClientUtilForProviderTypeString returns the `authutil.OAuth2Util` for a registered
+// provider name.
 func NewClientUtilForProviderTypeString(providerTypeString string) (authutil.OAuth2Util, error) {
-	providerType, err := ProviderStringToConst(providerTypeString)
+	return clientUtilForProvider(providers.Default, providerTypeString)
+}
+
+// clientUtilForProvider returns the `authutil.OAuth2Util` for a provider in `reg`.
+func clientUtilForProvider(reg *providers.Registry, provider string) (authutil.OAuth2Util, error) {
+	p, err := reg.Get(provider)
 	if err != nil {
-		return &ringcentral.ClientUtil{}, nil
+		return nil, err
+	} else if util := p.ClientUtil(); util != nil {
+		return util, nil
 	}
-	return NewClientUtilForProviderType(providerType)
+	return nil, fmt.Errorf("cannot find ClientUtil for provider type [%s]", p.Name)
 }
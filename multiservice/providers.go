@@ -3,11 +3,16 @@ package multiservice
 import (
 	"fmt"
 	"strings"
+
+	"github.com/grokify/goauth/multiservice/providers"
 )
 
 // OAuth2Provider a constant list of OAuth2 providers.
 // Warning: do not rely on ordering or integer value
 // as this will change as additional providers are added.
+//
+// Deprecated: use the provider names in the `providers` registry, which providers outside this
+// package can be added to.
 type OAuth2Provider int
 
 const (
@@ -24,7 +29,7 @@ const (
 	Zendesk
 )
 
-var providers = [...]string{
+var providerNames = [...]string{
 	"aha",
 	"facebook",
 	"google",
@@ -41,19 +46,21 @@ var providers = [...]string{
 // String converts a provider type to a string.
 func (p OAuth2Provider) String() string {
 	if Aha <= p && p <= Zendesk {
-		return providers[p]
+		return providerNames[p]
 	}
-	panic(fmt.Sprintf("Provider not in range [%d]", p))
+	return fmt.Sprintf("OAuth2Provider(%d)", int(p))
 }
 
 // ProviderStringToConst returns an OAuth2Provider type constant
 // from a string.
+//
+// Deprecated: use `providers.Get()`.
 func ProviderStringToConst(s string) (OAuth2Provider, error) {
 	s = strings.ToLower(strings.TrimSpace(s))
-	for i, p := range providers {
+	for i, p := range providerNames {
 		if p == s {
 			return OAuth2Provider(i), nil
 		}
 	}
-	return RingCentral, fmt.Errorf("OAuth2 Provider Not Found [%v]", s)
+	return -1, fmt.Errorf("%w (%s)", providers.ErrNotFound, s)
 }
@@ -0,0 +1,180 @@
+// Package providers is a registry of OAuth 2.0 login providers. A provider package registers
+// its name, endpoint, default scopes and how to read the user profile, usually in `init()`, so
+// `multiservice` can use providers it does not import, like `database/sql` drivers.
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/goauth/endpoints"
+	"github.com/grokify/goauth/scim"
+)
+
+var (
+	// ErrNotFound is returned when no provider is registered for a name.
+	ErrNotFound = errors.New("oauth2 provider not found")
+	// ErrDuplicate is returned when a provider is registered twice.
+	ErrDuplicate = errors.New("oauth2 provider already registered")
+)
+
+// ProfileMapper converts a userinfo response body to a SCIM user.
+type ProfileMapper func(data []byte) (scim.User, error)
+
+// Provider describes an OAuth 2.0 provider. The user profile is read with `NewClientUtil` if
+// set, otherwise by requesting `UserinfoURL` and converting the response with `MapProfile`.
+type Provider struct {
+	// Name is the lower case provider key, e.g. `github`, used in `O2ConfigMore.Provider`.
+	Name string
+	// Endpoint returns the endpoint for a subdomain or hostname, which is empty for providers
+	// with a fixed endpoint.
+	Endpoint      func(subdomain string) (oauth2.Endpoint, error)
+	DefaultScopes []string
+	NewClientUtil func() authutil.OAuth2Util
+	UserinfoURL   string
+	MapProfile    ProfileMapper
+}
+
+// ServiceEndpoint returns an endpoint factory for a service in the `endpoints` package.
+func ServiceEndpoint(serviceName string) func(subdomain string) (oauth2.Endpoint, error) {
+	return func(subdomain string) (oauth2.Endpoint, error) {
+		ep, _, err := endpoints.NewEndpoint(serviceName, subdomain)
+		return ep, err
+	}
+}
+
+// ClientUtil returns the `authutil.OAuth2Util` which reads the user profile, or nil if the
+// provider has neither `NewClientUtil` nor `UserinfoURL` and `MapProfile`.
+func (p *Provider) ClientUtil() authutil.OAuth2Util {
+	if p.NewClientUtil != nil {
+		return p.NewClientUtil()
+	} else if p.UserinfoURL != "" && p.MapProfile != nil {
+		return &userinfoUtil{url: p.UserinfoURL, mapProfile: p.MapProfile}
+	}
+	return nil
+}
+
+// userinfoUtil is an `authutil.OAuth2Util` which reads a JSON userinfo endpoint.
+type userinfoUtil struct {
+	client     *http.Client
+	url        string
+	mapProfile ProfileMapper
+}
+
+func (u *userinfoUtil) SetClient(client *http.Client) { u.client = client }
+
+func (u *userinfoUtil) GetSCIMUser() (scim.User, error) {
+	if u.client == nil {
+		return scim.User{}, errors.New("userinfo client not set")
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u.url, nil)
+	if err != nil {
+		return scim.User{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return scim.User{}, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return scim.User{}, err
+	} else if resp.StatusCode >= 300 {
+		return scim.User{}, fmt.Errorf("userinfo status code [%d]", resp.StatusCode)
+	}
+	return u.mapProfile(data)
+}
+
+// Registry is a set of providers by name.
+type Registry struct {
+	mu        sync.RWMutex
+	providers map[string]*Provider
+}
+
+func NewRegistry() *Registry {
+	return &Registry{providers: map[string]*Provider{}}
+}
+
+// Register adds a provider. The name is lower cased.
+func (r *Registry) Register(p Provider) error {
+	p.Name = strings.ToLower(strings.TrimSpace(p.Name))
+	if p.Name == "" {
+		return errors.New("provider name is required")
+	}
+	p.DefaultScopes = slices.Clone(p.DefaultScopes)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.providers[p.Name]; ok {
+		return fmt.Errorf("%w (%s)", ErrDuplicate, p.Name)
+	} else if r.providers == nil {
+		r.providers = map[string]*Provider{}
+	}
+	r.providers[p.Name] = &p
+	return nil
+}
+
+// Get returns the provider for a name, ignoring case.
+func (r *Registry) Get(name string) (*Provider, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if p, ok := r.providers[key]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("%w (%s)", ErrNotFound, key)
+}
+
+// Names returns the sorted provider names.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var names []string
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Default is the registry used by the package functions and `multiservice.OAuth2Manager`.
+var Default = NewRegistry()
+
+// Register adds a provider to `Default`.
+func Register(p Provider) error { return Default.Register(p) }
+
+// MustRegister adds a provider to `Default` and panics on error, e.g. in `init()`.
+func MustRegister(p Provider) {
+	if err := Default.Register(p); err != nil {
+		panic(err)
+	}
+}
+
+// Get returns a provider from `Default`.
+func Get(name string) (*Provider, error) { return Default.Get(name) }
+
+// Names returns the provider names in `Default`.
+func Names() []string { return Default.Names() }
+
+// UnmarshalProfile returns a `ProfileMapper` which decodes the response into `T` and converts
+// it with `fn`.
+func UnmarshalProfile[T any](fn func(T) scim.User) ProfileMapper {
+	return func(data []byte) (scim.User, error) {
+		var v T
+		if err := json.Unmarshal(data, &v); err != nil {
+			return scim.User{}, err
+		}
+		return fn(v), nil
+	}
+}
@@ -0,0 +1,73 @@
+package providers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grokify/goauth/endpoints"
+	"github.com/grokify/goauth/scim"
+)
+
+type githubUser struct {
+	ID    int    `json:"id"`
+	Login string `json:"login"`
+	Email string `json:"email"`
+}
+
+func TestRegistry(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer at" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":7,"login":"octocat","email":"octocat@example.com"}`))
+	}))
+	defer svr.Close()
+
+	reg := NewRegistry()
+	github := Provider{
+		Name:          "GitHub",
+		Endpoint:      ServiceEndpoint(endpoints.ServiceGithub),
+		DefaultScopes: []string{"read:user", "user:email"},
+		UserinfoURL:   svr.URL,
+		MapProfile: UnmarshalProfile(func(u githubUser) scim.User {
+			return scim.User{ID: u.Login, UserName: u.Login}
+		})}
+	if err := reg.Register(github); err != nil {
+		t.Fatalf("Registry.Register(github): err [%v]", err)
+	} else if err := reg.Register(github); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Registry.Register(github) again: want [%v], got [%v]", ErrDuplicate, err)
+	}
+	if _, err := reg.Get("microsoft"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Registry.Get(microsoft): want [%v], got [%v]", ErrNotFound, err)
+	}
+	p, err := reg.Get(" github ")
+	if err != nil {
+		t.Fatalf("Registry.Get(github): err [%v]", err)
+	} else if ep, err := p.Endpoint(""); err != nil || ep.TokenURL != endpoints.GithubTokenURL {
+		t.Errorf("Provider.Endpoint(): want [%s], got [%s] err [%v]", endpoints.GithubTokenURL, ep.TokenURL, err)
+	}
+
+	util := p.ClientUtil()
+	util.SetClient(&http.Client{Transport: bearer("at")})
+	if user, err := util.GetSCIMUser(); err != nil || user.ID != "octocat" {
+		t.Errorf("Provider.ClientUtil().GetSCIMUser(): want [octocat], got [%v] err [%v]", user.ID, err)
+	}
+	util.SetClient(&http.Client{Transport: bearer("bad")})
+	if _, err := util.GetSCIMUser(); err == nil {
+		t.Errorf("Provider.ClientUtil().GetSCIMUser() unauthorized: want error, got nil")
+	}
+	if names := reg.Names(); len(names) != 1 || names[0] != "github" {
+		t.Errorf("Registry.Names(): want [[github]], got [%v]", names)
+	}
+}
+
+type bearer string
+
+func (b bearer) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+string(b))
+	return http.DefaultTransport.RoundTrip(r)
+}
@@ -1,7 +1,11 @@
 package multiservice
 
 import (
+	"errors"
 	"testing"
+
+	"github.com/grokify/goauth/endpoints"
+	"github.com/grokify/goauth/multiservice/providers"
 )
 
 var providerTests = []struct {
@@ -36,3 +40,23 @@ func TestProvidersReverse(t *testing.T) {
 		}
 	}
 }
+
+func TestProviderRegistry(t *testing.T) {
+	cfg, err := NewO2ConfigMoreFromJSON([]byte(`{"provider":"Facebook","client_id":"id"}`))
+	if err != nil {
+		t.Fatalf("NewO2ConfigMoreFromJSON(facebook): err [%v]", err)
+	} else if cfg.AuthURI != endpoints.FacebookAuthzURL || len(cfg.Scopes) != 2 {
+		t.Errorf("NewO2ConfigMoreFromJSON(facebook): want registered defaults, got [%v] [%v]", cfg.AuthURI, cfg.Scopes)
+	}
+	for _, name := range []string{"aha", "facebook", "google", "ringcentral"} {
+		if util, err := NewClientUtilForProviderTypeString(name); err != nil || util == nil {
+			t.Errorf("NewClientUtilForProviderTypeString(%s): want ClientUtil, got err [%v]", name, err)
+		}
+	}
+	if _, err := NewClientUtilForProviderTypeString("unknown"); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("NewClientUtilForProviderTypeString(unknown): want [%v], got [%v]", providers.ErrNotFound, err)
+	}
+	if s := OAuth2Provider(99).String(); s != "OAuth2Provider(99)" {
+		t.Errorf("OAuth2Provider(99).String(): want [OAuth2Provider(99)], got [%s]", s)
+	}
+}
@@ -0,0 +1,287 @@
+package tokens
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	// EnvelopeVersion is the format version written to `Envelope.V`.
+	EnvelopeVersion = 1
+
+	dataKeyLength = 32
+)
+
+var (
+	// ErrKeyNotFound is returned when a token is encrypted with a key ID not in the keyring.
+	ErrKeyNotFound = errors.New("encryption key not found")
+	// ErrNotEncrypted is returned by `EncryptedTokenSet` when a stored token is not encrypted
+	// and `AllowPlaintext` is not set.
+	ErrNotEncrypted = errors.New("token is not encrypted")
+	// ErrDecrypt is returned when a token cannot be decrypted, e.g. because it was stored under
+	// another key or modified.
+	ErrDecrypt = errors.New("token decryption failed")
+)
+
+// Envelope is an encrypted `TokenInfo`. The token info is encrypted with a random data key
+// using AES-GCM, and the data key is encrypted with the keyring key `KeyID`. Both use the token
+// key as associated data, so an envelope only decrypts under the key it was written for.
+type Envelope struct {
+	V          int    `json:"v"`
+	KeyID      string `json:"kid"`
+	WrappedKey []byte `json:"wrappedKey"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Keyring holds AES key encryption keys by ID, e.g. `v1`, `v2`. New tokens are encrypted with
+// `Primary`, and other keys are kept to decrypt tokens written before a rotation.
+type Keyring struct {
+	Primary string
+	Keys    map[string][]byte
+}
+
+// NewKeyring returns a keyring which encrypts with `primary`. Keys must be 16, 24 or 32 bytes
+// for AES-128, AES-192 or AES-256.
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("%w: primary key id (%s)", ErrKeyNotFound, primary)
+	}
+	for id, key := range keys {
+		if id == "" {
+			return nil, errors.New("encryption key id is required")
+		} else if _, err := aes.NewCipher(key); err != nil {
+			return nil, fmt.Errorf("encryption key (%s): %w", id, err)
+		}
+	}
+	return &Keyring{Primary: primary, Keys: keys}, nil
+}
+
+func (kr *Keyring) aead(keyID string) (cipher.AEAD, error) {
+	key, ok := kr.Keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w (%s)", ErrKeyNotFound, keyID)
+	}
+	return newAEAD(key)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt returns an envelope for `ti` stored under the token key `key`, using the primary key.
+func (kr *Keyring) Encrypt(key string, ti *TokenInfo) (*Envelope, error) {
+	kek, err := kr.aead(kr.Primary)
+	if err != nil {
+		return nil, err
+	}
+	plain := ti.Clone()
+	if plain == nil {
+		plain = &TokenInfo{}
+	}
+	plain.Version = 0
+	plain.Encrypted = nil
+	data, err := json.Marshal(plain)
+	if err != nil {
+		return nil, err
+	}
+	dataKey := make([]byte, dataKeyLength)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	dek, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		V:          EnvelopeVersion,
+		KeyID:      kr.Primary,
+		WrappedKey: seal(kek, dataKey, associatedData("key", kr.Primary, key)),
+		Ciphertext: seal(dek, data, associatedData("token", kr.Primary, key))}, nil
+}
+
+// Decrypt returns the token info in `env`, which must have been encrypted for `key`.
+func (kr *Keyring) Decrypt(key string, env *Envelope) (*TokenInfo, error) {
+	if env == nil {
+		return nil, ErrNotEncrypted
+	} else if env.V != EnvelopeVersion {
+		return nil, fmt.Errorf("%w: unsupported envelope version [%d]", ErrDecrypt, env.V)
+	}
+	kek, err := kr.aead(env.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := open(kek, env.WrappedKey, associatedData("key", env.KeyID, key))
+	if err != nil {
+		return nil, err
+	}
+	dek, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := open(dek, env.Ciphertext, associatedData("token", env.KeyID, key))
+	if err != nil {
+		return nil, err
+	}
+	return ParseTokenInfo(data)
+}
+
+func associatedData(kind, keyID, key string) []byte {
+	return []byte("goauth/tokens/" + kind + "\x00" + keyID + "\x00" + key)
+}
+
+// seal returns the nonce followed by the ciphertext.
+func seal(aead cipher.AEAD, plaintext, ad []byte) []byte {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err) // `crypto/rand.Read` does not return errors.
+	}
+	return aead.Seal(nonce, nonce, plaintext, ad)
+}
+
+func open(aead cipher.AEAD, ciphertext, ad []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrDecrypt)
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, ad)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecrypt, err)
+	}
+	return plaintext, nil
+}
+
+// EncryptedTokenSet is a `TokenSet` which stores token info in `inner` as an `Envelope`. Only
+// `ServiceKey` and `ServiceType` are stored in plaintext, so backends can index them.
+//
+// Tokens read which are encrypted with a key other than the keyring's primary key are
+// re-encrypted with it, so rotating keys only requires a new primary key, with the old key kept
+// until all tokens have been read. Re-encryption is skipped if another writer changes the token
+// first.
+type EncryptedTokenSet struct {
+	inner   TokenSet
+	keyring *Keyring
+	// AllowPlaintext reads tokens which are not encrypted, e.g. when migrating an existing token
+	// set, and encrypts them like tokens under an old key.
+	AllowPlaintext bool
+}
+
+// NewEncryptedTokenSet returns a token set which encrypts token info stored in `inner`.
+func NewEncryptedTokenSet(inner TokenSet, keyring *Keyring) *EncryptedTokenSet {
+	return &EncryptedTokenSet{inner: inner, keyring: keyring}
+}
+
+func (toks *EncryptedTokenSet) GetTokenInfo(ctx context.Context, key string) (*TokenInfo, error) {
+	key = FormatKey(key)
+	stored, err := toks.inner.GetTokenInfo(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	ti, err := toks.decrypt(key, stored)
+	if err != nil {
+		return nil, err
+	} else if stored.Encrypted != nil && stored.Encrypted.KeyID == toks.keyring.Primary {
+		return ti, nil
+	}
+	// Re-encrypt with the primary key, and read back the new version and update time.
+	if err := toks.CompareAndSwap(ctx, key, stored, ti); err != nil {
+		return ti, nil
+	} else if stored, err = toks.inner.GetTokenInfo(ctx, key); err != nil {
+		return ti, nil
+	} else if next, err := toks.decrypt(key, stored); err == nil {
+		return next, nil
+	}
+	return ti, nil
+}
+
+// decrypt returns the token info in `stored` with its version and update time.
+func (toks *EncryptedTokenSet) decrypt(key string, stored *TokenInfo) (*TokenInfo, error) {
+	if stored.Encrypted == nil {
+		if !toks.AllowPlaintext {
+			return nil, fmt.Errorf("%w (%s)", ErrNotEncrypted, key)
+		}
+		return stored.Clone(), nil
+	}
+	ti, err := toks.keyring.Decrypt(key, stored.Encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("%w (%s)", err, key)
+	}
+	ti.Version = stored.Version
+	ti.UpdatedAt = stored.UpdatedAt
+	return ti, nil
+}
+
+func (toks *EncryptedTokenSet) encrypt(key string, ti *TokenInfo) (*TokenInfo, error) {
+	env, err := toks.keyring.Encrypt(key, ti)
+	if err != nil {
+		return nil, err
+	}
+	out := &TokenInfo{Encrypted: env}
+	if ti != nil {
+		out.ServiceKey = ti.ServiceKey
+		out.ServiceType = ti.ServiceType
+	}
+	return out, nil
+}
+
+func (toks *EncryptedTokenSet) SetTokenInfo(ctx context.Context, key string, ti *TokenInfo) error {
+	key = FormatKey(key)
+	if key == "" {
+		return ErrKeyRequired
+	}
+	enc, err := toks.encrypt(key, ti)
+	if err != nil {
+		return err
+	}
+	return toks.inner.SetTokenInfo(ctx, key, enc)
+}
+
+func (toks *EncryptedTokenSet) CompareAndSwap(ctx context.Context, key string, old, ti *TokenInfo) error {
+	key = FormatKey(key)
+	if key == "" {
+		return ErrKeyRequired
+	}
+	enc, err := toks.encrypt(key, ti)
+	if err != nil {
+		return err
+	}
+	return toks.inner.CompareAndSwap(ctx, key, old, enc)
+}
+
+func (toks *EncryptedTokenSet) Delete(ctx context.Context, key string) error {
+	return toks.inner.Delete(ctx, key)
+}
+
+func (toks *EncryptedTokenSet) List(ctx context.Context, prefix string) ([]string, error) {
+	return toks.inner.List(ctx, prefix)
+}
+
+// Lock uses the inner token set's lock if it implements `Locker`, so `TokenSource` refreshes
+// are coordinated the same way as with the inner token set.
+func (toks *EncryptedTokenSet) Lock(ctx context.Context, key string) (func(), error) {
+	if l, ok := toks.inner.(Locker); ok {
+		return l.Lock(ctx, key)
+	}
+	return localLocker.Lock(ctx, key)
+}
+
+// TryLock uses the inner token set's lock if it implements `TryLocker`, or the lock used by
+// `Lock()` if the inner token set does not implement `Locker`. If the inner token set only
+// implements `Locker`, which cannot be tried without waiting, `ok` is always false, so
+// `SharedTokenSource` uses the stored token until it expires and then waits for `Lock()`.
+func (toks *EncryptedTokenSet) TryLock(ctx context.Context, key string) (func(), bool, error) {
+	if l, ok := toks.inner.(TryLocker); ok {
+		return l.TryLock(ctx, key)
+	} else if _, ok := toks.inner.(Locker); !ok {
+		return localLocker.TryLock(ctx, key)
+	}
+	return nil, false, ctx.Err()
+}
@@ -0,0 +1,133 @@
+package tokens_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grokify/goauth/multiservice/tokens"
+	"github.com/grokify/goauth/multiservice/tokens/tokensetmemory"
+	"github.com/grokify/goauth/multiservice/tokens/tokenstest"
+	"golang.org/x/oauth2"
+)
+
+func newTestKeyring(t *testing.T, primary string) *tokens.Keyring {
+	kr, err := tokens.NewKeyring(primary, map[string][]byte{
+		"v1": bytes.Repeat([]byte{1}, 32),
+		"v2": bytes.Repeat([]byte{2}, 32)})
+	if err != nil {
+		t.Fatalf("tokens.NewKeyring(%s): err [%v]", primary, err)
+	}
+	return kr
+}
+
+func TestEncryptedTokenSet(t *testing.T) {
+	tokenstest.TestTokenSet(t, func(t *testing.T) tokens.TokenSet {
+		return tokens.NewEncryptedTokenSet(tokensetmemory.NewTokenSet(), newTestKeyring(t, "v1"))
+	})
+}
+
+func TestEncryptedTokenSetStorage(t *testing.T) {
+	ctx := context.Background()
+	inner := tokensetmemory.NewTokenSet()
+	ts := tokens.NewEncryptedTokenSet(inner, newTestKeyring(t, "v1"))
+	for _, key := range []string{"user-1", "user-2"} {
+		if err := ts.SetTokenInfo(ctx, key, &tokens.TokenInfo{ServiceKey: "svc", Token: &oauth2.Token{RefreshToken: "secret-" + key}}); err != nil {
+			t.Fatalf("EncryptedTokenSet.SetTokenInfo(%s): err [%v]", key, err)
+		}
+	}
+	stored, err := inner.GetTokenInfo(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("TokenSet.GetTokenInfo(user-1): err [%v]", err)
+	}
+	if b, _ := json.Marshal(stored); bytes.Contains(b, []byte("secret")) || stored.Token != nil || stored.Encrypted.KeyID != "v1" {
+		t.Errorf("EncryptedTokenSet.SetTokenInfo(user-1): want encrypted with [v1], got [%s]", b)
+	}
+
+	// An entry copied to another user's key does not decrypt.
+	if err := inner.SetTokenInfo(ctx, "user-2", stored); err != nil {
+		t.Fatalf("TokenSet.SetTokenInfo(user-2): err [%v]", err)
+	} else if _, err := ts.GetTokenInfo(ctx, "user-2"); !errors.Is(err, tokens.ErrDecrypt) {
+		t.Errorf("EncryptedTokenSet.GetTokenInfo(user-2) swapped: want [%v], got [%v]", tokens.ErrDecrypt, err)
+	}
+
+	// Plaintext entries are only read with `AllowPlaintext`.
+	if err := inner.SetTokenInfo(ctx, "plain", &tokens.TokenInfo{Token: &oauth2.Token{AccessToken: "a"}}); err != nil {
+		t.Fatalf("TokenSet.SetTokenInfo(plain): err [%v]", err)
+	} else if _, err := ts.GetTokenInfo(ctx, "plain"); !errors.Is(err, tokens.ErrNotEncrypted) {
+		t.Errorf("EncryptedTokenSet.GetTokenInfo(plain): want [%v], got [%v]", tokens.ErrNotEncrypted, err)
+	}
+	ts.AllowPlaintext = true
+	if tok, err := tokens.GetToken(ctx, ts, "plain"); err != nil || tok.AccessToken != "a" {
+		t.Errorf("EncryptedTokenSet.GetTokenInfo(plain): want [a], got [%v] err [%v]", tok, err)
+	} else if stored, err := inner.GetTokenInfo(ctx, "plain"); err != nil || stored.Encrypted == nil {
+		t.Errorf("EncryptedTokenSet.GetTokenInfo(plain): want re-encrypted, got [%v] err [%v]", stored, err)
+	}
+}
+
+func TestEncryptedTokenSetRotation(t *testing.T) {
+	ctx := context.Background()
+	inner := tokensetmemory.NewTokenSet()
+	if err := tokens.NewEncryptedTokenSet(inner, newTestKeyring(t, "v1")).SetTokenInfo(ctx, "k",
+		&tokens.TokenInfo{Token: &oauth2.Token{AccessToken: "a"}}); err != nil {
+		t.Fatalf("EncryptedTokenSet.SetTokenInfo(k): err [%v]", err)
+	}
+	ts := tokens.NewEncryptedTokenSet(inner, newTestKeyring(t, "v2"))
+	got, err := ts.GetTokenInfo(ctx, "k")
+	if err != nil || got.Token.AccessToken != "a" {
+		t.Fatalf("EncryptedTokenSet.GetTokenInfo(k): want [a], got [%v] err [%v]", got, err)
+	}
+	stored, err := inner.GetTokenInfo(ctx, "k")
+	if err != nil || stored.Encrypted.KeyID != "v2" || stored.Version != got.Version {
+		t.Errorf("EncryptedTokenSet.GetTokenInfo(k): want re-encrypted with [v2] at version [%d], got [%v] err [%v]", got.Version, stored, err)
+	}
+	if _, err := tokens.NewEncryptedTokenSet(inner, &tokens.Keyring{Primary: "v1", Keys: map[string][]byte{"v1": bytes.Repeat([]byte{1}, 32)}}).
+		GetTokenInfo(ctx, "k"); !errors.Is(err, tokens.ErrKeyNotFound) {
+		t.Errorf("EncryptedTokenSet.GetTokenInfo(k) without v2: want [%v], got [%v]", tokens.ErrKeyNotFound, err)
+	}
+}
+
+func TestNewKeyring(t *testing.T) {
+	if _, err := tokens.NewKeyring("v3", map[string][]byte{"v1": make([]byte, 32)}); !errors.Is(err, tokens.ErrKeyNotFound) {
+		t.Errorf("tokens.NewKeyring(v3): want [%v], got [%v]", tokens.ErrKeyNotFound, err)
+	}
+	if _, err := tokens.NewKeyring("v1", map[string][]byte{"v1": make([]byte, 10)}); err == nil {
+		t.Errorf("tokens.NewKeyring(10 byte key): want error, got nil")
+	}
+}
+
+// lockOnlyTokenSet is a token set which implements `Locker` but not `TryLocker`.
+type lockOnlyTokenSet struct {
+	tokens.TokenSet
+	locker tokens.LocalLocker
+}
+
+func (ts *lockOnlyTokenSet) Lock(ctx context.Context, key string) (func(), error) {
+	return ts.locker.Lock(ctx, key)
+}
+
+func TestEncryptedTokenSetTryLock(t *testing.T) {
+	inner := &lockOnlyTokenSet{TokenSet: tokensetmemory.NewTokenSet()}
+	ts := tokens.NewEncryptedTokenSet(inner, newTestKeyring(t, "v1"))
+	unlock, err := ts.Lock(context.Background(), "k")
+	if err != nil {
+		t.Fatalf("EncryptedTokenSet.Lock(k): err [%v]", err)
+	}
+	defer unlock()
+	done := make(chan bool, 1)
+	go func() {
+		_, ok, _ := ts.TryLock(context.Background(), "k")
+		done <- ok
+	}()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Errorf("EncryptedTokenSet.TryLock(k) while locked: want [false], got [true]")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("EncryptedTokenSet.TryLock(k) while locked: want no wait, got blocked")
+	}
+}
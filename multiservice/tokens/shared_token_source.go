@@ -0,0 +1,188 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// DefaultRefreshBefore is how long before expiry `SharedTokenSource` fetches a new token.
+const DefaultRefreshBefore = time.Minute
+
+// TryLocker is a `Locker` which can also acquire a lock without waiting. `ok` is false if the
+// lock is held by another process.
+type TryLocker interface {
+	Locker
+	TryLock(ctx context.Context, key string) (unlock func(), ok bool, err error)
+}
+
+func (l *LocalLocker) TryLock(ctx context.Context, key string) (func(), bool, error) {
+	ch := l.lock(key)
+	select {
+	case ch <- struct{}{}:
+		return func() { <-ch }, true, nil
+	default:
+		return nil, false, ctx.Err()
+	}
+}
+
+// SharedTokenSource is an `oauth2.TokenSource` for a token shared by replicas through
+// `TokenSet`, e.g. a client credentials token, so replicas do not each request tokens. The
+// token is fetched with `Fetch` within `RefreshBefore` of expiry by one replica holding
+// `Locker`, e.g. a Redis lease from `tokensetredis`, and stored for the others to read. While
+// the stored token is still valid, replicas which do not get the lock return it instead of
+// waiting. Within a replica, one goroutine reads or fetches at a time and the token is cached
+// in memory.
+type SharedTokenSource struct {
+	TokenSet TokenSet
+	Key      string
+	// Fetch requests a new token, e.g. `clientcredentials.Config.Token`. It must not return a
+	// cached token.
+	Fetch func(ctx context.Context) (*oauth2.Token, error)
+	// Locker defaults to `TokenSet` if it implements `Locker`, otherwise to a `LocalLocker`.
+	Locker Locker
+	// RefreshBefore defaults to `DefaultRefreshBefore`.
+	RefreshBefore time.Duration
+	// ExtraKeys are the token response fields stored with fetched tokens. See `NewTokenInfo()`.
+	ExtraKeys []string
+	ctx       context.Context
+
+	mu     sync.Mutex
+	tok    *oauth2.Token
+	flight sync.Mutex
+}
+
+// NewSharedTokenSource returns a `SharedTokenSource` for the token stored under `key`. `ctx`
+// is used for token set, lock and fetch requests.
+func NewSharedTokenSource(ctx context.Context, fetch func(context.Context) (*oauth2.Token, error), ts TokenSet, key string) *SharedTokenSource {
+	return &SharedTokenSource{TokenSet: ts, Key: key, Fetch: fetch, ctx: ctx}
+}
+
+func (s *SharedTokenSource) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+func (s *SharedTokenSource) locker() Locker {
+	if s.Locker != nil {
+		return s.Locker
+	} else if l, ok := s.TokenSet.(Locker); ok {
+		return l
+	}
+	return localLocker
+}
+
+// fresh returns true if `tok` does not need to be fetched yet.
+func (s *SharedTokenSource) fresh(tok *oauth2.Token) bool {
+	if tok == nil || tok.AccessToken == "" {
+		return false
+	} else if tok.Expiry.IsZero() {
+		return true
+	}
+	refreshBefore := s.RefreshBefore
+	if refreshBefore <= 0 {
+		refreshBefore = DefaultRefreshBefore
+	}
+	return time.Until(tok.Expiry) > refreshBefore
+}
+
+// Token returns the cached or stored token if it is not within `RefreshBefore` of expiry,
+// otherwise fetches and stores a new one.
+func (s *SharedTokenSource) Token() (*oauth2.Token, error) {
+	if s.TokenSet == nil {
+		return nil, errors.New("shared token source token set is required")
+	} else if s.Fetch == nil {
+		return nil, errors.New("shared token source fetch func is required")
+	}
+	s.mu.Lock()
+	tok := s.tok
+	s.mu.Unlock()
+	if s.fresh(tok) {
+		return tok, nil
+	}
+	// Local single flight: while another goroutine reads or fetches, use the cached token if
+	// it is still valid, otherwise wait for the result.
+	if !s.flight.TryLock() {
+		if tok.Valid() {
+			return tok, nil
+		}
+		s.flight.Lock()
+	}
+	defer s.flight.Unlock()
+	s.mu.Lock()
+	tok = s.tok
+	s.mu.Unlock()
+	if s.fresh(tok) {
+		return tok, nil
+	}
+	tok, err := s.shared(s.context())
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.tok = tok
+	s.mu.Unlock()
+	return tok, nil
+}
+
+// shared returns the stored token, or fetches and stores a new one holding the lock.
+func (s *SharedTokenSource) shared(ctx context.Context) (*oauth2.Token, error) {
+	cur, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stored := cur.OAuth2Token()
+	if s.fresh(stored) {
+		return stored, nil
+	}
+	var unlock func()
+	if tl, ok := s.locker().(TryLocker); ok && stored.Valid() {
+		// Another replica is fetching. The stored token can be used until it has.
+		if unlock, ok, err = tl.TryLock(ctx, FormatKey(s.Key)); err != nil {
+			return nil, err
+		} else if !ok {
+			return stored, nil
+		}
+	} else if unlock, err = s.locker().Lock(ctx, FormatKey(s.Key)); err != nil {
+		return nil, err
+	}
+	defer unlock()
+	// Another replica may have stored a token while this one waited for the lock.
+	if cur, err = s.get(ctx); err != nil {
+		return nil, err
+	} else if stored = cur.OAuth2Token(); s.fresh(stored) {
+		return stored, nil
+	}
+	tok, err := s.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	} else if tok == nil {
+		return nil, errors.New("shared token source fetch returned no token")
+	}
+	next := NewTokenInfo(s.Key, "", tok, s.ExtraKeys...)
+	if cur != nil {
+		next.ServiceKey = cur.ServiceKey
+		next.ServiceType = cur.ServiceType
+		next.Metadata = cur.Metadata
+	}
+	// A conflict means a writer which does not share the lock stored a token after this one
+	// was read. Keep its token, and return the fetched one which is also valid.
+	if err := s.TokenSet.CompareAndSwap(ctx, s.Key, cur, next); err != nil && !errors.Is(err, ErrConflict) {
+		return nil, err
+	}
+	return next.OAuth2Token(), nil
+}
+
+// get returns the stored token info, or nil if there is none.
+func (s *SharedTokenSource) get(ctx context.Context) (*TokenInfo, error) {
+	cur, err := s.TokenSet.GetTokenInfo(ctx, s.Key)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return cur, err
+}
@@ -0,0 +1,93 @@
+package tokens_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/grokify/goauth/multiservice/tokens"
+	"github.com/grokify/goauth/multiservice/tokens/tokensetmemory"
+	"github.com/grokify/goauth/multiservice/tokens/tokensetredis"
+)
+
+func TestSharedTokenSource(t *testing.T) {
+	testSharedTokenSource(t, tokensetmemory.NewTokenSet())
+}
+
+// TestSharedTokenSourceRedis runs the replicas against Redis, which locks with `SET NX` rather
+// than within the process.
+func TestSharedTokenSourceRedis(t *testing.T) {
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { client.Close() })
+	testSharedTokenSource(t, tokensetredis.NewTokenSet(client))
+}
+
+func testSharedTokenSource(t *testing.T, ts tokens.TokenSet) {
+	ctx := context.Background()
+	var fetches atomic.Int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := strconv.Itoa(int(fetches.Add(1)))
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"at-` + n + `","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer svr.Close()
+	conf := &clientcredentials.Config{ClientID: "id", ClientSecret: "secret", TokenURL: svr.URL}
+
+	// Replicas, each with several goroutines, share the token set.
+	replicas := make([]*tokens.SharedTokenSource, 4)
+	for i := range replicas {
+		replicas[i] = tokens.NewSharedTokenSource(ctx, conf.Token, ts, "svc")
+	}
+	run := func(want string) {
+		var wg sync.WaitGroup
+		for _, src := range replicas {
+			for range 4 {
+				wg.Go(func() {
+					if tok, err := src.Token(); err != nil {
+						t.Errorf("SharedTokenSource.Token(): err [%v]", err)
+					} else if want != "" && tok.AccessToken != want {
+						t.Errorf("SharedTokenSource.Token(): want [%s], got [%s]", want, tok.AccessToken)
+					}
+				})
+			}
+		}
+		wg.Wait()
+	}
+	run("at-1")
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("SharedTokenSource.Token(): want [1] fetch, got [%d]", n)
+	}
+
+	// Within `RefreshBefore` of expiry one replica fetches, and the others use the valid token
+	// or read the new one.
+	if err := ts.SetTokenInfo(ctx, "svc", &tokens.TokenInfo{
+		Token: &oauth2.Token{AccessToken: "at-1", TokenType: "Bearer", Expiry: time.Now().Add(30 * time.Second)}}); err != nil {
+		t.Fatalf("TokenSet.SetTokenInfo(svc): err [%v]", err)
+	}
+	for i := range replicas {
+		replicas[i] = tokens.NewSharedTokenSource(ctx, conf.Token, ts, "svc")
+	}
+	run("")
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("SharedTokenSource.Token() before expiry: want [2] fetches, got [%d]", n)
+	}
+	// Goroutines reading while another reads the store may still get the valid cached token,
+	// so check the stored token is read one call at a time.
+	for _, src := range replicas {
+		if tok, err := src.Token(); err != nil || tok.AccessToken != "at-2" {
+			t.Errorf("SharedTokenSource.Token() after refresh: want [at-2], got [%v] err [%v]", tok, err)
+		}
+	}
+}
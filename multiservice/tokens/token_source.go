@@ -0,0 +1,156 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// Locker acquires an exclusive lock by key, e.g. so only one process refreshes a token. The
+// returned function releases the lock. Token sets shared between processes implement it with
+// a lock which is shared too.
+type Locker interface {
+	Lock(ctx context.Context, key string) (unlock func(), err error)
+}
+
+// LocalLocker is a `Locker` for goroutines in one process.
+type LocalLocker struct {
+	mu    sync.Mutex
+	locks map[string]chan struct{}
+}
+
+func (l *LocalLocker) Lock(ctx context.Context, key string) (func(), error) {
+	ch := l.lock(key)
+	select {
+	case ch <- struct{}{}:
+		return func() { <-ch }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// lock returns the channel for a key, which holds a value while the lock is held.
+func (l *LocalLocker) lock(key string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.locks == nil {
+		l.locks = map[string]chan struct{}{}
+	}
+	ch, ok := l.locks[key]
+	if !ok {
+		ch = make(chan struct{}, 1)
+		l.locks[key] = ch
+	}
+	return ch
+}
+
+// localLocker is used by `TokenSource` when the token set does not implement `Locker`.
+var localLocker = &LocalLocker{}
+
+// TokenSource is an `oauth2.TokenSource` which reads the token for `Key` from `TokenSet`, and
+// refreshes it with `Config` when expired. Each refreshed token is written back with
+// `TokenSet.CompareAndSwap()`, so providers which rotate refresh tokens on every refresh keep
+// working. Refreshes are made holding `Locker`, and the token is read again after locking, so
+// processes sharing the lock do not spend the same refresh token.
+type TokenSource struct {
+	TokenSet TokenSet
+	Key      string
+	Config   *oauth2.Config
+	// Locker defaults to `TokenSet` if it implements `Locker`, otherwise to a `LocalLocker`.
+	Locker Locker
+	// ExtraKeys are the token response fields stored with refreshed tokens. See `NewTokenInfo()`.
+	ExtraKeys []string
+	ctx       context.Context
+}
+
+// NewTokenSource returns a `TokenSource` for the token stored under `key`. `ctx` is used for
+// token set, lock and refresh requests.
+func NewTokenSource(ctx context.Context, conf *oauth2.Config, ts TokenSet, key string) *TokenSource {
+	return &TokenSource{TokenSet: ts, Key: key, Config: conf, ctx: ctx}
+}
+
+func (s *TokenSource) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+func (s *TokenSource) locker() Locker {
+	if s.Locker != nil {
+		return s.Locker
+	} else if l, ok := s.TokenSet.(Locker); ok {
+		return l
+	}
+	return localLocker
+}
+
+// Token returns the stored token if valid, otherwise refreshes and stores it.
+func (s *TokenSource) Token() (*oauth2.Token, error) {
+	ctx := s.context()
+	if s.TokenSet == nil {
+		return nil, errors.New("token source token set is required")
+	} else if s.Config == nil {
+		return nil, errors.New("token source oauth2 config is required")
+	}
+	if cur, err := s.TokenSet.GetTokenInfo(ctx, s.Key); err != nil {
+		return nil, err
+	} else if tok := cur.OAuth2Token(); tok.Valid() {
+		return tok, nil
+	}
+	unlock, err := s.locker().Lock(ctx, FormatKey(s.Key))
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	// Another process may have refreshed the token while this one waited for the lock.
+	cur, err := s.TokenSet.GetTokenInfo(ctx, s.Key)
+	if err != nil {
+		return nil, err
+	} else if tok := cur.OAuth2Token(); tok.Valid() {
+		return tok, nil
+	} else if tok == nil {
+		return nil, NotFoundError(FormatKey(s.Key))
+	}
+	tok, err := s.Config.TokenSource(ctx, cur.Token).Token()
+	if err != nil {
+		return nil, err
+	}
+	next := s.nextTokenInfo(cur, tok)
+	if err := s.TokenSet.CompareAndSwap(ctx, s.Key, cur, next); err == nil {
+		return next.OAuth2Token(), nil
+	} else if !errors.Is(err, ErrConflict) {
+		return nil, err
+	}
+	// A writer which does not share the lock stored a token after this one was read. Use its
+	// token if valid, otherwise store the refreshed one over it, so the refresh token returned
+	// by a provider which rotates them is not lost.
+	stored, err := Update(ctx, s.TokenSet, s.Key, func(cur *TokenInfo) (*TokenInfo, error) {
+		if cur == nil {
+			return nil, NotFoundError(FormatKey(s.Key))
+		} else if cur.OAuth2Token().Valid() {
+			return nil, nil
+		}
+		return s.nextTokenInfo(cur, tok), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored.OAuth2Token(), nil
+}
+
+// nextTokenInfo returns the token info to store for a token refreshed from `cur`.
+func (s *TokenSource) nextTokenInfo(cur *TokenInfo, tok *oauth2.Token) *TokenInfo {
+	next := NewTokenInfo(cur.ServiceKey, cur.ServiceType, tok, s.ExtraKeys...)
+	next.Metadata = cur.Metadata
+	// Keep extra fields, e.g. `id_token`, which are not returned on refresh.
+	if len(cur.Extra) > 0 {
+		extra := maps.Clone(cur.Extra)
+		maps.Copy(extra, next.Extra)
+		next.Extra = extra
+	}
+	return next
+}
@@ -0,0 +1,169 @@
+package tokens_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grokify/goauth/multiservice/tokens"
+	"github.com/grokify/goauth/multiservice/tokens/tokensetmemory"
+	"golang.org/x/oauth2"
+)
+
+// rotatingServer is a token endpoint which issues a new refresh token on each refresh and
+// only accepts the latest one.
+type rotatingServer struct {
+	mu       sync.Mutex
+	refreshN int
+}
+
+func (s *rotatingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.FormValue("refresh_token") != "rt-"+strconv.Itoa(s.refreshN) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+	s.refreshN++
+	n := strconv.Itoa(s.refreshN)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "at-" + n, "refresh_token": "rt-" + n, "token_type": "Bearer", "expires_in": 3600})
+}
+
+func TestTokenSource(t *testing.T) {
+	ctx := context.Background()
+	rs := &rotatingServer{}
+	svr := httptest.NewServer(rs)
+	defer svr.Close()
+	conf := &oauth2.Config{ClientID: "id", Endpoint: oauth2.Endpoint{TokenURL: svr.URL, AuthStyle: oauth2.AuthStyleInParams}}
+	ts := tokensetmemory.NewTokenSet()
+
+	for i := 1; i <= 2; i++ {
+		if err := ts.SetTokenInfo(ctx, "user-1", &tokens.TokenInfo{
+			Token:    &oauth2.Token{AccessToken: "expired", RefreshToken: "rt-" + strconv.Itoa(i-1), Expiry: time.Now().Add(-time.Minute)},
+			Extra:    map[string]any{"id_token": "id"},
+			Metadata: []byte(`{"user":"1"}`)}); err != nil {
+			t.Fatalf("TokenSet.SetTokenInfo(user-1): err [%v]", err)
+		}
+		// Sources for the same key, e.g. in several replicas, refresh once between them.
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Go(func() {
+				if tok, err := tokens.NewTokenSource(ctx, conf, ts, "user-1").Token(); err != nil {
+					t.Errorf("TokenSource.Token(): err [%v]", err)
+				} else if tok.AccessToken != "at-"+strconv.Itoa(i) {
+					t.Errorf("TokenSource.Token(): want [at-%d], got [%s]", i, tok.AccessToken)
+				}
+			})
+		}
+		wg.Wait()
+		if rs.refreshN != i {
+			t.Errorf("TokenSource.Token(): want [%d] refreshes, got [%d]", i, rs.refreshN)
+		}
+		stored, err := ts.GetTokenInfo(ctx, "user-1")
+		if err != nil || stored.Token.RefreshToken != "rt-"+strconv.Itoa(i) || stored.Extra["id_token"] != "id" ||
+			string(stored.Metadata) != `{"user":"1"}` {
+			t.Errorf("TokenSource.Token(): want stored [rt-%d] with extra and metadata, got [%v] err [%v]", i, stored, err)
+		}
+	}
+}
+
+// conflictTokenSet stores `interfere` before the first `CompareAndSwap()`, like a writer
+// which does not share the lock.
+type conflictTokenSet struct {
+	tokens.TokenSet
+	interfere *tokens.TokenInfo
+	once      sync.Once
+}
+
+func (ts *conflictTokenSet) CompareAndSwap(ctx context.Context, key string, old, tok *tokens.TokenInfo) error {
+	ts.once.Do(func() {
+		if err := ts.SetTokenInfo(ctx, key, ts.interfere); err != nil {
+			panic(err)
+		}
+	})
+	return ts.TokenSet.CompareAndSwap(ctx, key, old, tok)
+}
+
+func TestTokenSourceConflict(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		interfere    *oauth2.Token
+		wantAccess   string
+		wantRefresh  string
+		wantRefreshN int
+	}{
+		// A valid token stored by the other writer is used.
+		{&oauth2.Token{AccessToken: "other", RefreshToken: "rt-other", Expiry: time.Now().Add(time.Hour)}, "other", "rt-other", 1},
+		// An expired token is replaced by the refreshed one, so its refresh token is kept.
+		{&oauth2.Token{AccessToken: "other", RefreshToken: "rt-other", Expiry: time.Now().Add(-time.Minute)}, "at-1", "rt-1", 1},
+	}
+	for _, tt := range tests {
+		rs := &rotatingServer{}
+		svr := httptest.NewServer(rs)
+		conf := &oauth2.Config{ClientID: "id", Endpoint: oauth2.Endpoint{TokenURL: svr.URL, AuthStyle: oauth2.AuthStyleInParams}}
+		ts := &conflictTokenSet{TokenSet: tokensetmemory.NewTokenSet(), interfere: &tokens.TokenInfo{Token: tt.interfere}}
+		if err := ts.SetTokenInfo(ctx, "user-1", &tokens.TokenInfo{
+			Token: &oauth2.Token{AccessToken: "expired", RefreshToken: "rt-0", Expiry: time.Now().Add(-time.Minute)}}); err != nil {
+			t.Fatalf("TokenSet.SetTokenInfo(user-1): err [%v]", err)
+		}
+		tok, err := tokens.NewTokenSource(ctx, conf, ts, "user-1").Token()
+		svr.Close()
+		if err != nil || tok.AccessToken != tt.wantAccess {
+			t.Errorf("TokenSource.Token() conflict: want [%s], got [%v] err [%v]", tt.wantAccess, tok, err)
+		}
+		if stored, err := ts.GetTokenInfo(ctx, "user-1"); err != nil || stored.Token.AccessToken != tt.wantAccess ||
+			stored.Token.RefreshToken != tt.wantRefresh {
+			t.Errorf("TokenSource.Token() conflict: want stored [%s %s], got [%v] err [%v]", tt.wantAccess, tt.wantRefresh, stored, err)
+		}
+		if rs.refreshN != tt.wantRefreshN {
+			t.Errorf("TokenSource.Token() conflict: want [%d] refreshes, got [%d]", tt.wantRefreshN, rs.refreshN)
+		}
+	}
+}
+
+func TestLocalLocker(t *testing.T) {
+	l := &tokens.LocalLocker{}
+	unlock, err := l.Lock(context.Background(), "k")
+	if err != nil {
+		t.Fatalf("LocalLocker.Lock(k): err [%v]", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Lock(ctx, "k"); err == nil {
+		t.Errorf("LocalLocker.Lock(k) while locked: want error, got nil")
+	}
+	unlock()
+	if unlock, err := l.Lock(context.Background(), "k"); err != nil {
+		t.Errorf("LocalLocker.Lock(k) after unlock: err [%v]", err)
+	} else {
+		unlock()
+	}
+}
+
+func TestNewClientWithTokenSet(t *testing.T) {
+	ctx := context.Background()
+	ts := tokensetmemory.NewTokenSet()
+	if err := ts.SetTokenInfo(ctx, "user-1", &tokens.TokenInfo{
+		Token:    &oauth2.Token{AccessToken: "revoked", Expiry: time.Now().Add(time.Hour)},
+		Metadata: []byte(`{"user":"1"}`)}); err != nil {
+		t.Fatalf("TokenSet.SetTokenInfo(user-1): err [%v]", err)
+	}
+	// A token from a new authorization replaces the stored one.
+	tok := &oauth2.Token{AccessToken: "new", Expiry: time.Now().Add(time.Hour)}
+	if _, err := tokens.NewClientWithTokenSet(ctx, &oauth2.Config{}, tok, ts, "user-1", "svc", "oauth2"); err != nil {
+		t.Fatalf("NewClientWithTokenSet(): err [%v]", err)
+	}
+	if stored, err := ts.GetTokenInfo(ctx, "user-1"); err != nil || stored.Token.AccessToken != "new" ||
+		string(stored.Metadata) != `{"user":"1"}` {
+		t.Errorf("NewClientWithTokenSet(): want stored [new] with metadata, got [%v] err [%v]", stored, err)
+	}
+}
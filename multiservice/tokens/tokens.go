@@ -3,24 +3,187 @@ package tokens
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"golang.org/x/oauth2"
 )
 
+var (
+	// ErrNotFound is returned when a token set has no token for a key.
+	ErrNotFound = errors.New("token not found")
+	// ErrConflict is returned by `TokenSet.CompareAndSwap()` when the stored token is not the
+	// expected version.
+	ErrConflict = errors.New("token version conflict")
+	// ErrKeyRequired is returned when a token key is empty.
+	ErrKeyRequired = errors.New("token key is required")
+)
+
+// UpdateMaxAttempts is the number of times `Update()` retries after `ErrConflict`.
+var UpdateMaxAttempts = 5
+
 type TokenInfo struct {
 	ServiceKey  string        `json:"serviceKey,omitempty"`
 	ServiceType string        `json:"serviceType,omitempty"`
 	Token       *oauth2.Token `json:"token,omitempty"`
+	// Extra holds token response fields beyond the standard ones, e.g. `id_token` or `scope`,
+	// which `oauth2.Token` does not serialize. See `TokenExtra()`.
+	Extra map[string]any `json:"extra,omitempty"`
+	// Metadata is application data stored with the token, e.g. `multiservice.Connection`. It
+	// is kept when the token is refreshed by `TokenSource`.
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	// Encrypted is set instead of `Token` and `Extra` by `EncryptedTokenSet` in the token info
+	// it stores.
+	Encrypted *Envelope `json:"encrypted,omitempty"`
+	// UpdatedAt is set by the token set on each write.
+	UpdatedAt time.Time `json:"updatedAt,omitzero"`
+	// Version is set by the token set and incremented on each write. It is compared by
+	// `TokenSet.CompareAndSwap()`.
+	Version int64 `json:"version,omitempty"`
+}
+
+// DefaultExtraKeys are the token response fields captured by `NewTokenInfo()` when no keys
+// are given.
+var DefaultExtraKeys = []string{"id_token", "scope"}
+
+// NewTokenInfo returns token info for `tok`, capturing the `extraKeys` fields of the token
+// response, or `DefaultExtraKeys` if none are given.
+func NewTokenInfo(serviceKey, serviceType string, tok *oauth2.Token, extraKeys ...string) *TokenInfo {
+	if len(extraKeys) == 0 {
+		extraKeys = DefaultExtraKeys
+	}
+	return &TokenInfo{
+		ServiceKey:  serviceKey,
+		ServiceType: serviceType,
+		Token:       tok,
+		Extra:       TokenExtra(tok, extraKeys...)}
+}
+
+// TokenExtra returns the non-nil `keys` fields of the token response, or nil if there are none.
+func TokenExtra(tok *oauth2.Token, keys ...string) map[string]any {
+	if tok == nil {
+		return nil
+	}
+	var extra map[string]any
+	for _, key := range keys {
+		if v := tok.Extra(key); v != nil {
+			if extra == nil {
+				extra = map[string]any{}
+			}
+			extra[key] = v
+		}
+	}
+	return extra
+}
+
+// OAuth2Token returns the token with `Extra` available from `oauth2.Token.Extra()`, or nil
+// if there is no token.
+func (ti *TokenInfo) OAuth2Token() *oauth2.Token {
+	if ti == nil || ti.Token == nil {
+		return nil
+	} else if len(ti.Extra) == 0 {
+		return ti.Token
+	}
+	return ti.Token.WithExtra(maps.Clone(ti.Extra))
+}
+
+// Clone returns a copy of the token info, its token and extra fields.
+func (ti *TokenInfo) Clone() *TokenInfo {
+	if ti == nil {
+		return nil
+	}
+	out := *ti
+	if ti.Token != nil {
+		tok := *ti.Token
+		out.Token = &tok
+	}
+	out.Extra = maps.Clone(ti.Extra)
+	out.Metadata = slices.Clone(ti.Metadata)
+	if ti.Encrypted != nil {
+		env := *ti.Encrypted
+		out.Encrypted = &env
+	}
+	return &out
 }
 
 func FormatKey(key string) string { return strings.TrimSpace(key) }
 
+// TokenSet stores tokens by key. Keys are formatted with `FormatKey()`. Implementations must
+// be safe for concurrent use.
 type TokenSet interface {
-	GetTokenInfo(key string) (*TokenInfo, error)
-	GetToken(key string) (*oauth2.Token, error)
-	SetTokenInfo(key string, tokenInfo *TokenInfo) error
+	// GetTokenInfo returns the token info for a key, or an error wrapping `ErrNotFound`.
+	GetTokenInfo(ctx context.Context, key string) (*TokenInfo, error)
+	// SetTokenInfo stores the token info for a key, replacing any existing value.
+	SetTokenInfo(ctx context.Context, key string, tokenInfo *TokenInfo) error
+	// CompareAndSwap stores `tokenInfo` only if the stored token is at the version of `old`,
+	// or does not exist if `old` is nil. It returns an error wrapping `ErrConflict` otherwise.
+	CompareAndSwap(ctx context.Context, key string, old, tokenInfo *TokenInfo) error
+	// Delete removes the token for a key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns the sorted keys which start with `prefix`, or all keys if empty.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// GetToken returns the token for a key, with `TokenInfo.Extra` available from
+// `oauth2.Token.Extra()`.
+func GetToken(ctx context.Context, ts TokenSet, key string) (*oauth2.Token, error) {
+	if ti, err := ts.GetTokenInfo(ctx, key); err != nil {
+		return nil, err
+	} else if ti.Token == nil {
+		return nil, fmt.Errorf("%w: no token in token info (%s)", ErrNotFound, FormatKey(key))
+	} else {
+		return ti.OAuth2Token(), nil
+	}
+}
+
+// Update replaces the token info for a key with the result of `fn`, which is called with
+// the current value or nil if there is none. It retries with the new current value when
+// another writer changes the token first. If `fn` returns nil, the token is not changed.
+func Update(ctx context.Context, ts TokenSet, key string, fn func(cur *TokenInfo) (*TokenInfo, error)) (*TokenInfo, error) {
+	var err error
+	for range max(UpdateMaxAttempts, 1) {
+		cur, getErr := ts.GetTokenInfo(ctx, key)
+		if errors.Is(getErr, ErrNotFound) {
+			cur = nil
+		} else if getErr != nil {
+			return nil, getErr
+		}
+		next, fnErr := fn(cur.Clone())
+		if fnErr != nil {
+			return nil, fnErr
+		} else if next == nil {
+			return cur, nil
+		}
+		if err = ts.CompareAndSwap(ctx, key, cur, next); err == nil {
+			return ts.GetTokenInfo(ctx, key)
+		} else if !errors.Is(err, ErrConflict) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// NotFoundError returns an error wrapping `ErrNotFound` for a key.
+func NotFoundError(key string) error {
+	return fmt.Errorf("%w (%s)", ErrNotFound, key)
+}
+
+// ConflictError returns an error wrapping `ErrConflict` for a key.
+func ConflictError(key string, want, got int64) error {
+	return fmt.Errorf("%w (%s): want version [%d], got [%d]", ErrConflict, key, want, got)
+}
+
+// Version returns the version of `ti`, or 0 if nil.
+func Version(ti *TokenInfo) int64 {
+	if ti == nil {
+		return 0
+	}
+	return ti.Version
 }
 
 func ParseTokenInfo(data []byte) (*TokenInfo, error) {
@@ -28,19 +191,31 @@ func ParseTokenInfo(data []byte) (*TokenInfo, error) {
 	return tok, json.Unmarshal(data, tok)
 }
 
+// NewClientWithTokenSet returns a client using a `TokenSource` for `tokenKey`, so every
+// refreshed token is stored in `tokenSet`. If `token` is not nil, it replaces the stored token,
+// e.g. after the user authorizes again, keeping the stored metadata. If nil, the stored token
+// is used.
 func NewClientWithTokenSet(ctx context.Context, conf *oauth2.Config, token *oauth2.Token,
 	tokenSet TokenSet, tokenKey, serviceKey, serviceType string) (*http.Client, error) {
-	tokenSource := conf.TokenSource(ctx, token)
-
-	if newToken, err := tokenSource.Token(); err != nil {
-		return nil, err
-	} else if newToken.AccessToken != token.AccessToken {
-		if err := tokenSet.SetTokenInfo(tokenKey, &TokenInfo{
-			ServiceKey:  serviceKey,
-			ServiceType: serviceType,
-			Token:       newToken}); err != nil {
+	if token != nil {
+		_, err := Update(ctx, tokenSet, tokenKey, func(cur *TokenInfo) (*TokenInfo, error) {
+			if stored := cur.OAuth2Token(); stored != nil && stored.AccessToken == token.AccessToken &&
+				stored.RefreshToken == token.RefreshToken {
+				return nil, nil
+			}
+			next := NewTokenInfo(serviceKey, serviceType, token)
+			if cur != nil {
+				next.Metadata = cur.Metadata
+			}
+			return next, nil
+		})
+		if err != nil {
 			return nil, err
 		}
 	}
-	return oauth2.NewClient(ctx, tokenSource), nil
+	tokenSource := NewTokenSource(ctx, conf, tokenSet, tokenKey)
+	if _, err := tokenSource.Token(); err != nil {
+		return nil, err
+	}
+	return oauth2.NewClient(ctx, oauth2.ReuseTokenSource(nil, tokenSource)), nil
 }
@@ -0,0 +1,215 @@
+package tokensetfile
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/goauth/multiservice/tokens"
+)
+
+const (
+	fileExt        = ".json"
+	refreshLockExt = ".refresh"
+	hashPrefix     = "sha256="
+	// maxNameLen bounds escaped key file names, leaving room for lock and temporary file
+	// suffixes within the usual 255 byte file name limit.
+	maxNameLen = 200
+)
+
+// TokenSet is a `tokens.TokenSet` which stores each token as a JSON file in `Dir` with `0600`
+// permissions. Writes are guarded by a lock file and use a temporary file and rename, so
+// processes sharing the directory do not see partial writes. Keys are query escaped to form
+// file names, with upper case letters escaped too so keys differing in case do not collide on
+// case insensitive file systems, e.g. `User/1` is stored as `%55ser%2F1.json`. Keys whose
+// escaped names are longer than 200 bytes are stored as `sha256=<hex>.json` using the SHA-256
+// hash of the key. Each file also holds its key, which `List()` reads for hashed names.
+type TokenSet struct {
+	Dir string
+}
+
+// NewTokenSet returns a `TokenSet` for `dir`, creating it with `0700` permissions.
+func NewTokenSet(dir string) (*TokenSet, error) {
+	if strings.TrimSpace(dir) == "" {
+		return nil, errors.New("token set directory is required")
+	} else if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &TokenSet{Dir: dir}, nil
+}
+
+// fileTokenInfo is the file format, a `tokens.TokenInfo` with its key.
+type fileTokenInfo struct {
+	Key string `json:"key,omitempty"`
+	*tokens.TokenInfo
+}
+
+// name returns the file name for `key` without an extension.
+func name(key string) string {
+	var b strings.Builder
+	for i := range len(key) {
+		if c := key[i]; c >= 'A' && c <= 'Z' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteString(url.QueryEscape(key[i : i+1]))
+		}
+	}
+	if b.Len() > maxNameLen {
+		sum := sha256.Sum256([]byte(key))
+		return hashPrefix + hex.EncodeToString(sum[:])
+	}
+	return b.String()
+}
+
+func (toks *TokenSet) path(key string) string {
+	return filepath.Join(toks.Dir, name(key)+fileExt)
+}
+
+func (toks *TokenSet) GetTokenInfo(ctx context.Context, key string) (*tokens.TokenInfo, error) {
+	key = tokens.FormatKey(key)
+	if key == "" {
+		return nil, tokens.NotFoundError(key)
+	}
+	return toks.read(key)
+}
+
+func (toks *TokenSet) read(key string) (*tokens.TokenInfo, error) {
+	data, err := os.ReadFile(toks.path(key))
+	if errors.Is(err, os.ErrNotExist) || (err == nil && strings.TrimSpace(string(data)) == "") {
+		return nil, tokens.NotFoundError(key)
+	} else if err != nil {
+		return nil, err
+	}
+	return tokens.ParseTokenInfo(data)
+}
+
+func (toks *TokenSet) SetTokenInfo(ctx context.Context, key string, tok *tokens.TokenInfo) error {
+	return toks.update(ctx, key, func(key string, cur *tokens.TokenInfo) error { return nil }, tok)
+}
+
+func (toks *TokenSet) CompareAndSwap(ctx context.Context, key string, old, tok *tokens.TokenInfo) error {
+	return toks.update(ctx, key, func(key string, cur *tokens.TokenInfo) error {
+		if (cur != nil) != (old != nil) || tokens.Version(cur) != tokens.Version(old) {
+			return tokens.ConflictError(key, tokens.Version(old), tokens.Version(cur))
+		}
+		return nil
+	}, tok)
+}
+
+// update writes `tok` with the version after the current token if `check` returns nil,
+// holding the key's lock file.
+func (toks *TokenSet) update(ctx context.Context, key string, check func(key string, cur *tokens.TokenInfo) error, tok *tokens.TokenInfo) error {
+	key = tokens.FormatKey(key)
+	if key == "" {
+		return tokens.ErrKeyRequired
+	} else if err := os.MkdirAll(toks.Dir, 0700); err != nil {
+		return err
+	}
+	path := toks.path(key)
+	unlock, err := authutil.LockFileContext(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	cur, err := toks.read(key)
+	if err != nil && !errors.Is(err, tokens.ErrNotFound) {
+		return err
+	} else if err := check(key, cur); err != nil {
+		return err
+	}
+	next := tok.Clone()
+	if next == nil {
+		next = &tokens.TokenInfo{}
+	}
+	next.Version = tokens.Version(cur) + 1
+	next.UpdatedAt = time.Now().UTC()
+	b, err := json.Marshal(fileTokenInfo{Key: key, TokenInfo: next})
+	if err != nil {
+		return err
+	}
+	return authutil.WriteFileAtomic(path, b, 0600)
+}
+
+func (toks *TokenSet) Delete(ctx context.Context, key string) error {
+	key = tokens.FormatKey(key)
+	if key == "" {
+		return nil
+	}
+	path := toks.path(key)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	unlock, err := authutil.LockFileContext(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List returns the keys with `prefix` from the JSON file names in `Dir`, or from the files for
+// hashed names. Lock and temporary files are skipped.
+func (toks *TokenSet) List(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	entries, err := os.ReadDir(toks.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return keys, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != fileExt {
+			continue
+		}
+		key, err := toks.key(strings.TrimSuffix(e.Name(), fileExt))
+		if err == nil && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// key returns the key for file name `name`, reading it from the file for hashed names.
+func (toks *TokenSet) key(name string) (string, error) {
+	if !strings.HasPrefix(name, hashPrefix) {
+		return url.QueryUnescape(name)
+	}
+	data, err := os.ReadFile(filepath.Join(toks.Dir, name+fileExt))
+	if err != nil {
+		return "", err
+	}
+	fti := fileTokenInfo{}
+	if err := json.Unmarshal(data, &fti); err != nil {
+		return "", err
+	} else if fti.Key == "" {
+		return "", tokens.ErrKeyRequired
+	}
+	return fti.Key, nil
+}
+
+// Lock acquires a lock shared by processes using `Dir`, using a lock file separate from the
+// one guarding writes. It waits until `ctx` is done.
+func (toks *TokenSet) Lock(ctx context.Context, key string) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	} else if err := os.MkdirAll(toks.Dir, 0700); err != nil {
+		return nil, err
+	}
+	return authutil.LockFileContext(ctx, filepath.Join(toks.Dir, name(tokens.FormatKey(key))+refreshLockExt))
+}
@@ -0,0 +1,118 @@
+package tokensetfile
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/goauth/multiservice/tokens"
+	"github.com/grokify/goauth/multiservice/tokens/tokenstest"
+)
+
+func TestTokenSet(t *testing.T) {
+	tokenstest.TestTokenSet(t, func(t *testing.T) tokens.TokenSet {
+		ts, err := NewTokenSet(t.TempDir())
+		if err != nil {
+			t.Fatalf("NewTokenSet(): err [%v]", err)
+		}
+		return ts
+	})
+}
+
+func TestTokenSetFile(t *testing.T) {
+	ts, err := NewTokenSet(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewTokenSet(): err [%v]", err)
+	}
+	if err := ts.SetTokenInfo(context.Background(), "user/1", &tokens.TokenInfo{ServiceKey: "svc"}); err != nil {
+		t.Fatalf("TokenSet.SetTokenInfo(user/1): err [%v]", err)
+	}
+	path := ts.path("user/1")
+	if fi, err := os.Stat(path); err != nil {
+		t.Errorf("TokenSet.SetTokenInfo(user/1): err [%v]", err)
+	} else if fi.Mode().Perm() != 0600 {
+		t.Errorf("TokenSet.SetTokenInfo(user/1): want mode [0600], got [%v]", fi.Mode().Perm())
+	}
+	// Lock files are kept, but released, and not listed.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if unlock, err := authutil.LockFileContext(ctx, path); err != nil {
+		t.Errorf("TokenSet.SetTokenInfo(user/1): want lock released, got [%v]", err)
+	} else {
+		unlock()
+	}
+	if keys, err := ts.List(context.Background(), ""); err != nil || !slices.Equal(keys, []string{"user/1"}) {
+		t.Errorf("TokenSet.List(): want [user/1], got [%v] err [%v]", keys, err)
+	}
+}
+
+func TestTokenSetFileLock(t *testing.T) {
+	ts, err := NewTokenSet(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewTokenSet(): err [%v]", err)
+	}
+	unlock, err := ts.Lock(context.Background(), "user/1")
+	if err != nil {
+		t.Fatalf("TokenSet.Lock(user/1): err [%v]", err)
+	}
+	defer unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := ts.Lock(ctx, "user/1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("TokenSet.Lock(user/1) while held: want [%v], got [%v]", context.DeadlineExceeded, err)
+	}
+	if unlock2, err := ts.Lock(context.Background(), "user/2"); err != nil {
+		t.Errorf("TokenSet.Lock(user/2): err [%v]", err)
+	} else {
+		unlock2()
+	}
+}
+
+func TestTokenSetFileNames(t *testing.T) {
+	ts, err := NewTokenSet(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewTokenSet(): err [%v]", err)
+	}
+	ctx := context.Background()
+	long := strings.Repeat("k", 300)
+	keys := []string{"User/1", "user/1", long}
+	for _, key := range keys {
+		if err := ts.SetTokenInfo(ctx, key, &tokens.TokenInfo{ServiceKey: key}); err != nil {
+			t.Fatalf("TokenSet.SetTokenInfo(%s): err [%v]", key, err)
+		}
+	}
+	if strings.EqualFold(ts.path("User/1"), ts.path("user/1")) {
+		t.Errorf("TokenSet.path(User/1): want differing from [%s] ignoring case, got [%s]", ts.path("user/1"), ts.path("User/1"))
+	}
+	if n := len(filepath.Base(ts.path(long))); n > 255 {
+		t.Errorf("TokenSet.path(long): want length <= [255], got [%d]", n)
+	}
+	for _, key := range keys {
+		if ti, err := ts.GetTokenInfo(ctx, key); err != nil || ti.ServiceKey != key {
+			t.Errorf("TokenSet.GetTokenInfo(%s): want [%s], got [%v] err [%v]", key, key, ti, err)
+		}
+	}
+	want := slices.Sorted(slices.Values(keys))
+	if got, err := ts.List(ctx, ""); err != nil || !slices.Equal(got, want) {
+		t.Errorf("TokenSet.List(): want [%v], got [%v] err [%v]", want, got, err)
+	}
+}
+
+func TestTokenSetFileDeleteMissing(t *testing.T) {
+	ts, err := NewTokenSet(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewTokenSet(): err [%v]", err)
+	}
+	if err := ts.Delete(context.Background(), "user/1"); err != nil {
+		t.Errorf("TokenSet.Delete(user/1): err [%v]", err)
+	}
+	if entries, err := os.ReadDir(ts.Dir); err != nil || len(entries) != 0 {
+		t.Errorf("TokenSet.Delete(user/1) when missing: want no files, got [%v] err [%v]", entries, err)
+	}
+}
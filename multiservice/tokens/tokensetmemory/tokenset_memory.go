@@ -1,41 +1,275 @@
 package tokensetmemory
 
 import (
-	"errors"
-	"fmt"
+	"container/list"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/grokify/goauth/multiservice/tokens"
-	"golang.org/x/oauth2"
 )
 
+// WatchBuffer is the number of events buffered for each watcher. Events are dropped for
+// watchers which do not keep up, so watchers should read the token again when notified
+// rather than rely on every event.
+var WatchBuffer = 16
+
+// EventType is the type of change to a token.
+type EventType string
+
+const (
+	// EventSet is sent when a token is stored or replaced.
+	EventSet EventType = "set"
+	// EventDelete is sent when a token is deleted, e.g. revoked.
+	EventDelete EventType = "delete"
+	// EventEvict is sent when a token is evicted by `TTL`, `EvictExpired` or `MaxSize`.
+	EventEvict EventType = "evict"
+)
+
+// Event is a change to the token for `Key`. `TokenInfo` is set for `EventSet`.
+type Event struct {
+	Type      EventType
+	Key       string
+	TokenInfo *tokens.TokenInfo
+}
+
+// TokenSet is an in-memory `tokens.TokenSet` which is safe for concurrent use. Token info is
+// copied on read and write. Eviction is optional and configured before use: entries are
+// evicted `TTL` after they are written, when `EvictExpired` is set and the token has expired
+// and cannot be refreshed, or least recently used first when there are more than `MaxSize`.
+// Expired entries are evicted when accessed, or by `Prune()`.
 type TokenSet struct {
-	tokenMap map[string]*tokens.TokenInfo
+	TTL          time.Duration
+	MaxSize      int
+	EvictExpired bool
+
+	mu       sync.Mutex
+	tokenMap map[string]*entry
+	lru      *list.List // of `*entry`, most recently used first
+	watchers map[*watcher]struct{}
+	now      func() time.Time
+}
+
+type entry struct {
+	key       string
+	info      *tokens.TokenInfo
+	expiresAt time.Time // zero if there is no `TTL`
+	elem      *list.Element
+}
+
+type watcher struct {
+	key string // empty for all keys
+	ch  chan Event
 }
 
 func NewTokenSet() *TokenSet {
-	return &TokenSet{tokenMap: map[string]*tokens.TokenInfo{}}
+	return &TokenSet{}
+}
+
+// init initializes the maps. `toks.mu` must be held.
+func (toks *TokenSet) init() {
+	if toks.tokenMap == nil {
+		toks.tokenMap = map[string]*entry{}
+		toks.lru = list.New()
+	}
 }
 
-func (toks *TokenSet) GetToken(key string) (*oauth2.Token, error) {
-	if tok, ok := toks.tokenMap[tokens.FormatKey(key)]; ok {
-		return tok.Token, nil
+func (toks *TokenSet) timeNow() time.Time {
+	if toks.now != nil {
+		return toks.now()
 	}
-	return nil, fmt.Errorf("info AppConfig not found for [%v]", key)
+	return time.Now()
 }
 
-func (toks *TokenSet) GetTokenInfo(key string) (*tokens.TokenInfo, error) {
+// evictable returns true if `e` should be evicted at `now`.
+func (toks *TokenSet) evictable(e *entry, now time.Time) bool {
+	if !e.expiresAt.IsZero() && !now.Before(e.expiresAt) {
+		return true
+	} else if tok := e.info.Token; toks.EvictExpired && tok != nil && tok.RefreshToken == "" &&
+		!tok.Expiry.IsZero() && !now.Before(tok.Expiry) {
+		return true
+	}
+	return false
+}
+
+// get returns the entry for `key`, evicting it if needed. `toks.mu` must be held.
+func (toks *TokenSet) get(key string) (*entry, bool) {
+	toks.init()
+	e, ok := toks.tokenMap[key]
+	if !ok {
+		return nil, false
+	} else if toks.evictable(e, toks.timeNow()) {
+		toks.remove(e, EventEvict)
+		return nil, false
+	}
+	return e, true
+}
+
+func (toks *TokenSet) GetTokenInfo(ctx context.Context, key string) (*tokens.TokenInfo, error) {
+	key = tokens.FormatKey(key)
+	toks.mu.Lock()
+	defer toks.mu.Unlock()
+	if e, ok := toks.get(key); ok {
+		toks.lru.MoveToFront(e.elem)
+		return e.info.Clone(), nil
+	}
+	return nil, tokens.NotFoundError(key)
+}
+
+func (toks *TokenSet) SetTokenInfo(ctx context.Context, key string, tok *tokens.TokenInfo) error {
 	key = tokens.FormatKey(key)
-	if tok, ok := toks.tokenMap[key]; ok {
-		return tok, nil
+	if len(key) == 0 {
+		return tokens.ErrKeyRequired
 	}
-	return nil, fmt.Errorf("info AppConfig not found for [%v]", key)
+	toks.mu.Lock()
+	defer toks.mu.Unlock()
+	cur, _ := toks.get(key)
+	toks.put(key, cur, tok)
+	return nil
 }
 
-func (toks *TokenSet) SetTokenInfo(key string, tok *tokens.TokenInfo) error {
+func (toks *TokenSet) CompareAndSwap(ctx context.Context, key string, old, tok *tokens.TokenInfo) error {
 	key = tokens.FormatKey(key)
 	if len(key) == 0 {
-		return errors.New("set token requires non-empty key")
+		return tokens.ErrKeyRequired
+	}
+	toks.mu.Lock()
+	defer toks.mu.Unlock()
+	cur, ok := toks.get(key)
+	var curInfo *tokens.TokenInfo
+	if ok {
+		curInfo = cur.info
+	}
+	if ok != (old != nil) || tokens.Version(curInfo) != tokens.Version(old) {
+		return tokens.ConflictError(key, tokens.Version(old), tokens.Version(curInfo))
 	}
-	toks.tokenMap[key] = tok
+	toks.put(key, cur, tok)
 	return nil
 }
+
+// put stores a copy of `tok` with the next version and update time, replacing `cur` if not
+// nil, and evicts the least recently used entries over `MaxSize`. `toks.mu` must be held.
+func (toks *TokenSet) put(key string, cur *entry, tok *tokens.TokenInfo) {
+	toks.init()
+	next := tok.Clone()
+	if next == nil {
+		next = &tokens.TokenInfo{}
+	}
+	now := toks.timeNow()
+	if cur != nil {
+		next.Version = cur.info.Version + 1
+	} else {
+		next.Version = 1
+		cur = &entry{key: key}
+		cur.elem = toks.lru.PushFront(cur)
+		toks.tokenMap[key] = cur
+	}
+	next.UpdatedAt = now.UTC()
+	cur.info = next
+	if toks.TTL > 0 {
+		cur.expiresAt = now.Add(toks.TTL)
+	} else {
+		cur.expiresAt = time.Time{}
+	}
+	toks.lru.MoveToFront(cur.elem)
+	toks.notify(Event{Type: EventSet, Key: key, TokenInfo: next})
+	for toks.MaxSize > 0 && toks.lru.Len() > toks.MaxSize {
+		toks.remove(toks.lru.Back().Value.(*entry), EventEvict)
+	}
+}
+
+// remove deletes `e` and notifies watchers. `toks.mu` must be held.
+func (toks *TokenSet) remove(e *entry, typ EventType) {
+	delete(toks.tokenMap, e.key)
+	toks.lru.Remove(e.elem)
+	toks.notify(Event{Type: typ, Key: e.key})
+}
+
+func (toks *TokenSet) Delete(ctx context.Context, key string) error {
+	toks.mu.Lock()
+	defer toks.mu.Unlock()
+	toks.init()
+	if e, ok := toks.tokenMap[tokens.FormatKey(key)]; ok {
+		toks.remove(e, EventDelete)
+	}
+	return nil
+}
+
+func (toks *TokenSet) List(ctx context.Context, prefix string) ([]string, error) {
+	toks.mu.Lock()
+	defer toks.mu.Unlock()
+	toks.prune()
+	keys := []string{}
+	for key := range toks.tokenMap {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// Prune evicts entries which are past `TTL` or, with `EvictExpired`, have expired tokens, and
+// returns the number evicted. It can be called periodically to free memory.
+func (toks *TokenSet) Prune() int {
+	toks.mu.Lock()
+	defer toks.mu.Unlock()
+	return toks.prune()
+}
+
+func (toks *TokenSet) prune() int {
+	toks.init()
+	now := toks.timeNow()
+	count := 0
+	for _, e := range toks.tokenMap {
+		if toks.evictable(e, now) {
+			toks.remove(e, EventEvict)
+			count++
+		}
+	}
+	return count
+}
+
+// Len returns the number of entries, including expired entries not yet evicted.
+func (toks *TokenSet) Len() int {
+	toks.mu.Lock()
+	defer toks.mu.Unlock()
+	return len(toks.tokenMap)
+}
+
+// Watch returns a channel of changes to the token for `key`, or to all tokens if `key` is
+// empty, e.g. so a component holding a client can rebuild it when the token is replaced or
+// revoked. The channel is closed when `ctx` is done.
+func (toks *TokenSet) Watch(ctx context.Context, key string) <-chan Event {
+	w := &watcher{key: tokens.FormatKey(key), ch: make(chan Event, max(WatchBuffer, 1))}
+	toks.mu.Lock()
+	if toks.watchers == nil {
+		toks.watchers = map[*watcher]struct{}{}
+	}
+	toks.watchers[w] = struct{}{}
+	toks.mu.Unlock()
+	context.AfterFunc(ctx, func() {
+		toks.mu.Lock()
+		defer toks.mu.Unlock()
+		delete(toks.watchers, w)
+		close(w.ch)
+	})
+	return w.ch
+}
+
+// notify sends `ev` to matching watchers without blocking. `toks.mu` must be held.
+func (toks *TokenSet) notify(ev Event) {
+	for w := range toks.watchers {
+		if w.key != "" && w.key != ev.Key {
+			continue
+		}
+		wev := ev
+		wev.TokenInfo = ev.TokenInfo.Clone()
+		select {
+		case w.ch <- wev:
+		default:
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package tokensetmemory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grokify/goauth/multiservice/tokens"
+	"github.com/grokify/goauth/multiservice/tokens/tokenstest"
+	"golang.org/x/oauth2"
+)
+
+func TestTokenSet(t *testing.T) {
+	tokenstest.TestTokenSet(t, func(t *testing.T) tokens.TokenSet { return NewTokenSet() })
+}
+
+func TestEviction(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	ts := NewTokenSet()
+	ts.TTL = time.Hour
+	ts.MaxSize = 2
+	ts.EvictExpired = true
+	ts.now = func() time.Time { return now }
+	set := func(key string, tok *oauth2.Token) {
+		if err := ts.SetTokenInfo(ctx, key, &tokens.TokenInfo{Token: tok}); err != nil {
+			t.Fatalf("TokenSet.SetTokenInfo(%s): err [%v]", key, err)
+		}
+	}
+	set("a", &oauth2.Token{AccessToken: "a"})
+	set("b", &oauth2.Token{AccessToken: "b"})
+	if _, err := ts.GetTokenInfo(ctx, "a"); err != nil {
+		t.Fatalf("TokenSet.GetTokenInfo(a): err [%v]", err)
+	}
+	set("c", &oauth2.Token{AccessToken: "c"})
+	if keys, _ := ts.List(ctx, ""); strings.Join(keys, ",") != "a,c" {
+		t.Errorf("TokenSet.List() after MaxSize: want [a,c], got [%v]", keys)
+	}
+
+	set("expiring", &oauth2.Token{AccessToken: "x", Expiry: now.Add(time.Minute)})
+	set("refreshable", &oauth2.Token{AccessToken: "x", RefreshToken: "r", Expiry: now.Add(time.Minute)})
+	now = now.Add(2 * time.Minute)
+	if _, err := ts.GetTokenInfo(ctx, "expiring"); !errors.Is(err, tokens.ErrNotFound) {
+		t.Errorf("TokenSet.GetTokenInfo(expiring): want [%v], got [%v]", tokens.ErrNotFound, err)
+	} else if _, err := ts.GetTokenInfo(ctx, "refreshable"); err != nil {
+		t.Errorf("TokenSet.GetTokenInfo(refreshable): err [%v]", err)
+	}
+	now = now.Add(time.Hour)
+	if n := ts.Prune(); n != 1 || ts.Len() != 0 {
+		t.Errorf("TokenSet.Prune() after TTL: want [1] with [0] left, got [%d] with [%d] left", n, ts.Len())
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ts := NewTokenSet()
+	events := ts.Watch(ctx, "a")
+	all := ts.Watch(ctx, "")
+	if err := ts.SetTokenInfo(ctx, "a", &tokens.TokenInfo{Token: &oauth2.Token{AccessToken: "a"}}); err != nil {
+		t.Fatalf("TokenSet.SetTokenInfo(a): err [%v]", err)
+	} else if err := ts.SetTokenInfo(ctx, "b", &tokens.TokenInfo{}); err != nil {
+		t.Fatalf("TokenSet.SetTokenInfo(b): err [%v]", err)
+	} else if err := ts.Delete(ctx, "a"); err != nil {
+		t.Fatalf("TokenSet.Delete(a): err [%v]", err)
+	}
+	cancel()
+	var got []string
+	for ev := range events {
+		got = append(got, string(ev.Type)+":"+ev.Key)
+		if ev.Type == EventSet && (ev.TokenInfo == nil || ev.TokenInfo.Token.AccessToken != "a") {
+			t.Errorf("TokenSet.Watch(a): want token info [a], got [%v]", ev.TokenInfo)
+		}
+	}
+	if strings.Join(got, ",") != "set:a,delete:a" {
+		t.Errorf("TokenSet.Watch(a): want [set:a,delete:a], got [%v]", got)
+	}
+	got = nil
+	for ev := range all {
+		got = append(got, string(ev.Type)+":"+ev.Key)
+	}
+	if strings.Join(got, ",") != "set:a,set:b,delete:a" {
+		t.Errorf("TokenSet.Watch(): want [set:a,set:b,delete:a], got [%v]", got)
+	}
+}
@@ -1,46 +1,207 @@
 package tokensetredis
 
 import (
+	"cmp"
 	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/grokify/goauth/multiservice/tokens"
-	"github.com/grokify/sogo/database/kvs"
-	"github.com/grokify/sogo/database/kvs/redis"
-	"golang.org/x/oauth2"
 )
 
+// scanCount is the `SCAN` batch size hint used by `List()`.
+const scanCount = 100
+
+const (
+	// DefaultLockPrefix is the key prefix for locks taken by `Lock()`.
+	DefaultLockPrefix = "goauth:lock:"
+	// DefaultLockTTL is how long a lock is held if it is not released, e.g. if the process exits.
+	DefaultLockTTL = 30 * time.Second
+
+	lockRetryInterval = 50 * time.Millisecond
+)
+
+// unlockScript deletes a lock only if it is still held with the caller's value, so a lock
+// which expired and was taken by another process is not released.
+var unlockScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// TokenSet is a `tokens.TokenSet` which stores token info as JSON strings in Redis. Keys are
+// stored with `KeyPrefix`, e.g. `goauth:token:`, which is empty by default. Set it if the
+// database is shared, since `List()` returns all keys with the prefix. Locks are stored under
+// `KeyPrefix` + `LockPrefix` and are not returned by `List()`.
 type TokenSet struct {
-	gsClient kvs.Client
+	client    redis.UniversalClient
+	KeyPrefix string
+	// LockPrefix and LockTTL default to `DefaultLockPrefix` and `DefaultLockTTL`.
+	LockPrefix string
+	LockTTL    time.Duration
 }
 
-func NewTokenSet(client *redis.Client) *TokenSet {
-	return &TokenSet{gsClient: client}
+func NewTokenSet(client redis.UniversalClient) *TokenSet {
+	return &TokenSet{client: client}
 }
 
-func (toks *TokenSet) GetToken(ctx context.Context, key string) (*oauth2.Token, error) {
-	if tokInfo, err := toks.GetTokenInfo(ctx, key); err != nil {
-		return nil, err
-	} else {
-		return tokInfo.Token, nil
-	}
+func (toks *TokenSet) redisKey(key string) string {
+	return toks.KeyPrefix + tokens.FormatKey(key)
 }
 
 func (toks *TokenSet) GetTokenInfo(ctx context.Context, key string) (*tokens.TokenInfo, error) {
-	key = tokens.FormatKey(key)
-	data := toks.gsClient.GetOrDefaultString(ctx, key, "")
-	if len(strings.TrimSpace(data)) == 0 {
-		return nil, fmt.Errorf("no token for [%v]", key)
+	return toks.get(ctx, toks.client, key)
+}
+
+func (toks *TokenSet) get(ctx context.Context, cmd redis.Cmdable, key string) (*tokens.TokenInfo, error) {
+	data, err := cmd.Get(ctx, toks.redisKey(key)).Result()
+	if errors.Is(err, redis.Nil) || (err == nil && strings.TrimSpace(data) == "") {
+		return nil, tokens.NotFoundError(tokens.FormatKey(key))
+	} else if err != nil {
+		return nil, err
 	}
 	return tokens.ParseTokenInfo([]byte(data))
 }
 
 func (toks *TokenSet) SetTokenInfo(ctx context.Context, key string, tok *tokens.TokenInfo) error {
-	if bytes, err := json.Marshal(tok); err != nil {
+	if tokens.FormatKey(key) == "" {
+		return tokens.ErrKeyRequired
+	}
+	rkey := toks.redisKey(key)
+	var err error
+	// Retry if another writer changes the key between reading its version and writing.
+	for range max(tokens.UpdateMaxAttempts, 1) {
+		err = toks.watch(ctx, rkey, func(tx *redis.Tx) error {
+			cur, err := toks.get(ctx, tx, key)
+			if err != nil && !errors.Is(err, tokens.ErrNotFound) {
+				return err
+			}
+			return toks.put(ctx, tx, rkey, cur, tok)
+		})
+		if !errors.Is(err, tokens.ErrConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+func (toks *TokenSet) CompareAndSwap(ctx context.Context, key string, old, tok *tokens.TokenInfo) error {
+	if tokens.FormatKey(key) == "" {
+		return tokens.ErrKeyRequired
+	}
+	rkey := toks.redisKey(key)
+	return toks.watch(ctx, rkey, func(tx *redis.Tx) error {
+		cur, err := toks.get(ctx, tx, key)
+		if err != nil && !errors.Is(err, tokens.ErrNotFound) {
+			return err
+		} else if (cur != nil) != (old != nil) || tokens.Version(cur) != tokens.Version(old) {
+			return tokens.ConflictError(tokens.FormatKey(key), tokens.Version(old), tokens.Version(cur))
+		}
+		return toks.put(ctx, tx, rkey, cur, tok)
+	})
+}
+
+// watch runs `fn` in a transaction watching `rkey`. A concurrent write to the key is
+// reported as `tokens.ErrConflict`.
+func (toks *TokenSet) watch(ctx context.Context, rkey string, fn func(tx *redis.Tx) error) error {
+	err := toks.client.Watch(ctx, fn, rkey)
+	if errors.Is(err, redis.TxFailedErr) {
+		return fmt.Errorf("%w (%s): concurrent write", tokens.ErrConflict, strings.TrimPrefix(rkey, toks.KeyPrefix))
+	}
+	return err
+}
+
+// put writes `tok` with the version after `cur` and the update time in a `MULTI`/`EXEC` block.
+func (toks *TokenSet) put(ctx context.Context, tx *redis.Tx, rkey string, cur, tok *tokens.TokenInfo) error {
+	next := tok.Clone()
+	if next == nil {
+		next = &tokens.TokenInfo{}
+	}
+	next.Version = tokens.Version(cur) + 1
+	next.UpdatedAt = time.Now().UTC()
+	b, err := json.Marshal(next)
+	if err != nil {
 		return err
-	} else {
-		return toks.gsClient.SetString(ctx, tokens.FormatKey(key), string(bytes))
 	}
+	_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		return pipe.Set(ctx, rkey, string(b), 0).Err()
+	})
+	return err
+}
+
+func (toks *TokenSet) Delete(ctx context.Context, key string) error {
+	return toks.client.Del(ctx, toks.redisKey(key)).Err()
+}
+
+// List returns the keys with `prefix` using `SCAN`, without `KeyPrefix`.
+func (toks *TokenSet) List(ctx context.Context, prefix string) ([]string, error) {
+	match := escapeGlob(toks.KeyPrefix+prefix) + "*"
+	keys := []string{}
+	iter := toks.client.Scan(ctx, 0, match, scanCount).Iterator()
+	for iter.Next(ctx) {
+		if !strings.HasPrefix(iter.Val(), toks.lockKey("")) {
+			keys = append(keys, strings.TrimPrefix(iter.Val(), toks.KeyPrefix))
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	slices.Sort(keys)
+	return slices.Compact(keys), nil
+}
+
+// lockKey returns the Redis key of the lock for a token key.
+func (toks *TokenSet) lockKey(key string) string {
+	return toks.KeyPrefix + cmp.Or(toks.LockPrefix, DefaultLockPrefix) + tokens.FormatKey(key)
+}
+
+// escapeGlob escapes Redis `MATCH` pattern characters.
+func escapeGlob(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// Lock acquires a lock shared by all processes using the Redis database, using `SET NX` with
+// `LockTTL`. It waits until the lock is released or expires, or `ctx` is done.
+func (toks *TokenSet) Lock(ctx context.Context, key string) (func(), error) {
+	for {
+		if unlock, ok, err := toks.TryLock(ctx, key); err != nil {
+			return nil, err
+		} else if ok {
+			return unlock, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// TryLock acquires the lock taken by `Lock()` if it is not held. The lock is a lease which
+// expires after `LockTTL`, so it is released if the holder exits without unlocking, or if
+// unlocking fails, which is logged.
+func (toks *TokenSet) TryLock(ctx context.Context, key string) (func(), bool, error) {
+	lkey := toks.lockKey(key)
+	val := rand.Text()
+	if ok, err := toks.client.SetNX(ctx, lkey, val, cmp.Or(toks.LockTTL, DefaultLockTTL)).Result(); err != nil || !ok {
+		return nil, false, err
+	}
+	return func() {
+		if err := unlockScript.Run(context.WithoutCancel(ctx), toks.client, []string{lkey}, val).Err(); err != nil {
+			slog.Warn("token lock release failed", "key", lkey, "error", err.Error())
+		}
+	}, true, nil
 }
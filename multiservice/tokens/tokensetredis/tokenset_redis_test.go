@@ -0,0 +1,83 @@
+package tokensetredis
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/oauth2"
+
+	"github.com/grokify/goauth/multiservice/tokens"
+	"github.com/grokify/goauth/multiservice/tokens/tokenstest"
+)
+
+func TestTokenSet(t *testing.T) {
+	tokenstest.TestTokenSet(t, func(t *testing.T) tokens.TokenSet {
+		srv := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+		t.Cleanup(func() { client.Close() })
+		ts := NewTokenSet(client)
+		ts.KeyPrefix = "goauth:token:"
+		return ts
+	})
+}
+
+func TestLock(t *testing.T) {
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { client.Close() })
+	ts, other := NewTokenSet(client), NewTokenSet(client)
+	unlock, err := ts.Lock(context.Background(), "k")
+	if err != nil {
+		t.Fatalf("TokenSet.Lock(k): err [%v]", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := other.Lock(ctx, "k"); err == nil {
+		t.Errorf("TokenSet.Lock(k) while locked: want error, got nil")
+	}
+	if _, ok, err := other.TryLock(context.Background(), "k"); ok || err != nil {
+		t.Errorf("TokenSet.TryLock(k) while locked: want [false], got [%v] err [%v]", ok, err)
+	}
+	if err := ts.SetTokenInfo(context.Background(), "k", &tokens.TokenInfo{}); err != nil {
+		t.Fatalf("TokenSet.SetTokenInfo(k): err [%v]", err)
+	} else if keys, err := ts.List(context.Background(), ""); err != nil || len(keys) != 1 || keys[0] != "k" {
+		t.Errorf("TokenSet.List() while locked: want [[k]], got [%v] err [%v]", keys, err)
+	}
+	unlock()
+	if unlock, err := other.Lock(context.Background(), "k"); err != nil {
+		t.Errorf("TokenSet.Lock(k) after unlock: err [%v]", err)
+	} else {
+		unlock()
+	}
+}
+
+func TestSharedTokenSource(t *testing.T) {
+	srv := miniredis.RunT(t)
+	var fetches atomic.Int32
+	fetch := func(ctx context.Context) (*oauth2.Token, error) {
+		fetches.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return &oauth2.Token{AccessToken: "at", Expiry: time.Now().Add(time.Hour)}, nil
+	}
+	// Each replica has its own client and token source.
+	var wg sync.WaitGroup
+	for range 4 {
+		client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+		t.Cleanup(func() { client.Close() })
+		src := tokens.NewSharedTokenSource(context.Background(), fetch, NewTokenSet(client), "svc")
+		wg.Go(func() {
+			if tok, err := src.Token(); err != nil || tok.AccessToken != "at" {
+				t.Errorf("SharedTokenSource.Token(): want [at], got [%v] err [%v]", tok, err)
+			}
+		})
+	}
+	wg.Wait()
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("SharedTokenSource.Token(): want [1] fetch, got [%d]", n)
+	}
+}
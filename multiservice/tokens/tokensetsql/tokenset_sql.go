@@ -0,0 +1,336 @@
+package tokensetsql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/grokify/goauth/multiservice/tokens"
+)
+
+// DefaultTable is the token table name. Applied migrations are recorded in the table with a
+// `_migrations` suffix.
+const DefaultTable = "goauth_tokens"
+
+// Placeholder is the bind parameter style of a database driver.
+type Placeholder int
+
+const (
+	// PlaceholderQuestion uses `?`, e.g. for MySQL and SQLite.
+	PlaceholderQuestion Placeholder = iota
+	// PlaceholderDollar uses `$1`, `$2`, etc., e.g. for PostgreSQL.
+	PlaceholderDollar
+)
+
+var rxTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ErrTooLong is returned when a key, service key or service type is longer than its column.
+var ErrTooLong = errors.New("value too long for token table column")
+
+// Column lengths in characters, matching the `VARCHAR` columns in `migrations`.
+const (
+	MaxKeyLength         = 255
+	MaxServiceKeyLength  = 255
+	MaxServiceTypeLength = 64
+)
+
+// migrations are applied in order by `TokenSet.Migrate()`. `%[1]s` is the table name. Times
+// are stored as Unix milliseconds and the token info as JSON so the schema is portable across
+// SQL databases. Applied migrations must not be changed; add a new one instead.
+var migrations = []string{
+	`CREATE TABLE %[1]s (
+	token_key VARCHAR(255) NOT NULL PRIMARY KEY,
+	service_key VARCHAR(255) NOT NULL,
+	service_type VARCHAR(64) NOT NULL,
+	token_info TEXT NOT NULL,
+	expires_at BIGINT NOT NULL,
+	version BIGINT NOT NULL,
+	updated_at BIGINT NOT NULL)`,
+	`CREATE INDEX %[1]s_service_key ON %[1]s (service_key)`,
+}
+
+// TokenSet is a `tokens.TokenSet` which stores token info in a SQL table using `database/sql`.
+// `Migrate()` must be called to create or update the table before use.
+type TokenSet struct {
+	db          *sql.DB
+	Table       string
+	Placeholder Placeholder
+}
+
+// NewTokenSet returns a `TokenSet` using `DefaultTable` and `PlaceholderQuestion`.
+func NewTokenSet(db *sql.DB) *TokenSet {
+	return &TokenSet{db: db, Table: DefaultTable}
+}
+
+func (toks *TokenSet) table() (string, error) {
+	table := strings.TrimSpace(toks.Table)
+	if table == "" {
+		return DefaultTable, nil
+	} else if !rxTableName.MatchString(table) {
+		return "", fmt.Errorf("invalid token table name (%s)", table)
+	}
+	return table, nil
+}
+
+// query returns `query` with `{table}` replaced by the table name and `?` placeholders
+// rewritten for `Placeholder`.
+func (toks *TokenSet) query(query string) (string, error) {
+	table, err := toks.table()
+	if err != nil {
+		return "", err
+	}
+	query = strings.ReplaceAll(query, "{table}", table)
+	if toks.Placeholder != PlaceholderDollar {
+		return query, nil
+	}
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String(), nil
+}
+
+// Migrate applies the schema migrations which have not been applied yet, each in its own
+// transaction, and returns the number applied.
+func (toks *TokenSet) Migrate(ctx context.Context) (int, error) {
+	table, err := toks.table()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := toks.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+table+`_migrations (
+	version INTEGER NOT NULL PRIMARY KEY,
+	applied_at BIGINT NOT NULL)`); err != nil {
+		return 0, err
+	}
+	var cur int
+	if err := toks.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM `+table+`_migrations`).Scan(&cur); err != nil {
+		return 0, err
+	}
+	insert, err := toks.query(`INSERT INTO {table}_migrations (version, applied_at) VALUES (?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for i := cur; i < len(migrations); i++ {
+		tx, err := toks.db.BeginTx(ctx, nil)
+		if err != nil {
+			return count, err
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(migrations[i], table)); err != nil {
+			tx.Rollback()
+			return count, fmt.Errorf("token table migration [%d]: %w", i+1, err)
+		} else if _, err := tx.ExecContext(ctx, insert, i+1, time.Now().UnixMilli()); err != nil {
+			tx.Rollback()
+			return count, fmt.Errorf("token table migration [%d]: %w", i+1, err)
+		} else if err := tx.Commit(); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (toks *TokenSet) GetTokenInfo(ctx context.Context, key string) (*tokens.TokenInfo, error) {
+	key = tokens.FormatKey(key)
+	q, err := toks.query(`SELECT token_info, version, updated_at FROM {table} WHERE token_key = ?`)
+	if err != nil {
+		return nil, err
+	}
+	var data string
+	var version, updatedAt int64
+	if err := toks.db.QueryRowContext(ctx, q, key).Scan(&data, &version, &updatedAt); errors.Is(err, sql.ErrNoRows) {
+		return nil, tokens.NotFoundError(key)
+	} else if err != nil {
+		return nil, err
+	}
+	ti, err := tokens.ParseTokenInfo([]byte(data))
+	if err != nil {
+		return nil, err
+	}
+	ti.Version = version
+	ti.UpdatedAt = time.UnixMilli(updatedAt).UTC()
+	return ti, nil
+}
+
+// row returns the column values for `tok`. The version and update time are stored in their
+// own columns rather than the JSON.
+func row(tok *tokens.TokenInfo) (data string, expiresAt int64, err error) {
+	ti := tok.Clone()
+	if ti == nil {
+		ti = &tokens.TokenInfo{}
+	}
+	ti.Version = 0
+	ti.UpdatedAt = time.Time{}
+	if ti.Token != nil && !ti.Token.Expiry.IsZero() {
+		expiresAt = ti.Token.Expiry.UnixMilli()
+	}
+	b, err := json.Marshal(ti)
+	return string(b), expiresAt, err
+}
+
+func (toks *TokenSet) SetTokenInfo(ctx context.Context, key string, tok *tokens.TokenInfo) error {
+	key = tokens.FormatKey(key)
+	if key == "" {
+		return tokens.ErrKeyRequired
+	} else if err := checkLengths(key, tok); err != nil {
+		return err
+	}
+	q, err := toks.query(`UPDATE {table} SET service_key = ?, service_type = ?, token_info = ?,
+	expires_at = ?, version = version + 1, updated_at = ? WHERE token_key = ?`)
+	if err != nil {
+		return err
+	}
+	data, expiresAt, err := row(tok)
+	if err != nil {
+		return err
+	}
+	// Update the existing row, or insert one. Retry if another writer inserts the key first.
+	for range max(tokens.UpdateMaxAttempts, 1) {
+		res, err := toks.db.ExecContext(ctx, q, serviceKey(tok), serviceType(tok), data, expiresAt, time.Now().UnixMilli(), key)
+		if err != nil {
+			return err
+		} else if n, err := res.RowsAffected(); err != nil || n > 0 {
+			return err
+		}
+		if err = toks.insert(ctx, key, tok); err == nil || !errors.Is(err, tokens.ErrConflict) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w (%s): concurrent write", tokens.ErrConflict, key)
+}
+
+func (toks *TokenSet) CompareAndSwap(ctx context.Context, key string, old, tok *tokens.TokenInfo) error {
+	key = tokens.FormatKey(key)
+	if key == "" {
+		return tokens.ErrKeyRequired
+	} else if err := checkLengths(key, tok); err != nil {
+		return err
+	} else if old == nil {
+		return toks.insert(ctx, key, tok)
+	}
+	q, err := toks.query(`UPDATE {table} SET service_key = ?, service_type = ?, token_info = ?,
+	expires_at = ?, version = ?, updated_at = ? WHERE token_key = ? AND version = ?`)
+	if err != nil {
+		return err
+	}
+	data, expiresAt, err := row(tok)
+	if err != nil {
+		return err
+	}
+	res, err := toks.db.ExecContext(ctx, q, serviceKey(tok), serviceType(tok), data, expiresAt, old.Version+1, time.Now().UnixMilli(), key, old.Version)
+	if err != nil {
+		return err
+	} else if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		cur, _ := toks.GetTokenInfo(ctx, key)
+		return tokens.ConflictError(key, old.Version, tokens.Version(cur))
+	}
+	return nil
+}
+
+// insert adds a row for a new key at version 1. If the insert fails because the key exists,
+// it returns an error wrapping `tokens.ErrConflict`, since duplicate key errors are driver
+// specific.
+func (toks *TokenSet) insert(ctx context.Context, key string, tok *tokens.TokenInfo) error {
+	q, err := toks.query(`INSERT INTO {table} (token_key, service_key, service_type, token_info,
+	expires_at, version, updated_at) VALUES (?, ?, ?, ?, ?, 1, ?)`)
+	if err != nil {
+		return err
+	}
+	data, expiresAt, err := row(tok)
+	if err != nil {
+		return err
+	}
+	if _, err := toks.db.ExecContext(ctx, q, key, serviceKey(tok), serviceType(tok), data, expiresAt, time.Now().UnixMilli()); err != nil {
+		if cur, getErr := toks.GetTokenInfo(ctx, key); getErr == nil {
+			return tokens.ConflictError(key, 0, cur.Version)
+		}
+		return err
+	}
+	return nil
+}
+
+func (toks *TokenSet) Delete(ctx context.Context, key string) error {
+	q, err := toks.query(`DELETE FROM {table} WHERE token_key = ?`)
+	if err != nil {
+		return err
+	}
+	_, err = toks.db.ExecContext(ctx, q, tokens.FormatKey(key))
+	return err
+}
+
+// List returns the keys with `prefix`. Keys are filtered with `LIKE` and then compared exactly,
+// since `LIKE` is case-insensitive on some databases.
+func (toks *TokenSet) List(ctx context.Context, prefix string) ([]string, error) {
+	q, err := toks.query(`SELECT token_key FROM {table} WHERE token_key LIKE ? ESCAPE '!'`)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := toks.db.QueryContext(ctx, q, escapeLike(prefix)+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		} else if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// checkLengths returns an error wrapping `ErrTooLong` if `key` or the service key or type of
+// `tok` does not fit its column, rather than leaving it to the database to fail or truncate.
+func checkLengths(key string, tok *tokens.TokenInfo) error {
+	if n := utf8.RuneCountInString(key); n > MaxKeyLength {
+		return fmt.Errorf("%w (token_key): length [%d] max [%d]", ErrTooLong, n, MaxKeyLength)
+	} else if n := utf8.RuneCountInString(serviceKey(tok)); n > MaxServiceKeyLength {
+		return fmt.Errorf("%w (service_key): length [%d] max [%d]", ErrTooLong, n, MaxServiceKeyLength)
+	} else if n := utf8.RuneCountInString(serviceType(tok)); n > MaxServiceTypeLength {
+		return fmt.Errorf("%w (service_type): length [%d] max [%d]", ErrTooLong, n, MaxServiceTypeLength)
+	}
+	return nil
+}
+
+// escapeLike escapes `LIKE` pattern characters using `!`, which unlike `\` is not a string
+// escape in any common SQL dialect.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+func serviceKey(tok *tokens.TokenInfo) string {
+	if tok == nil {
+		return ""
+	}
+	return tok.ServiceKey
+}
+
+func serviceType(tok *tokens.TokenInfo) string {
+	if tok == nil {
+		return ""
+	}
+	return tok.ServiceType
+}
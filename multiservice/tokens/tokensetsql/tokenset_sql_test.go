@@ -0,0 +1,94 @@
+package tokensetsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grokify/goauth/multiservice/tokens"
+	"github.com/grokify/goauth/multiservice/tokens/tokenstest"
+	_ "modernc.org/sqlite"
+)
+
+func newTestTokenSet(t *testing.T) *TokenSet {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "tokens.db"))
+	if err != nil {
+		t.Fatalf("sql.Open(): err [%v]", err)
+	}
+	// SQLite allows one writer, so serialize connections rather than retrying busy errors.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return NewTokenSet(db)
+}
+
+func TestTokenSet(t *testing.T) {
+	tokenstest.TestTokenSet(t, func(t *testing.T) tokens.TokenSet {
+		ts := newTestTokenSet(t)
+		if _, err := ts.Migrate(context.Background()); err != nil {
+			t.Fatalf("TokenSet.Migrate(): err [%v]", err)
+		}
+		return ts
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestTokenSet(t)
+	if n, err := ts.Migrate(ctx); err != nil || n != len(migrations) {
+		t.Errorf("TokenSet.Migrate(): want [%d], got [%d] err [%v]", len(migrations), n, err)
+	}
+	if n, err := ts.Migrate(ctx); err != nil || n != 0 {
+		t.Errorf("TokenSet.Migrate() again: want [0], got [%d] err [%v]", n, err)
+	}
+	ts.Table = "tokens; DROP TABLE goauth_tokens"
+	if _, err := ts.Migrate(ctx); err == nil {
+		t.Errorf("TokenSet.Migrate(%s): want error, got nil", ts.Table)
+	}
+}
+
+var queryTests = []struct {
+	placeholder Placeholder
+	query       string
+	want        string
+}{
+	{PlaceholderQuestion, `SELECT a FROM {table} WHERE b = ? AND c = ?`, `SELECT a FROM goauth_tokens WHERE b = ? AND c = ?`},
+	{PlaceholderDollar, `SELECT a FROM {table} WHERE b = ? AND c = ?`, `SELECT a FROM goauth_tokens WHERE b = $1 AND c = $2`},
+}
+
+func TestQuery(t *testing.T) {
+	for _, tt := range queryTests {
+		ts := &TokenSet{Placeholder: tt.placeholder}
+		if got, err := ts.query(tt.query); err != nil || got != tt.want {
+			t.Errorf("TokenSet.query(%s): want [%s], got [%s] err [%v]", tt.query, tt.want, got, err)
+		}
+	}
+}
+
+func TestTokenSetTooLong(t *testing.T) {
+	ts := newTestTokenSet(t)
+	if _, err := ts.Migrate(context.Background()); err != nil {
+		t.Fatalf("TokenSet.Migrate(): err [%v]", err)
+	}
+	ctx := context.Background()
+	tests := []struct {
+		key string
+		tok *tokens.TokenInfo
+		err error
+	}{
+		{strings.Repeat("k", MaxKeyLength), &tokens.TokenInfo{}, nil},
+		{strings.Repeat("k", MaxKeyLength+1), &tokens.TokenInfo{}, ErrTooLong},
+		{"user/1", &tokens.TokenInfo{ServiceKey: strings.Repeat("s", MaxServiceKeyLength+1)}, ErrTooLong},
+		{"user/1", &tokens.TokenInfo{ServiceType: strings.Repeat("s", MaxServiceTypeLength+1)}, ErrTooLong},
+	}
+	for _, tt := range tests {
+		if err := ts.SetTokenInfo(ctx, tt.key, tt.tok); !errors.Is(err, tt.err) {
+			t.Errorf("TokenSet.SetTokenInfo(%d chars): want [%v], got [%v]", len(tt.key), tt.err, err)
+		}
+		if err := ts.CompareAndSwap(ctx, tt.key+"/cas", nil, tt.tok); tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("TokenSet.CompareAndSwap(%d chars): want [%v], got [%v]", len(tt.key), tt.err, err)
+		}
+	}
+}
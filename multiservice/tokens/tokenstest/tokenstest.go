@@ -0,0 +1,200 @@
+// Package tokenstest provides a conformance test suite for `tokens.TokenSet` implementations.
+package tokenstest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grokify/goauth/multiservice/tokens"
+	"golang.org/x/oauth2"
+)
+
+// TestTokenSet runs the conformance tests against token sets returned by `newTokenSet`, which
+// must return an empty token set for each call.
+func TestTokenSet(t *testing.T, newTokenSet func(t *testing.T) tokens.TokenSet) {
+	t.Helper()
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, ts tokens.TokenSet)
+	}{
+		{"GetNotFound", testGetNotFound},
+		{"SetGet", testSetGet},
+		{"CompareAndSwap", testCompareAndSwap},
+		{"UpdateConcurrent", testUpdateConcurrent},
+		{"Delete", testDelete},
+		{"List", testList},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) { tt.fn(t, newTokenSet(t)) })
+	}
+}
+
+func newTokenInfo(accessToken string) *tokens.TokenInfo {
+	return &tokens.TokenInfo{
+		ServiceKey:  "svc",
+		ServiceType: "oauth2",
+		Token: &oauth2.Token{
+			AccessToken:  accessToken,
+			TokenType:    "Bearer",
+			RefreshToken: "refresh-" + accessToken,
+			Expiry:       time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)},
+		Extra: map[string]any{"id_token": "id-" + accessToken, "scope": "read write"}}
+}
+
+func testGetNotFound(t *testing.T, ts tokens.TokenSet) {
+	ctx := context.Background()
+	if _, err := ts.GetTokenInfo(ctx, "missing"); !errors.Is(err, tokens.ErrNotFound) {
+		t.Errorf("TokenSet.GetTokenInfo(missing): want [%v], got [%v]", tokens.ErrNotFound, err)
+	}
+	if _, err := tokens.GetToken(ctx, ts, "missing"); !errors.Is(err, tokens.ErrNotFound) {
+		t.Errorf("tokens.GetToken(missing): want [%v], got [%v]", tokens.ErrNotFound, err)
+	}
+}
+
+func testSetGet(t *testing.T, ts tokens.TokenSet) {
+	ctx := context.Background()
+	want := newTokenInfo("a")
+	start := time.Now().Add(-time.Second)
+	if err := ts.SetTokenInfo(ctx, " user-1 ", want); err != nil {
+		t.Fatalf("TokenSet.SetTokenInfo(user-1): err [%v]", err)
+	}
+	got, err := ts.GetTokenInfo(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("TokenSet.GetTokenInfo(user-1): err [%v]", err)
+	}
+	if got.ServiceKey != want.ServiceKey || got.ServiceType != want.ServiceType || got.Token == nil ||
+		got.Token.AccessToken != want.Token.AccessToken || got.Token.RefreshToken != want.Token.RefreshToken ||
+		got.Token.TokenType != want.Token.TokenType || !got.Token.Expiry.Equal(want.Token.Expiry) {
+		t.Errorf("TokenSet.GetTokenInfo(user-1): want [%v %v], got [%v %v]", want, want.Token, got, got.Token)
+	}
+	if got.Version <= 0 {
+		t.Errorf("TokenSet.GetTokenInfo(user-1): want version > 0, got [%d]", got.Version)
+	}
+	if got.Extra["id_token"] != "id-a" || got.Extra["scope"] != "read write" {
+		t.Errorf("TokenSet.GetTokenInfo(user-1): want extra [%v], got [%v]", want.Extra, got.Extra)
+	}
+	if got.UpdatedAt.Before(start) || got.UpdatedAt.After(time.Now().Add(time.Second)) {
+		t.Errorf("TokenSet.GetTokenInfo(user-1): want updated at after [%v], got [%v]", start, got.UpdatedAt)
+	}
+	if tok, err := tokens.GetToken(ctx, ts, "user-1"); err != nil || tok.Extra("id_token") != "id-a" {
+		t.Errorf("tokens.GetToken(user-1).Extra(id_token): want [id-a], got [%v] err [%v]", tok, err)
+	}
+	got.Token.AccessToken = "changed"
+	got.Extra["id_token"] = "changed"
+	if again, err := tokens.GetToken(ctx, ts, "user-1"); err != nil || again.AccessToken != "a" || again.Extra("id_token") != "id-a" {
+		t.Errorf("tokens.GetToken(user-1): want [a] after changing a returned token, got [%v] err [%v]", again, err)
+	}
+	if err := ts.SetTokenInfo(ctx, "user-1", newTokenInfo("b")); err != nil {
+		t.Fatalf("TokenSet.SetTokenInfo(user-1): err [%v]", err)
+	} else if next, err := ts.GetTokenInfo(ctx, "user-1"); err != nil || next.Token.AccessToken != "b" || next.Version <= got.Version ||
+		next.UpdatedAt.Before(got.UpdatedAt) {
+		t.Errorf("TokenSet.SetTokenInfo(user-1): want [b] with version > [%d], got [%v] err [%v]", got.Version, next, err)
+	}
+	if err := ts.SetTokenInfo(ctx, " ", newTokenInfo("c")); err == nil {
+		t.Errorf("TokenSet.SetTokenInfo(empty key): want error, got nil")
+	}
+}
+
+func testCompareAndSwap(t *testing.T, ts tokens.TokenSet) {
+	ctx := context.Background()
+	if err := ts.CompareAndSwap(ctx, "k", nil, newTokenInfo("a")); err != nil {
+		t.Fatalf("TokenSet.CompareAndSwap(k, nil): err [%v]", err)
+	}
+	if err := ts.CompareAndSwap(ctx, "k", nil, newTokenInfo("x")); !errors.Is(err, tokens.ErrConflict) {
+		t.Errorf("TokenSet.CompareAndSwap(k, nil) existing: want [%v], got [%v]", tokens.ErrConflict, err)
+	}
+	cur, err := ts.GetTokenInfo(ctx, "k")
+	if err != nil {
+		t.Fatalf("TokenSet.GetTokenInfo(k): err [%v]", err)
+	}
+	if err := ts.CompareAndSwap(ctx, "k", cur, newTokenInfo("b")); err != nil {
+		t.Fatalf("TokenSet.CompareAndSwap(k, current): err [%v]", err)
+	}
+	if err := ts.CompareAndSwap(ctx, "k", cur, newTokenInfo("x")); !errors.Is(err, tokens.ErrConflict) {
+		t.Errorf("TokenSet.CompareAndSwap(k, stale): want [%v], got [%v]", tokens.ErrConflict, err)
+	}
+	if got, err := tokens.GetToken(ctx, ts, "k"); err != nil || got.AccessToken != "b" {
+		t.Errorf("tokens.GetToken(k): want [b], got [%v] err [%v]", got, err)
+	}
+	if err := ts.CompareAndSwap(ctx, "missing", cur, newTokenInfo("x")); !errors.Is(err, tokens.ErrConflict) {
+		t.Errorf("TokenSet.CompareAndSwap(missing, old): want [%v], got [%v]", tokens.ErrConflict, err)
+	}
+}
+
+// testUpdateConcurrent increments a counter stored in the access token from several
+// goroutines. Lost updates mean compare-and-swap is not atomic.
+func testUpdateConcurrent(t *testing.T, ts tokens.TokenSet) {
+	ctx := context.Background()
+	const workers, increments = 4, 10
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for range increments {
+				for {
+					_, err := tokens.Update(ctx, ts, "counter", func(cur *tokens.TokenInfo) (*tokens.TokenInfo, error) {
+						n := 0
+						if cur != nil {
+							n, _ = strconv.Atoi(cur.Token.AccessToken)
+						}
+						return newTokenInfo(strconv.Itoa(n + 1)), nil
+					})
+					if err == nil {
+						break
+					} else if !errors.Is(err, tokens.ErrConflict) {
+						t.Errorf("tokens.Update(counter): err [%v]", err)
+						return
+					}
+				}
+			}
+		})
+	}
+	wg.Wait()
+	want := strconv.Itoa(workers * increments)
+	if got, err := tokens.GetToken(ctx, ts, "counter"); err != nil || got.AccessToken != want {
+		t.Errorf("tokens.Update(counter): want [%s], got [%v] err [%v]", want, got, err)
+	}
+}
+
+func testDelete(t *testing.T, ts tokens.TokenSet) {
+	ctx := context.Background()
+	if err := ts.SetTokenInfo(ctx, "k", newTokenInfo("a")); err != nil {
+		t.Fatalf("TokenSet.SetTokenInfo(k): err [%v]", err)
+	} else if err := ts.Delete(ctx, "k"); err != nil {
+		t.Errorf("TokenSet.Delete(k): err [%v]", err)
+	} else if _, err := ts.GetTokenInfo(ctx, "k"); !errors.Is(err, tokens.ErrNotFound) {
+		t.Errorf("TokenSet.Delete(k): want [%v], got [%v]", tokens.ErrNotFound, err)
+	}
+	if err := ts.Delete(ctx, "missing"); err != nil {
+		t.Errorf("TokenSet.Delete(missing): want nil, got [%v]", err)
+	}
+}
+
+func testList(t *testing.T, ts tokens.TokenSet) {
+	ctx := context.Background()
+	for _, key := range []string{"user/2", "user/1", "app/1", "user*"} {
+		if err := ts.SetTokenInfo(ctx, key, newTokenInfo(key)); err != nil {
+			t.Fatalf("TokenSet.SetTokenInfo(%s): err [%v]", key, err)
+		}
+	}
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"app/1", "user*", "user/1", "user/2"}},
+		{"user/", []string{"user/1", "user/2"}},
+		{"user*", []string{"user*"}},
+		{"none", []string{}},
+	}
+	for _, tt := range tests {
+		if got, err := ts.List(ctx, tt.prefix); err != nil {
+			t.Errorf("TokenSet.List(%s): err [%v]", tt.prefix, err)
+		} else if !slices.Equal(got, tt.want) {
+			t.Errorf("TokenSet.List(%s): want [%v], got [%v]", tt.prefix, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package multiservice
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/oauth2"
+
+	"github.com/grokify/goauth/authutil/oauthstate"
+	"github.com/grokify/goauth/scim"
+)
+
+const (
+	// ParamReturnTo is the connect query parameter for the URL path to return to after the
+	// callback.
+	ParamReturnTo = "return_to"
+
+	stateDataTenantID = "tenantId"
+)
+
+var (
+	// ErrAuthorization is returned by the callback handler when the provider returns an error,
+	// e.g. `access_denied` if the user does not authorize the connection.
+	ErrAuthorization = errors.New("oauth2 authorization failed")
+)
+
+// WebOptions configures the handlers returned by `OAuth2Manager.ConnectHandler()` and
+// `OAuth2Manager.CallbackHandler()`.
+type WebOptions struct {
+	// State issues and verifies the signed, single use state, e.g. from
+	// `oauthstate.NewManager()`. The state carries the `TenantID` and `return_to` path, which
+	// are readable in the authorization URL unless the manager has an `oauthstate.Store`.
+	State *oauthstate.Manager
+	// BaseURL, e.g. `https://example.com`, is used to build the callback URL
+	// `{BaseURL}/callback/{service}` for services without a configured redirect URL.
+	BaseURL string
+	// TenantID returns the signed in tenant or user the connection is for. If nil or empty,
+	// the callback is a login, and `LoginTenantID` is used.
+	TenantID func(r *http.Request) (string, error)
+	// LoginTenantID returns the tenant for a login with the provider account `identity`,
+	// which is nil for providers without an `authutil.OAuth2Util`, e.g. by finding or creating
+	// an application user.
+	LoginTenantID func(r *http.Request, identity *scim.User) (string, error)
+	// OnConnect is called after the connection is stored, e.g. to start a session. If nil, the
+	// browser is redirected to `returnTo`, or the connection is written as JSON if empty.
+	OnConnect func(w http.ResponseWriter, r *http.Request, conn *Connection, returnTo string)
+	// OnError is called if connecting fails. If nil, the error is logged with `slog` and the
+	// status text is written with status 400 for request errors and 500 otherwise, since errors
+	// may include token endpoint responses.
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// RegisterHandlers registers `GET /connect/{service}` and `GET /callback/{service}` on `mux`.
+func (cb *OAuth2Manager) RegisterHandlers(mux *http.ServeMux, opts *WebOptions) {
+	mux.Handle("GET /connect/{service}", cb.ConnectHandler(opts))
+	mux.Handle("GET /callback/{service}", cb.CallbackHandler(opts))
+}
+
+// ConnectHandler redirects to the authorization URL of the `{service}` path value with a state
+// from `WebOptions.State` and a PKCE challenge, plus a nonce for `openid` scopes. The state is
+// bound to the browser with a cookie. A relative `return_to` query path is returned to after
+// the callback.
+func (cb *OAuth2Manager) ConnectHandler(opts *WebOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		service := r.PathValue("service")
+		conf, err := cb.webConfig(opts, service)
+		if err != nil {
+			opts.error(w, r, err)
+			return
+		}
+		st := &oauthstate.State{
+			Provider:  service,
+			ReturnURL: returnTo(r.URL.Query().Get(ParamReturnTo))}
+		if opts.TenantID != nil {
+			if tenantID, err := opts.TenantID(r); err != nil {
+				opts.error(w, r, err)
+				return
+			} else if tenantID != "" {
+				st.Data = map[string]string{stateDataTenantID: tenantID}
+			}
+		}
+		state, err := opts.State.Issue(r.Context(), st)
+		if err != nil {
+			opts.error(w, r, err)
+			return
+		}
+		opts.State.SetCookie(w, state, cookieOptions(conf.RedirectURL))
+		authOpts := []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(st.Verifier)}
+		if slices.Contains(conf.Scopes, "openid") {
+			authOpts = append(authOpts, oauth2.SetAuthURLParam("nonce", st.Nonce))
+		}
+		http.Redirect(w, r, conf.AuthCodeURL(state, authOpts...), http.StatusFound)
+	})
+}
+
+// CallbackHandler verifies the state and its cookie, exchanges the code, checks the nonce of
+// the ID token if one is returned, reads the provider account identity, stores the connection
+// and calls `WebOptions.OnConnect`.
+func (cb *OAuth2Manager) CallbackHandler(opts *WebOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		service := r.PathValue("service")
+		conf, err := cb.webConfig(opts, service)
+		if err != nil {
+			opts.error(w, r, err)
+			return
+		}
+		q := r.URL.Query()
+		if e := q.Get("error"); e != "" {
+			oauthstate.ClearCookie(w, cookieOptions(conf.RedirectURL))
+			opts.error(w, r, fmt.Errorf("%w (%s): %s", ErrAuthorization, e, q.Get("error_description")))
+			return
+		}
+		st, err := opts.State.VerifyRequest(w, r, cookieOptions(conf.RedirectURL))
+		if err != nil {
+			opts.error(w, r, err)
+			return
+		} else if st.Provider != service {
+			opts.error(w, r, fmt.Errorf("%w: service mismatch", oauthstate.ErrInvalidState))
+			return
+		}
+		// The connection is for the tenant which started the flow, which must still be the
+		// signed in tenant, so a state from another session cannot connect to this one.
+		if opts.TenantID != nil {
+			if tenantID, err := opts.TenantID(r); err != nil {
+				opts.error(w, r, err)
+				return
+			} else if tenantID != st.Data[stateDataTenantID] {
+				opts.error(w, r, fmt.Errorf("%w: tenant mismatch", oauthstate.ErrInvalidState))
+				return
+			}
+		}
+		cfgMore, err := cb.ConfigSet.Get(service)
+		if err != nil {
+			opts.error(w, r, err)
+			return
+		}
+		ctx := r.Context()
+		tok, err := conf.Exchange(ctx, q.Get("code"), oauth2.VerifierOption(st.Verifier))
+		if err != nil {
+			opts.error(w, r, err)
+			return
+		} else if idToken, ok := tok.Extra("id_token").(string); ok && idToken != "" {
+			if err := st.VerifyNonce(idToken); err != nil {
+				opts.error(w, r, err)
+				return
+			}
+		}
+		identity, err := cb.identity(ctx, cfgMore, tok)
+		if err != nil {
+			opts.error(w, r, err)
+			return
+		}
+		tenantID := st.Data[stateDataTenantID]
+		if tenantID == "" && opts.LoginTenantID != nil {
+			if tenantID, err = opts.LoginTenantID(r, identity); err != nil {
+				opts.error(w, r, err)
+				return
+			}
+		}
+		if strings.TrimSpace(tenantID) == "" {
+			opts.error(w, r, ErrTenantIDRequired)
+			return
+		}
+		conn, err := cb.storeConnection(ctx, tenantID, service, cfgMore, tok, identity)
+		if err != nil {
+			opts.error(w, r, err)
+			return
+		}
+		if opts.OnConnect != nil {
+			opts.OnConnect(w, r, conn, st.ReturnURL)
+		} else if st.ReturnURL != "" {
+			http.Redirect(w, r, st.ReturnURL, http.StatusFound)
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(conn)
+		}
+	})
+}
+
+// webConfig returns the OAuth 2.0 config for a service, with the callback URL from
+// `BaseURL` if no redirect URL is configured.
+func (cb *OAuth2Manager) webConfig(opts *WebOptions, service string) (*oauth2.Config, error) {
+	if opts == nil || opts.State == nil {
+		return nil, errors.New("web options state manager is required")
+	} else if cb.ConfigSet == nil {
+		return nil, fmt.Errorf("OAuth2Manager.ConfigSet == nil")
+	} else if cb.TokenSet == nil {
+		return nil, fmt.Errorf("OAuth2Manager.TokenSet == nil")
+	}
+	cfgMore, err := cb.ConfigSet.Get(service)
+	if err != nil {
+		return nil, err
+	}
+	conf := cfgMore.Config()
+	if conf.RedirectURL == "" && opts.BaseURL != "" {
+		conf.RedirectURL = strings.TrimRight(opts.BaseURL, "/") + "/callback/" + url.PathEscape(service)
+	}
+	return conf, nil
+}
+
+func (opts *WebOptions) error(w http.ResponseWriter, r *http.Request, err error) {
+	if opts != nil && opts.OnError != nil {
+		opts.OnError(w, r, err)
+		return
+	}
+	status, level := http.StatusInternalServerError, slog.LevelError
+	if errors.Is(err, oauthstate.ErrInvalidState) || errors.Is(err, ErrAuthorization) || errors.Is(err, ErrTenantIDRequired) {
+		status, level = http.StatusBadRequest, slog.LevelWarn
+	}
+	slog.Log(r.Context(), level, "oauth2 connect failed", "path", r.URL.Path, "status", status, "error", err.Error())
+	http.Error(w, http.StatusText(status), status)
+}
+
+// returnTo returns `s` if it is a local path, re-serialized as its path and query, so the
+// callback cannot redirect to another site. Values with a scheme, host, backslash or control
+// characters, which browsers may normalize to another host, return an empty string.
+func returnTo(s string) string {
+	if strings.ContainsRune(s, '\\') || strings.ContainsFunc(s, unicode.IsControl) {
+		return ""
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.User != nil || u.Opaque != "" ||
+		!strings.HasPrefix(u.EscapedPath(), "/") || strings.HasPrefix(u.EscapedPath(), "//") {
+		return ""
+	}
+	return (&url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery}).String()
+}
+
+// cookieOptions binds the state cookie to the path of the redirect URL, so it is only sent to
+// the callback.
+func cookieOptions(redirectURL string) oauthstate.CookieOptions {
+	opts := oauthstate.CookieOptions{Path: "/", Secure: strings.HasPrefix(redirectURL, "https://")}
+	if u, err := url.Parse(redirectURL); err == nil && u.Path != "" {
+		opts.Path = u.Path
+	}
+	return opts
+}
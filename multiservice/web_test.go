@@ -0,0 +1,266 @@
+package multiservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/goauth/authutil/oauthstate"
+	"github.com/grokify/goauth/scim"
+)
+
+// newTestProvider returns an authorization server which redirects straight back with a code,
+// and a token endpoint which checks the PKCE verifier against the challenge. If `idTokenNonce`
+// is set, the token response includes an ID token with the nonce it returns for the nonce of
+// the authorization request.
+func newTestProvider(t *testing.T, idTokenNonce func(nonce string) string) *httptest.Server {
+	challenges := map[string]string{}
+	nonces := map[string]string{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		code := "code-" + q.Get("state")[:8]
+		challenges[code] = q.Get("code_challenge")
+		nonces[code] = q.Get("nonce")
+		http.Redirect(w, r, q.Get("redirect_uri")+"?code="+code+"&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if challenges[r.FormValue("code")] != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			t.Errorf("token request: PKCE verifier does not match challenge")
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		resp := map[string]any{"access_token": "at", "token_type": "Bearer", "expires_in": 3600, "scope": "email"}
+		if idTokenNonce != nil {
+			idToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+				"sub": "g-1", "nonce": idTokenNonce(nonces[r.FormValue("code")])}).SignedString([]byte("provider-key"))
+			if err != nil {
+				t.Errorf("jwt.Token.SignedString(): err [%v]", err)
+			}
+			resp["id_token"] = idToken
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+	svr := httptest.NewServer(mux)
+	t.Cleanup(svr.Close)
+	return svr
+}
+
+func TestWebHandlers(t *testing.T) {
+	provider := newTestProvider(t, nil)
+	mgr := NewOAuth2Manager()
+	mgr.ConfigSet.ConfigMoreMap["google"] = &O2ConfigMore{
+		Provider: "google", ClientID: "id", AuthURI: provider.URL + "/authorize", TokenURI: provider.URL + "/token"}
+	mgr.ClientUtilFunc = func(provider string) (authutil.OAuth2Util, error) { return &testClientUtil{id: "g-1"}, nil }
+	states, err := oauthstate.NewManager(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatalf("oauthstate.NewManager(): err [%v]", err)
+	}
+	opts := &WebOptions{
+		State:    states,
+		TenantID: func(r *http.Request) (string, error) { return r.Header.Get("X-User"), nil },
+		LoginTenantID: func(r *http.Request, identity *scim.User) (string, error) {
+			return "login-" + identity.ID, nil
+		}}
+	mux := http.NewServeMux()
+	mgr.RegisterHandlers(mux, opts)
+	mux.HandleFunc("/done", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("done")) })
+	app := httptest.NewServer(mux)
+	defer app.Close()
+	opts.BaseURL = app.URL
+
+	tests := []struct {
+		user     string
+		returnTo string
+		want     string
+	}{
+		{"user-1", "/done", "user-1"},
+		{"", "https://evil.example.com/", "login-g-1"},
+	}
+	for _, tt := range tests {
+		jar, _ := cookiejar.New(nil)
+		clt := &http.Client{Jar: jar}
+		req, _ := http.NewRequest(http.MethodGet, app.URL+"/connect/google?return_to="+url.QueryEscape(tt.returnTo), nil)
+		req.Header.Set("X-User", tt.user)
+		resp, err := clt.Do(req)
+		if err != nil {
+			t.Fatalf("GET /connect/google: err [%v]", err)
+		}
+		body := new(bytes.Buffer)
+		_, _ = body.ReadFrom(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET /connect/google (%s): want [200], got [%d] [%s]", tt.user, resp.StatusCode, body)
+		} else if tt.returnTo == "/done" && body.String() != "done" {
+			t.Errorf("GET /connect/google (%s): want redirect to [/done], got [%s]", tt.user, body)
+		} else if tt.returnTo != "/done" {
+			conn := &Connection{}
+			if err := json.Unmarshal(body.Bytes(), conn); err != nil || conn.TenantID != tt.want {
+				t.Errorf("GET /connect/google (%s): want connection JSON for [%s], got [%s]", tt.user, tt.want, body)
+			}
+		}
+		if conn, err := mgr.GetConnection(context.Background(), tt.want, "google", "g-1"); err != nil || conn.Scopes[0] != "email" {
+			t.Errorf("OAuth2Manager.GetConnection(%s): want [email] scope, got [%v] err [%v]", tt.want, conn, err)
+		}
+	}
+
+	// A callback without the browser's cookie, or with a modified state, is rejected.
+	callback := func(state string) error {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/callback/google?code=x&state="+url.QueryEscape(state), nil)
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			return errors.New(rec.Body.String())
+		}
+		return nil
+	}
+	state, err := states.Issue(context.Background(), &oauthstate.State{Provider: "google"})
+	if err != nil {
+		t.Fatalf("oauthstate.Manager.Issue(): err [%v]", err)
+	} else if err := callback(state); err != nil {
+		t.Errorf("GET /callback/google without cookie: want [400], got [%v]", err)
+	}
+	if err := callback("e30." + state[len(state)-43:]); err != nil {
+		t.Errorf("GET /callback/google modified state: want [400], got [%v]", err)
+	}
+}
+
+func TestWebHandlersNonce(t *testing.T) {
+	tests := []struct {
+		idTokenNonce func(nonce string) string
+		wantStatus   int
+	}{
+		{func(nonce string) string { return nonce }, http.StatusOK},
+		{func(nonce string) string { return "other" }, http.StatusBadRequest},
+		{func(nonce string) string { return "" }, http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		provider := newTestProvider(t, tt.idTokenNonce)
+		mgr := NewOAuth2Manager()
+		mgr.ConfigSet.ConfigMoreMap["google"] = &O2ConfigMore{
+			Provider: "google", ClientID: "id", AuthURI: provider.URL + "/authorize", TokenURI: provider.URL + "/token",
+			Scopes: []string{"openid", "email"}}
+		mgr.ClientUtilFunc = func(provider string) (authutil.OAuth2Util, error) { return &testClientUtil{id: "g-1"}, nil }
+		states, err := oauthstate.NewManager(bytes.Repeat([]byte("k"), 32))
+		if err != nil {
+			t.Fatalf("oauthstate.NewManager(): err [%v]", err)
+		}
+		opts := &WebOptions{State: states, TenantID: func(r *http.Request) (string, error) { return "user-1", nil }}
+		mux := http.NewServeMux()
+		mgr.RegisterHandlers(mux, opts)
+		app := httptest.NewServer(mux)
+		opts.BaseURL = app.URL
+
+		jar, _ := cookiejar.New(nil)
+		resp, err := (&http.Client{Jar: jar}).Get(app.URL + "/connect/google")
+		if err != nil {
+			t.Fatalf("GET /connect/google: err [%v]", err)
+		}
+		resp.Body.Close()
+		app.Close()
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("GET /connect/google (%d): want [%d], got [%d]", i, tt.wantStatus, resp.StatusCode)
+		}
+	}
+}
+
+func TestWebHandlersSession(t *testing.T) {
+	provider := newTestProvider(t, nil)
+	mgr := NewOAuth2Manager()
+	mgr.ConfigSet.ConfigMoreMap["google"] = &O2ConfigMore{
+		Provider: "google", ClientID: "id", AuthURI: provider.URL + "/authorize", TokenURI: provider.URL + "/token"}
+	mgr.ClientUtilFunc = func(provider string) (authutil.OAuth2Util, error) { return &testClientUtil{id: "g-1"}, nil }
+	states, err := oauthstate.NewManager(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatalf("oauthstate.NewManager(): err [%v]", err)
+	}
+	opts := &WebOptions{State: states, TenantID: func(r *http.Request) (string, error) { return r.Header.Get("X-User"), nil }}
+	mux := http.NewServeMux()
+	mgr.RegisterHandlers(mux, opts)
+	app := httptest.NewServer(mux)
+	defer app.Close()
+	opts.BaseURL = app.URL
+
+	// A state issued to one signed in user is rejected when another user is signed in at the
+	// callback.
+	jar, _ := cookiejar.New(nil)
+	clt := &http.Client{Jar: jar, CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if req.URL.Path == "/callback/google" {
+			req.Header.Set("X-User", "user-2")
+		}
+		return nil
+	}}
+	req, _ := http.NewRequest(http.MethodGet, app.URL+"/connect/google", nil)
+	req.Header.Set("X-User", "user-1")
+	resp, err := clt.Do(req)
+	if err != nil {
+		t.Fatalf("GET /connect/google: err [%v]", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET /connect/google other tenant: want [400], got [%d]", resp.StatusCode)
+	}
+	if _, err := mgr.GetConnection(context.Background(), "user-2", "google", "g-1"); err == nil {
+		t.Errorf("OAuth2Manager.GetConnection(user-2): want no connection, got nil err")
+	}
+
+	// A provider error clears the state cookie and does not echo the error description.
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/callback/google?error=access_denied&error_description=internal-detail", nil))
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusBadRequest || len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Errorf("GET /callback/google error: want [400] and cookie cleared, got [%d] cookies [%v]", rec.Code, cookies)
+	}
+	if body := rec.Body.String(); body != http.StatusText(http.StatusBadRequest)+"\n" {
+		t.Errorf("GET /callback/google error: want body [%s], got [%s]", http.StatusText(http.StatusBadRequest), body)
+	}
+}
+
+func TestWebOptionsError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	(&WebOptions{}).error(rec, httptest.NewRequest(http.MethodGet, "/callback/google", nil),
+		errors.New(`oauth2: cannot fetch token: {"error":"server_error","detail":"db password"}`))
+	if rec.Code != http.StatusInternalServerError || rec.Body.String() != http.StatusText(http.StatusInternalServerError)+"\n" {
+		t.Errorf("WebOptions.error(): want [500 %s], got [%d %s]", http.StatusText(http.StatusInternalServerError), rec.Code, rec.Body.String())
+	}
+}
+
+var returnToTests = []struct {
+	v    string
+	want string
+}{
+	{"/done", "/done"},
+	{"/done?tab=1#frag", "/done?tab=1"},
+	{"", ""},
+	{"done", ""},
+	{"//evil.com", ""},
+	{"/\t/evil.com", ""},
+	{"/\n/evil.com", ""},
+	{"/\r\n/evil.com", ""},
+	{"/\\evil.com", ""},
+	{"\\/evil.com", ""},
+	{"/%2F/evil.com", "/%2F/evil.com"},
+	{"https://evil.com/", ""},
+	{"javascript:alert(1)", ""},
+}
+
+func TestReturnTo(t *testing.T) {
+	for _, tt := range returnToTests {
+		if got := returnTo(tt.v); got != tt.want {
+			t.Errorf("returnTo(%q): want [%s], got [%s]", tt.v, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/grokify/goauth"
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/mogo/net/http/httputilmore"
+	"github.com/grokify/mogo/type/maputil"
+	"golang.org/x/oauth2"
+)
+
+// MetadataOpenIDConnectURL is the `CredentialsOAuth2.Metadata` key used for `openIdConnectUrl`.
+const MetadataOpenIDConnectURL = "openIdConnectUrl"
+
+const headerCookie = "Cookie"
+
+// CredentialsSet returns a `goauth.CredentialsSet` skeleton with one account per security
+// scheme, keyed by scheme name. Secrets such as client secrets, API keys and passwords are
+// left empty to be filled in. `mutualTLS` schemes are not represented and are skipped.
+func (doc *Document) CredentialsSet() (*goauth.CredentialsSet, error) {
+	set := &goauth.CredentialsSet{Credentials: map[string]goauth.Credentials{}}
+	svrURL := doc.ServerURL()
+	for _, name := range maputil.Keys(doc.Components.SecuritySchemes) {
+		ss := doc.Components.SecuritySchemes[name]
+		if ss.Type == SchemeTypeMutualTLS {
+			continue
+		}
+		if creds, err := ss.Credentials(svrURL); err != nil {
+			return nil, fmt.Errorf("security scheme (%s): %w", name, err)
+		} else {
+			set.Credentials[name] = creds
+		}
+	}
+	return set, nil
+}
+
+// Credentials returns a `goauth.Credentials` skeleton for the security scheme.
+func (ss SecurityScheme) Credentials(serverURL string) (goauth.Credentials, error) {
+	switch ss.Type {
+	case SchemeTypeAPIKey:
+		if strings.TrimSpace(ss.Name) == "" {
+			return goauth.Credentials{}, errors.New("apiKey name is empty")
+		}
+		hq := &goauth.CredentialsHeaderQuery{ServerURL: serverURL}
+		switch ss.In {
+		case InHeader:
+			hq.Header = http.Header{}
+			hq.Header.Set(ss.Name, "")
+		case InQuery:
+			hq.Query = map[string][]string{ss.Name: {""}}
+		case InCookie:
+			hq.Header = http.Header{}
+			hq.Header.Set(headerCookie, ss.Name+"=")
+		default:
+			return goauth.Credentials{}, fmt.Errorf("apiKey location not supported (%s)", ss.In)
+		}
+		return goauth.Credentials{Type: goauth.TypeHeaderQuery, HeaderQuery: hq}, nil
+	case SchemeTypeHTTP:
+		switch strings.ToLower(ss.Scheme) {
+		case HTTPSchemeBasic:
+			return goauth.Credentials{
+				Type:  goauth.TypeBasic,
+				Basic: &goauth.CredentialsBasicAuth{ServerURL: serverURL}}, nil
+		case HTTPSchemeBearer:
+			hq := &goauth.CredentialsHeaderQuery{ServerURL: serverURL, Header: http.Header{}}
+			hq.Header.Set(httputilmore.HeaderAuthorization, authutil.TokenBearer+" ")
+			return goauth.Credentials{Type: goauth.TypeHeaderQuery, HeaderQuery: hq}, nil
+		default:
+			return goauth.Credentials{}, fmt.Errorf("http scheme not supported (%s)", ss.Scheme)
+		}
+	case SchemeTypeOAuth2:
+		if ss.Flows == nil {
+			return goauth.Credentials{}, errors.New("oauth2 flows are empty")
+		}
+		grantType, flow := ss.Flows.Preferred()
+		if flow == nil {
+			return goauth.Credentials{}, errors.New("oauth2 flows are empty")
+		}
+		return goauth.Credentials{
+			Type: goauth.TypeOAuth2,
+			OAuth2: &goauth.CredentialsOAuth2{
+				ServerURL: serverURL,
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  flow.AuthorizationURL,
+					TokenURL: flow.TokenURL},
+				Scopes:    maputil.Keys(flow.Scopes),
+				GrantType: grantType}}, nil
+	case SchemeTypeOpenIDConnect:
+		return goauth.Credentials{
+			Type: goauth.TypeOAuth2,
+			OAuth2: &goauth.CredentialsOAuth2{
+				ServerURL: serverURL,
+				Scopes:    []string{"openid"},
+				GrantType: authutil.GrantTypeAuthorizationCode,
+				Metadata:  map[string]string{MetadataOpenIDConnectURL: ss.OpenIDConnectURL}}}, nil
+	default:
+		return goauth.Credentials{}, fmt.Errorf("security scheme type not supported (%s)", ss.Type)
+	}
+}
+
+// Preferred returns the OAuth 2.0 grant type and flow to use for a credentials skeleton,
+// preferring `authorizationCode`, then `clientCredentials`, `password` and `implicit`.
+// Implicit flows are returned with an empty grant type as they have no token request.
+func (flows OAuthFlows) Preferred() (string, *OAuthFlow) {
+	switch {
+	case flows.AuthorizationCode != nil:
+		return authutil.GrantTypeAuthorizationCode, flows.AuthorizationCode
+	case flows.ClientCredentials != nil:
+		return authutil.GrantTypeClientCredentials, flows.ClientCredentials
+	case flows.Password != nil:
+		return authutil.GrantTypePassword, flows.Password
+	case flows.Implicit != nil:
+		return "", flows.Implicit
+	default:
+		return "", nil
+	}
+}
@@ -0,0 +1,215 @@
+// Package openapi reads the `securitySchemes` declared in an OpenAPI 3.x document
+// to generate `goauth.CredentialsSet` skeletons and to validate that credentials
+// satisfy an operation's security requirements.
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SchemeTypeAPIKey        = "apiKey"
+	SchemeTypeHTTP          = "http"
+	SchemeTypeMutualTLS     = "mutualTLS"
+	SchemeTypeOAuth2        = "oauth2"
+	SchemeTypeOpenIDConnect = "openIdConnect"
+
+	InHeader = "header"
+	InQuery  = "query"
+	InCookie = "cookie"
+
+	HTTPSchemeBasic  = "basic"
+	HTTPSchemeBearer = "bearer"
+)
+
+// Document is the subset of an OpenAPI 3.x document used for security processing.
+type Document struct {
+	OpenAPI    string                `json:"openapi,omitempty"`
+	Servers    []Server              `json:"servers,omitempty"`
+	Paths      map[string]PathItem   `json:"paths,omitempty"`
+	Components Components            `json:"components,omitempty"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Server struct {
+	URL         string `json:"url,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is described here: https://spec.openapis.org/oas/v3.1.0#security-scheme-object .
+type SecurityScheme struct {
+	Type             string      `json:"type,omitempty"`
+	Description      string      `json:"description,omitempty"`
+	Name             string      `json:"name,omitempty"`
+	In               string      `json:"in,omitempty"`
+	Scheme           string      `json:"scheme,omitempty"`
+	BearerFormat     string      `json:"bearerFormat,omitempty"`
+	Flows            *OAuthFlows `json:"flows,omitempty"`
+	OpenIDConnectURL string      `json:"openIdConnectUrl,omitempty"`
+}
+
+type OAuthFlows struct {
+	Implicit          *OAuthFlow `json:"implicit,omitempty"`
+	Password          *OAuthFlow `json:"password,omitempty"`
+	ClientCredentials *OAuthFlow `json:"clientCredentials,omitempty"`
+	AuthorizationCode *OAuthFlow `json:"authorizationCode,omitempty"`
+}
+
+type OAuthFlow struct {
+	AuthorizationURL string            `json:"authorizationUrl,omitempty"`
+	TokenURL         string            `json:"tokenUrl,omitempty"`
+	RefreshURL       string            `json:"refreshUrl,omitempty"`
+	Scopes           map[string]string `json:"scopes,omitempty"`
+}
+
+// SecurityRequirement maps security scheme names to required scopes. All schemes
+// in a requirement must be satisfied.
+type SecurityRequirement map[string][]string
+
+type PathItem struct {
+	Get     *Operation `json:"get,omitempty"`
+	Put     *Operation `json:"put,omitempty"`
+	Post    *Operation `json:"post,omitempty"`
+	Delete  *Operation `json:"delete,omitempty"`
+	Options *Operation `json:"options,omitempty"`
+	Head    *Operation `json:"head,omitempty"`
+	Patch   *Operation `json:"patch,omitempty"`
+	Trace   *Operation `json:"trace,omitempty"`
+}
+
+// Operations returns the path item's operations keyed by upper case HTTP method.
+func (pi PathItem) Operations() map[string]*Operation {
+	ops := map[string]*Operation{}
+	for method, op := range map[string]*Operation{
+		http.MethodGet:     pi.Get,
+		http.MethodPut:     pi.Put,
+		http.MethodPost:    pi.Post,
+		http.MethodDelete:  pi.Delete,
+		http.MethodOptions: pi.Options,
+		http.MethodHead:    pi.Head,
+		http.MethodPatch:   pi.Patch,
+		http.MethodTrace:   pi.Trace,
+	} {
+		if op != nil {
+			ops[method] = op
+		}
+	}
+	return ops
+}
+
+type Operation struct {
+	OperationID string `json:"operationId,omitempty"`
+	Summary     string `json:"summary,omitempty"`
+	// Security is a pointer so an explicit empty list, which removes the
+	// document level requirement, can be distinguished from an omitted one.
+	Security *[]SecurityRequirement `json:"security,omitempty"`
+}
+
+// ReadFile reads an OpenAPI 3.x document in JSON or YAML format.
+func ReadFile(name string) (*Document, error) {
+	if b, err := os.ReadFile(name); err != nil {
+		return nil, err
+	} else {
+		return Parse(b)
+	}
+}
+
+// Parse parses an OpenAPI 3.x document in JSON or YAML format.
+func Parse(b []byte) (*Document, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] != '{' {
+		var v any
+		if err := yaml.Unmarshal(b, &v); err != nil {
+			return nil, err
+		} else if jb, err := json.Marshal(stringKeys(v)); err != nil {
+			return nil, err
+		} else {
+			b = jb
+		}
+	}
+	doc := &Document{}
+	if err := json.Unmarshal(b, doc); err != nil {
+		return nil, err
+	} else if !strings.HasPrefix(strings.TrimSpace(doc.OpenAPI), "3.") {
+		return nil, fmt.Errorf("openapi version not supported (%s)", doc.OpenAPI)
+	}
+	return doc, nil
+}
+
+// stringKeys converts YAML maps with non-string keys, such as response codes,
+// to `map[string]any` so they can be marshaled as JSON.
+func stringKeys(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, vi := range t {
+			t[k] = stringKeys(vi)
+		}
+		return t
+	case map[any]any:
+		m := map[string]any{}
+		for k, vi := range t {
+			m[fmt.Sprintf("%v", k)] = stringKeys(vi)
+		}
+		return m
+	case []any:
+		for i, vi := range t {
+			t[i] = stringKeys(vi)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// ServerURL returns the first server URL, if any.
+func (doc *Document) ServerURL() string {
+	for _, svr := range doc.Servers {
+		if u := strings.TrimSpace(svr.URL); u != "" {
+			return u
+		}
+	}
+	return ""
+}
+
+// Operation returns the operation for an HTTP method and path template, e.g. `GET` and `/users/{id}`.
+func (doc *Document) Operation(method, path string) (*Operation, error) {
+	if pi, ok := doc.Paths[path]; !ok {
+		return nil, fmt.Errorf("path not found (%s)", path)
+	} else if op, ok := pi.Operations()[strings.ToUpper(strings.TrimSpace(method))]; !ok {
+		return nil, fmt.Errorf("operation not found (%s %s)", method, path)
+	} else {
+		return op, nil
+	}
+}
+
+// OperationByID returns the operation with the supplied `operationId`.
+func (doc *Document) OperationByID(operationID string) (*Operation, error) {
+	for _, pi := range doc.Paths {
+		for _, op := range pi.Operations() {
+			if op.OperationID == operationID {
+				return op, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("operationId not found (%s)", operationID)
+}
+
+// SecurityRequirements returns the effective security requirements for an operation,
+// which are the operation's requirements if declared, otherwise the document's.
+func (doc *Document) SecurityRequirements(op *Operation) []SecurityRequirement {
+	if op != nil && op.Security != nil {
+		return *op.Security
+	}
+	return doc.Security
+}
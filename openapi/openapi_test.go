@@ -0,0 +1,137 @@
+package openapi
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/grokify/goauth"
+)
+
+const testSpecYAML = `openapi: 3.0.3
+servers:
+  - url: https://api.example.com/v1
+security:
+  - apiKeyHeader: []
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      responses:
+        200:
+          description: ok
+    post:
+      operationId: createPet
+      security:
+        - oauth: [pets:write]
+        - basicAuth: []
+  /health:
+    get:
+      operationId: health
+      security: []
+components:
+  securitySchemes:
+    apiKeyHeader:
+      type: apiKey
+      in: header
+      name: X-API-Key
+    apiKeyQuery:
+      type: apiKey
+      in: query
+      name: api_key
+    basicAuth:
+      type: http
+      scheme: basic
+    bearerAuth:
+      type: http
+      scheme: bearer
+    oauth:
+      type: oauth2
+      flows:
+        clientCredentials:
+          tokenUrl: https://auth.example.com/token
+          scopes:
+            pets:read: read pets
+            pets:write: write pets
+`
+
+func TestCredentialsSet(t *testing.T) {
+	doc, err := Parse([]byte(testSpecYAML))
+	if err != nil {
+		t.Fatalf("Parse(): err [%v]", err)
+	}
+	set, err := doc.CredentialsSet()
+	if err != nil {
+		t.Fatalf("CredentialsSet(): err [%v]", err)
+	}
+	tests := []struct {
+		account   string
+		credsType string
+	}{
+		{"apiKeyHeader", goauth.TypeHeaderQuery},
+		{"apiKeyQuery", goauth.TypeHeaderQuery},
+		{"basicAuth", goauth.TypeBasic},
+		{"bearerAuth", goauth.TypeHeaderQuery},
+		{"oauth", goauth.TypeOAuth2},
+	}
+	for _, tt := range tests {
+		creds, err := set.Get(tt.account)
+		if err != nil {
+			t.Errorf("CredentialsSet().Get(%s): err [%v]", tt.account, err)
+		} else if creds.Type != tt.credsType {
+			t.Errorf("CredentialsSet().Get(%s).Type: want [%s], got [%s]", tt.account, tt.credsType, creds.Type)
+		}
+	}
+	oauth, err := set.Get("oauth")
+	if err != nil {
+		t.Fatalf("CredentialsSet().Get(oauth): err [%v]", err)
+	}
+	if oauth.OAuth2.Endpoint.TokenURL != "https://auth.example.com/token" ||
+		oauth.OAuth2.GrantType != "client_credentials" ||
+		len(oauth.OAuth2.Scopes) != 2 ||
+		oauth.OAuth2.ServerURL != "https://api.example.com/v1" {
+		t.Errorf("CredentialsSet().Get(oauth).OAuth2: mismatch [%v]", oauth.OAuth2)
+	}
+}
+
+func TestValidateOperation(t *testing.T) {
+	doc, err := Parse([]byte(testSpecYAML))
+	if err != nil {
+		t.Fatalf("Parse(): err [%v]", err)
+	}
+	apiKey := goauth.Credentials{
+		Type: goauth.TypeHeaderQuery,
+		HeaderQuery: &goauth.CredentialsHeaderQuery{
+			Header: http.Header{"X-Api-Key": []string{"secret"}}}}
+	readOnly := goauth.Credentials{
+		Type:   goauth.TypeOAuth2,
+		OAuth2: &goauth.CredentialsOAuth2{Scopes: []string{"pets:read"}}}
+	readWrite := goauth.Credentials{
+		Type:   goauth.TypeOAuth2,
+		OAuth2: &goauth.CredentialsOAuth2{Scopes: []string{"pets:read", "pets:write"}}}
+	basic := goauth.Credentials{
+		Type:  goauth.TypeBasic,
+		Basic: &goauth.CredentialsBasicAuth{Username: "user", Password: "pass"}}
+
+	tests := []struct {
+		creds  goauth.Credentials
+		method string
+		path   string
+		valid  bool
+	}{
+		{apiKey, http.MethodGet, "/pets", true},
+		{readWrite, http.MethodGet, "/pets", false},
+		{readWrite, http.MethodPost, "/pets", true},
+		{readOnly, http.MethodPost, "/pets", false},
+		{basic, http.MethodPost, "/pets", true},
+		{apiKey, http.MethodPost, "/pets", false},
+		{goauth.Credentials{}, http.MethodGet, "/health", true},
+	}
+	for _, tt := range tests {
+		err := doc.ValidateOperation(tt.creds, tt.method, tt.path)
+		if tt.valid && err != nil {
+			t.Errorf("ValidateOperation(%s, %s %s): want valid, got err [%v]", tt.creds.Type, tt.method, tt.path, err)
+		} else if !tt.valid && err == nil {
+			t.Errorf("ValidateOperation(%s, %s %s): want err, got valid", tt.creds.Type, tt.method, tt.path)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/grokify/goauth"
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/mogo/net/http/httputilmore"
+	"github.com/grokify/mogo/type/maputil"
+)
+
+// ValidateOperation checks that the credentials satisfy the security requirements of the
+// operation for the HTTP method and path template.
+func (doc *Document) ValidateOperation(creds goauth.Credentials, method, path string) error {
+	if op, err := doc.Operation(method, path); err != nil {
+		return err
+	} else {
+		return doc.Validate(creds, op)
+	}
+}
+
+// Validate checks that the credentials satisfy the effective security requirements of the
+// operation. Requirements are alternatives, so satisfying any one of them is sufficient.
+// An operation without requirements, or with an empty requirement, needs no credentials.
+func (doc *Document) Validate(creds goauth.Credentials, op *Operation) error {
+	reqs := doc.SecurityRequirements(op)
+	if len(reqs) == 0 {
+		return nil
+	}
+	var errs []error
+	for _, req := range reqs {
+		if err := doc.ValidateRequirement(creds, req); err != nil {
+			errs = append(errs, err)
+		} else {
+			return nil
+		}
+	}
+	return fmt.Errorf("credentials do not satisfy security requirements: %w", errors.Join(errs...))
+}
+
+// ValidateRequirement checks that the credentials satisfy every scheme in the requirement.
+func (doc *Document) ValidateRequirement(creds goauth.Credentials, req SecurityRequirement) error {
+	for _, name := range maputil.Keys(req) {
+		ss, ok := doc.Components.SecuritySchemes[name]
+		if !ok {
+			return fmt.Errorf("security scheme not found (%s)", name)
+		} else if err := ss.Validate(creds, req[name]); err != nil {
+			return fmt.Errorf("security scheme (%s): %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the credentials satisfy the security scheme, including the
+// supplied scopes for `oauth2` and `openIdConnect` schemes.
+func (ss SecurityScheme) Validate(creds goauth.Credentials, scopes []string) error {
+	switch ss.Type {
+	case SchemeTypeAPIKey:
+		if creds.Type != goauth.TypeHeaderQuery || creds.HeaderQuery == nil {
+			return fmt.Errorf("apiKey requires type `%s`", goauth.TypeHeaderQuery)
+		}
+		switch ss.In {
+		case InHeader:
+			if strings.TrimSpace(creds.HeaderQuery.Header.Get(ss.Name)) == "" {
+				return fmt.Errorf("header not set (%s)", ss.Name)
+			}
+		case InQuery:
+			if strings.TrimSpace(creds.HeaderQuery.Query.Get(ss.Name)) == "" {
+				return fmt.Errorf("query parameter not set (%s)", ss.Name)
+			}
+		case InCookie:
+			if !hasCookie(creds.HeaderQuery.Header.Values(headerCookie), ss.Name) {
+				return fmt.Errorf("cookie not set (%s)", ss.Name)
+			}
+		default:
+			return fmt.Errorf("apiKey location not supported (%s)", ss.In)
+		}
+		return nil
+	case SchemeTypeHTTP:
+		switch strings.ToLower(ss.Scheme) {
+		case HTTPSchemeBasic:
+			if creds.Type == goauth.TypeBasic && creds.Basic != nil &&
+				(creds.Basic.Username != "" || strings.TrimSpace(creds.Basic.Encoded) != "") {
+				return nil
+			} else if hasAuthorization(creds, authutil.TokenBasic) {
+				return nil
+			}
+			return errors.New("basic auth credentials not set")
+		case HTTPSchemeBearer:
+			switch creds.Type {
+			case goauth.TypeOAuth2, goauth.TypeGoogleOAuth2, goauth.TypeGCPSA, goauth.TypeJWT:
+				return nil
+			}
+			if creds.Token != nil && strings.TrimSpace(creds.Token.AccessToken) != "" {
+				return nil
+			} else if hasAuthorization(creds, authutil.TokenBearer) {
+				return nil
+			}
+			return errors.New("bearer token not set")
+		default:
+			return fmt.Errorf("http scheme not supported (%s)", ss.Scheme)
+		}
+	case SchemeTypeOAuth2, SchemeTypeOpenIDConnect:
+		if creds.Type != goauth.TypeOAuth2 || creds.OAuth2 == nil {
+			return fmt.Errorf("%s requires type `%s`", ss.Type, goauth.TypeOAuth2)
+		}
+		var missing []string
+		for _, scope := range scopes {
+			if !slices.Contains(creds.OAuth2.Scopes, scope) {
+				missing = append(missing, scope)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("scopes not set (%s)", strings.Join(missing, ","))
+		}
+		return nil
+	default:
+		return fmt.Errorf("security scheme type not supported (%s)", ss.Type)
+	}
+}
+
+// hasAuthorization returns true if a header query credential sets an `Authorization`
+// header with the supplied scheme and a non-empty value.
+func hasAuthorization(creds goauth.Credentials, scheme string) bool {
+	if creds.Type != goauth.TypeHeaderQuery || creds.HeaderQuery == nil {
+		return false
+	}
+	parts := strings.Fields(creds.HeaderQuery.Header.Get(httputilmore.HeaderAuthorization))
+	return len(parts) == 2 && strings.EqualFold(parts[0], scheme)
+}
+
+// hasCookie returns true if a `Cookie` header value sets the named cookie to a non-empty value.
+func hasCookie(vals []string, name string) bool {
+	for _, v := range vals {
+		for _, c := range strings.Split(v, ";") {
+			if k, val, ok := strings.Cut(strings.TrimSpace(c), "="); ok && k == name && val != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -20,7 +20,8 @@ import (
 )
 
 func NewTokenPassword(oc goauth.CredentialsOAuth2) (*oauth2.Token, error) {
-	return RetrieveToken(
+	return RetrieveTokenContext(
+		authutil.ContextWithHTTPClient(context.Background(), oc.HTTPClient),
 		oauth2.Config{
 			ClientID:     oc.ClientID,
 			ClientSecret: oc.ClientSecret,
@@ -30,13 +31,14 @@ func NewTokenPassword(oc goauth.CredentialsOAuth2) (*oauth2.Token, error) {
 
 // NewClientPassword uses dedicated password grant handling.
 func NewClientPassword(oc goauth.CredentialsOAuth2) (*http.Client, error) {
+	ctx := authutil.ContextWithHTTPClient(context.Background(), oc.HTTPClient)
 	c := oc.Config()
-	token, err := RetrieveToken(c, oc.PasswordRequestBody())
+	token, err := RetrieveTokenContext(ctx, c, oc.PasswordRequestBody())
 	if err != nil {
 		return nil, err
 	}
 
-	httpClient := c.Client(context.Background(), token)
+	httpClient := c.Client(ctx, token)
 
 	header := getClientHeader(oc)
 	if len(header) > 0 {
@@ -49,7 +51,8 @@ func NewClientPassword(oc goauth.CredentialsOAuth2) (*http.Client, error) {
 
 // NewClientPasswordSimple uses OAuth2 package password grant handling.
 func NewClientPasswordSimple(oc goauth.CredentialsOAuth2) (*http.Client, error) {
-	httpClient, err := authutil.NewClientPasswordConf(
+	httpClient, err := authutil.NewClientPasswordConfContext(
+		authutil.ContextWithHTTPClient(context.Background(), oc.HTTPClient),
 		oauth2.Config{
 			ClientID:     oc.ClientID,
 			ClientSecret: oc.ClientSecret,
@@ -111,7 +114,13 @@ func (oc *CredentialsOAuth2) AppNameAndVersion() string {
 */
 
 func RetrieveToken(cfg oauth2.Config, params url.Values) (*oauth2.Token, error) {
-	rcToken, err := RetrieveRcToken(cfg, params)
+	return RetrieveTokenContext(context.Background(), cfg, params)
+}
+
+// RetrieveTokenContext is like `RetrieveToken()` but uses the `*http.Client` in the
+// `oauth2.HTTPClient` context key.
+func RetrieveTokenContext(ctx context.Context, cfg oauth2.Config, params url.Values) (*oauth2.Token, error) {
+	rcToken, err := RetrieveRcTokenContext(ctx, cfg, params)
 	if err != nil {
 		return nil, err
 	}
@@ -119,7 +128,14 @@ func RetrieveToken(cfg oauth2.Config, params url.Values) (*oauth2.Token, error)
 }
 
 func RetrieveRcToken(cfg oauth2.Config, params url.Values) (*RcToken, error) {
-	r, err := http.NewRequest(
+	return RetrieveRcTokenContext(context.Background(), cfg, params)
+}
+
+// RetrieveRcTokenContext is like `RetrieveRcToken()` but uses the `*http.Client` in the
+// `oauth2.HTTPClient` context key.
+func RetrieveRcTokenContext(ctx context.Context, cfg oauth2.Config, params url.Values) (*RcToken, error) {
+	r, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodPost,
 		cfg.Endpoint.TokenURL,
 		strings.NewReader(params.Encode()))
@@ -136,8 +152,7 @@ func RetrieveRcToken(cfg oauth2.Config, params url.Values) (*RcToken, error) {
 	r.Header.Add(hum.HeaderContentType, hum.ContentTypeAppFormURLEncoded)
 	r.Header.Add(hum.HeaderContentLength, strconv.Itoa(len(params.Encode())))
 
-	client := &http.Client{}
-	resp, err := client.Do(r)
+	resp, err := authutil.ContextClient(ctx).Do(r)
 	if err != nil {
 		return nil, err
 	}
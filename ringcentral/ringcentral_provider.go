@@ -0,0 +1,14 @@
+package ringcentral
+
+import (
+	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/goauth/endpoints"
+	"github.com/grokify/goauth/multiservice/providers"
+)
+
+func init() {
+	providers.MustRegister(providers.Provider{
+		Name:          endpoints.ServiceRingcentral,
+		Endpoint:      providers.ServiceEndpoint(endpoints.ServiceRingcentral),
+		NewClientUtil: func() authutil.OAuth2Util { return &ClientUtil{} }})
+}
@@ -4,10 +4,10 @@ import (
 	"context"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/grokify/goauth"
 	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/goauth/authutil/oauthstate"
 	"golang.org/x/oauth2"
 )
 
@@ -15,7 +15,7 @@ func NewTokenCLI(ctx context.Context, creds goauth.Credentials, state string) (t
 	if creds.OAuth2.IsGrantType(authutil.GrantTypeAuthorizationCode) {
 		state = strings.TrimSpace(state)
 		if len(state) == 0 {
-			state = "goauth-" + time.Now().UTC().Format(time.RFC3339)
+			state = "goauth-" + oauthstate.Random()
 		}
 		fmt.Printf("OAuth State [%s]\n", state)
 		cfg := creds.OAuth2.Config()
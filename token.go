@@ -4,9 +4,9 @@ import (
 	"context"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/grokify/goauth/authutil"
+	"github.com/grokify/goauth/authutil/oauthstate"
 	"golang.org/x/oauth2"
 )
 
@@ -14,7 +14,7 @@ func NewTokenCLI(ctx context.Context, creds Credentials, state string) (token *o
 	if creds.OAuth2.IsGrantType(authutil.GrantTypeAuthorizationCode) {
 		state = strings.TrimSpace(state)
 		if len(state) == 0 {
-			state = "goauth-" + time.Now().UTC().Format(time.RFC3339)
+			state = "goauth-" + oauthstate.Random()
 		}
 		fmt.Printf("OAuth State [%s]\n", state)
 		cfg := creds.OAuth2.Config()